@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// xmlディレクトリの電文と生成したGeoJSONをアーカイブに入れる
+// 既に入っている電文は飛ばし、取消された電文は取り除く
+func runArchive(args []string) {
+	flags := flag.NewFlagSet("archive", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	archiveDir := flags.String("archive", "./archive", "アーカイブのディレクトリ")
+	force := flags.Bool("force", false, "既に入っている電文も生成し直す")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	options := productOptions()
+	registry, err := usecase.LoadStormRegistry(*xmlDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	archive, err := usecase.OpenArchive(*archiveDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer archive.Close()
+
+	for _, entry := range registry.AuditLog() {
+		if entry.InfoType != "取消" {
+			continue
+		}
+		if err := archive.Remove(entry.EventID, entry.Serial); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	added := 0
+	for _, storm := range registry.Storms() {
+		for _, advisory := range storm.Advisories {
+			if !*force && archive.Has(advisory) {
+				continue
+			}
+			if err := archive.Put(advisory, service.MakeTyphoonFeatureCollection(advisory.Typhoons, options)); err != nil {
+				log.Fatal(err)
+			}
+			added++
+		}
+	}
+
+	fmt.Printf("%d件の電文を入れ、%s には%d件の電文があります\n", added, *archiveDir, len(archive.Entries()))
+}
+
+// アーカイブから台風・報数・時刻・範囲で電文を探して一覧を表示する
+// 例: -storm TC2410 で台風の全ての電文、-time と -bbox でその時刻にその範囲にかかる電文
+func runQuery(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	archiveDir := flags.String("archive", "./archive", "アーカイブのディレクトリ")
+	eventID := flags.String("storm", "", "EventID (例: TC2410)")
+	serial := flags.Int("serial", 0, "報数")
+	validTime := flags.String("time", "", "この時刻の実況・予報を含む電文 (例: 2024-08-28T00:00:00Z)")
+	bbox := flags.String("bbox", "", "この範囲にかかる電文 (西端の経度,南端の緯度,東端の経度,北端の緯度)")
+	typhoons := flags.Bool("typhoons", false, "電文の実況・予報も表示する")
+	flags.Parse(args)
+
+	archive, err := usecase.OpenArchiveReadOnly(*archiveDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer archive.Close()
+
+	query := model.ArchiveQuery{EventID: *eventID, Serial: *serial}
+	if *validTime != "" {
+		t, err := time.Parse(time.RFC3339, *validTime)
+		if err != nil {
+			log.Fatalf("無効な時刻: %s", *validTime)
+		}
+		query.ValidTime = &t
+	}
+	if *bbox != "" {
+		bounds := parseBBox(*bbox)
+		query.Bounds = &bounds
+	}
+
+	entries := archive.Query(query)
+	for _, entry := range entries {
+		fmt.Printf(
+			"%s\t%s\t第%d報\t%s\t%s〜%s\t%s\n",
+			entry.EventID, entry.TyphoonName, entry.Serial, entry.InfoType,
+			entry.ValidFrom.UTC().Format(usecase.TargetTimestampLayout), entry.ValidTo.UTC().Format(usecase.TargetTimestampLayout),
+			filepath.Join(archive.Dir(), entry.GeoJSONPath),
+		)
+		if !*typhoons {
+			continue
+		}
+		advisory, err := archive.LoadAdvisory(entry)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, typhoon := range advisory.Typhoons {
+			fmt.Printf(
+				"\t%s\t%s\t%.1f,%.1f\t%dhPa\n",
+				typhoon.TargetTimestamp, typhoon.TargetTimestampType, typhoon.Latitude, typhoon.Longitude, typhoon.CentralPressure,
+			)
+		}
+	}
+	fmt.Printf("%d件の電文が見つかりました\n", len(entries))
+}
+
+// "120,20,150,45"のような西端の経度,南端の緯度,東端の経度,北端の緯度をパースする
+func parseBBox(s string) model.MapBounds {
+	values := strings.Split(s, ",")
+	if len(values) != 4 {
+		log.Fatalf("無効な範囲: %s", s)
+	}
+	v := [4]float64{}
+	for i, value := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			log.Fatalf("無効な範囲: %s", s)
+		}
+		v[i] = f
+	}
+	return model.MapBounds{MinLongitude: v[0], MinLatitude: v[1], MaxLongitude: v[2], MaxLatitude: v[3]}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// JTWC・NHCのATCF a-deck・b-deckを読み、気象庁の電文と同じ方法でGeoJSONに書き出す
+// b-deck(BEST)は実況経路と暴風域・強風域の通過範囲、a-deckは指定した初期時刻の予報を書き出す
+// -compareを指定した場合は、同じ時刻の気象庁の電文との差分も書き出す
+func runATCF(args []string) {
+	flags := flag.NewFlagSet("atcf", flag.ExitOnError)
+	deckPath := flags.String("deck", "", "a-deck・b-deckのパス (例: awp102024.dat)")
+	tech := flags.String("tech", "", "使う予報の種類 (省略時はBEST・JTWC・OFCLの順に、あるものを使う)")
+	initTime := flags.String("init", "", "使う予報の初期時刻 (例: 2024081900、省略時は最新)")
+	cone := flags.String("cone", "", "予報円の半径(km) (例: 24:90,48:160,72:240)")
+	compare := flags.String("compare", "", "比べる気象庁の台風 (EventID・台風番号・台風名のいずれか)")
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ (-compare指定時)")
+	outputDir := flags.String("o", "./geojson", "書き出すディレクトリ")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	if *deckPath == "" {
+		log.Fatal("-deck を指定してください")
+	}
+	records, err := usecase.ReadATCFDeck(*deckPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tech == "" {
+		*tech = selectATCFTech(records)
+	}
+	coneRadii := map[int]int{}
+	if *cone != "" {
+		coneRadii = parseConeRadii(*cone)
+	}
+	storm, err := usecase.MakeATCFStorm(records, *tech, coneRadii)
+	if err != nil {
+		log.Fatalf("%s: %v", *deckPath, err)
+	}
+	for i := range storm.Advisories {
+		storm.Advisories[i].SourcePath = *deckPath
+	}
+
+	if strings.EqualFold(*tech, "BEST") {
+		saveBestTrack(storm, *outputDir)
+		return
+	}
+
+	advisory, ok := usecase.LatestAdvisory(storm)
+	if *initTime != "" {
+		t, err := time.Parse("2006010215", *initTime)
+		if err != nil {
+			log.Fatalf("無効な初期時刻: %s", *initTime)
+		}
+		advisory, ok = usecase.FindAdvisoryByAnalysisTime(storm, t)
+		if !ok || !advisory.TargetDateTime.Equal(t) {
+			log.Fatalf("%s の初期時刻 %s の予報がありません", *tech, *initTime)
+		}
+	}
+	if !ok {
+		log.Fatalf("%s の予報がありません", *tech)
+	}
+
+	baseName := fmt.Sprintf("%s_%s_%s", storm.EventID, advisory.TargetDateTime.UTC().Format("2006010215"), strings.ToLower(*tech))
+	savePath := filepath.Join(*outputDir, baseName+".geojson")
+	options := productOptions()
+	if err := service.SaveTyphoonGeoJSON(savePath, advisory.Typhoons, options); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s %s の%s予報を %s に書き出しました\n", storm.EventID, advisory.TargetDateTime.UTC().Format(usecase.TargetTimestampLayout), *tech, savePath)
+
+	if *compare == "" {
+		return
+	}
+	jmaStorm := loadStorm(*xmlDir, *compare)
+	jmaAdvisory, ok := usecase.FindAdvisoryByAnalysisTime(jmaStorm, advisory.TargetDateTime)
+	if !ok {
+		log.Fatalf("%s に %s 以前の実況の電文がありません", jmaStorm.EventID, advisory.TargetDateTime.UTC().Format(usecase.TargetTimestampLayout))
+	}
+	diff := service.CalcAdvisoryDiff(jmaAdvisory, advisory)
+	diffPath := filepath.Join(*outputDir, fmt.Sprintf("%s_diff_%s_%d.geojson", baseName, jmaStorm.EventID, jmaAdvisory.Serial))
+	if err := usecase.SaveFeatureCollectionToFile(diffPath, service.MakeAdvisoryDiffFeatureCollection(diff)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("気象庁 %s 第%d報との比較 (予報円の範囲の追加・削除は%s側から見たもの)\n", jmaStorm.EventID, jmaAdvisory.Serial, *tech)
+	fmt.Print(service.FormatAdvisoryDiffSummary(diff))
+	fmt.Printf("差分のGeoJSONを %s に書き出しました\n", diffPath)
+}
+
+// b-deckならBEST、a-deckならJTWC・OFCLの順にあるものを使い、どれも無ければ最初の行のものを使う
+func selectATCFTech(records []model.ATCFRecord) string {
+	techs := map[string]bool{}
+	for _, record := range records {
+		techs[record.Tech] = true
+	}
+	for _, tech := range []string{"BEST", "JTWC", "OFCL"} {
+		if techs[tech] {
+			return tech
+		}
+	}
+	if len(records) == 0 {
+		log.Fatal("ATCFの行がありません")
+	}
+	return records[0].Tech
+}
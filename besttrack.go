@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// IBTrACSのCSV・気象庁のベストトラックを読み、指定した台風の実況経路と暴風域・強風域の通過範囲をGeoJSONに書き出す
+// -stormを指定しない場合は台風の一覧を表示する
+func runBestTrack(args []string) {
+	flags := flag.NewFlagSet("besttrack", flag.ExitOnError)
+	ibtracsPath := flags.String("ibtracs", "", "IBTrACSのCSVのパス (例: ibtracs.WP.list.v04r01.csv)")
+	rsmcPath := flags.String("rsmc", "", "気象庁のベストトラックのパス (例: bst_all.txt)")
+	stormQuery := flags.String("storm", "", "書き出す台風 (EventID・SID・台風番号・台風名のいずれか)")
+	outputDir := flags.String("o", "./geojson", "書き出すディレクトリ")
+	flags.Parse(args)
+
+	var storms []*model.Storm
+	var err error
+	switch {
+	case *ibtracsPath != "":
+		storms, err = usecase.ReadIBTrACSCSV(*ibtracsPath)
+	case *rsmcPath != "":
+		storms, err = usecase.ReadRSMCBestTrack(*rsmcPath)
+	default:
+		log.Fatal("-ibtracs か -rsmc を指定してください")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	registry := usecase.NewStormRegistry()
+	for _, storm := range storms {
+		for _, advisory := range storm.Advisories {
+			registry.Add(advisory)
+		}
+	}
+
+	if *stormQuery == "" {
+		for _, storm := range registry.Storms() {
+			first := storm.Advisories[0]
+			latest, _ := usecase.LatestAdvisory(storm)
+			fmt.Printf(
+				"%s\t%s\t%s〜%s\t%d件\n",
+				storm.EventID, storm.TyphoonName,
+				first.TargetDateTime.UTC().Format(usecase.TargetTimestampLayout), latest.TargetDateTime.UTC().Format(usecase.TargetTimestampLayout),
+				len(storm.Advisories),
+			)
+		}
+		return
+	}
+
+	storm, err := registry.FindStorm(*stormQuery)
+	if err != nil {
+		log.Fatal(err)
+	}
+	saveBestTrack(storm, *outputDir)
+}
+
+// ベストトラックの実況経路と暴風域・強風域の通過範囲を書き出す
+func saveBestTrack(storm *model.Storm, outputDir string) {
+	latest, _ := usecase.LatestAdvisory(storm)
+	track := service.MakeObservedTrack(storm)
+	trackPath := filepath.Join(outputDir, storm.EventID+"_best_track.geojson")
+	if err := usecase.SaveFeatureCollectionToFile(trackPath, service.MakeObservedTrackFeatureCollection(track, latest)); err != nil {
+		log.Fatal(err)
+	}
+
+	// 強風域の上に暴風域が重なるように、強風域を先に入れる
+	swaths := service.CalcObservedSwaths(storm, "強風域")
+	swaths = append(swaths, service.CalcObservedSwaths(storm, "暴風域")...)
+	swathPath := filepath.Join(outputDir, storm.EventID+"_best_swath.geojson")
+	if err := usecase.SaveFeatureCollectionToFile(swathPath, service.MakeObservedSwathFeatureCollection(swaths)); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s の実況%d件の経路を %s に、通過範囲を %s に書き出しました\n", storm.EventID, len(track.Fixes), trackPath, swathPath)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// 指定した台風の電文の予報円の範囲・暴風警戒域や強風域の通過範囲に入る人口・拠点を集計する
+func runExposure(args []string) {
+	flags := flag.NewFlagSet("exposure", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	serial := flags.Int("serial", 0, "使う電文の報数 (省略時は最新)")
+	populationPath := flags.String("population", "", "人口のESRI ASCII Grid(.asc)のパス")
+	sitesPath := flags.String("sites", "", "拠点のCSVのパス (name,latitude,longitude[,category,value])")
+	windows := flags.String("windows", "0-24,24-72,72-120", "集計する何時間後の範囲 (全体の集計に加えて出す)")
+	output := flags.String("o", "", "書き出すJSONのパス (省略時は<EventID>_<報数>_exposure.json)")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	if *populationPath == "" && *sitesPath == "" {
+		log.Fatal("-population か -sites を指定してください")
+	}
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	advisory := selectAdvisory(storm, *serial)
+
+	var population *model.Grid
+	if *populationPath != "" {
+		grid, err := usecase.ReadGridASCII(*populationPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		population = &grid
+	}
+	sites := []model.Site{}
+	if *sitesPath != "" {
+		var err error
+		if sites, err = usecase.ReadSitesCSV(*sitesPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// 全体(オプションの範囲そのまま)に続けて、何時間後の範囲ごとに集計する
+	options := productOptions()
+	leadWindows := []model.ProductOptions{options}
+	for _, window := range strings.Split(*windows, ",") {
+		minLeadHours, maxLeadHours, err := usecase.ParseLeadRange(window)
+		if err != nil {
+			log.Fatal(err)
+		}
+		windowOptions := options
+		windowOptions.MinLeadHours, windowOptions.MaxLeadHours, windowOptions.HasMaxLeadHours = minLeadHours, maxLeadHours, true
+		leadWindows = append(leadWindows, windowOptions)
+	}
+
+	areas := service.MakeProductAreas(advisory.Typhoons, leadWindows)
+	reports := service.CalcExposure(advisory, areas, population, sites)
+
+	fmt.Printf("%s 第%d報\n", storm.EventID, advisory.Serial)
+	for _, report := range reports {
+		fmt.Printf(
+			"%-20s %3d〜%3d時間後\t面積 約%.0fkm2\t人口 約%.0f人\t拠点 %d件 %s\n",
+			report.Product, report.LeadFrom, report.LeadTo, report.Area, report.Population, len(report.Sites), strings.Join(report.Sites, ","),
+		)
+	}
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", fmt.Sprintf("%s_%d_exposure.json", storm.EventID, advisory.Serial))
+	}
+	if err := usecase.SaveExposureReports(savePath, reports); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("集計結果を %s に書き出しました\n", savePath)
+}
@@ -7,3 +7,5 @@ toolchain go1.22.0
 require github.com/paulmach/go.geojson v1.5.0
 
 require github.com/twpayne/go-geos v0.18.1
+
+require golang.org/x/image v0.15.0
@@ -1,27 +1,113 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
-	"typhoon-polygon/model"
 	"typhoon-polygon/service"
 	"typhoon-polygon/usecase"
-
-	geojson "github.com/paulmach/go.geojson"
 )
 
 func main() {
+	command := "convert"
+	args := []string{}
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+		args = os.Args[2:]
+	}
+
+	switch command {
+	case "convert":
+		runConvert(args)
+	case "poll":
+		runPoll(args)
+	case "storms":
+		runStorms(args)
+	case "latest":
+		runLatest(args)
+	case "track":
+		runTrack(args)
+	case "swath":
+		runSwath(args)
+	case "windswath":
+		runWindSwath(args)
+	case "isochrone":
+		runIsochrone(args)
+	case "probability":
+		runProbability(args)
+	case "contour":
+		runContour(args)
+	case "exposure":
+		runExposure(args)
+	case "watch":
+		runWatch(args)
+	case "besttrack":
+		runBestTrack(args)
+	case "atcf":
+		runATCF(args)
+	case "verify":
+		runVerify(args)
+	case "calibration":
+		runCalibration(args)
+	case "diff":
+		runDiff(args)
+	case "index":
+		runIndex(args)
+	case "archive":
+		runArchive(args)
+	case "query":
+		runQuery(args)
+	case "render":
+		runRender(args)
+	case "animate":
+		runAnimate(args)
+	default:
+		fmt.Printf("不明なコマンド: %s\n", command)
+		os.Exit(2)
+	}
+}
+
+// jsonディレクトリのjsonをgeojsonディレクトリにGeoJSONとして書き出す
+// -stormを指定した場合は、xmlディレクトリからその台風の電文だけを書き出す
+func runConvert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ (訂正・取消で差し替えられた電文を調べる)")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	productOptions := addProductFlags(flags)
+	webhookNotifier := addWebhookFlags(flags)
+	flags.Parse(args)
+
+	options := productOptions()
+	notifier := webhookNotifier()
+
+	if *stormQuery != "" {
+		runConvertStorm(*xmlDir, *stormQuery, options, notifier)
+		return
+	}
+	// jsonには報数などの電文の情報が無く、毎回全てのファイルを書き直すので知らせない
+	if notifier != nil {
+		log.Fatal("Webhookで知らせるには -storm を指定するか、poll を使ってください")
+	}
+
 	// 検索するディレクトリ
 	dir := "./json"
 
+	// 訂正・取消で差し替えられた電文は変換しない
+	registry, err := usecase.LoadStormRegistry(*xmlDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+	if err == nil {
+		retractSuperseded(registry, dir, "./geojson")
+	}
+
 	var jsonFiles []string
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -42,111 +128,16 @@ func main() {
 	for _, path := range jsonFiles {
 		// JSONファイルを読み込む
 		fmt.Println(path)
-		jsonFile, err := os.Open(path)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer jsonFile.Close()
-
-		// JSONファイルの内容を読み込む
-		byteValue, _ := io.ReadAll(jsonFile)
-
-		// データを構造体にデコード
-		var typhoons []model.Typhoon
-		err = json.Unmarshal(byteValue, &typhoons)
+		typhoons, err := usecase.ReadTyphoonJSONFile(path)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		stormAreaTimeSeries := []model.StormArea{}
-		forecastCircleTimeSeries := []model.ForecastCircle{}
-
-		for _, typhoon := range typhoons {
-			if typhoon.TargetTimestampType == "実況" {
-				forecastCircleTimeSeries = append(
-					forecastCircleTimeSeries,
-					model.ForecastCircle{
-						CenterPoint:          model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
-						CircleLongDirection:  float64(0),
-						CircleLongRadius:     float64(0),
-						CircleShortDirection: float64(0),
-						CircleShortRadius:    float64(0),
-					},
-				)
-			}
-			for _, warningArea := range typhoon.WarningAreas {
-				if warningArea.WarningAreaType == "暴風域" || warningArea.WarningAreaType == "暴風警戒域" {
-					if warningArea.CircleLongRadius == 0 {
-						continue
-					}
-					stormAreaTimeSeries = append(
-						stormAreaTimeSeries,
-						model.StormArea{
-							CenterPoint:          model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
-							CircleLongDirection:  usecase.DirectionToDegrees(warningArea.CircleLongDirection),
-							CircleLongRadius:     float64(warningArea.CircleLongRadius),
-							CircleShortDirection: usecase.DirectionToDegrees(warningArea.CircleShortDirection),
-							CircleShortRadius:    float64(warningArea.CircleShortRadius),
-						},
-					)
-				}
-				if warningArea.WarningAreaType == "予報円" {
-					if warningArea.CircleLongRadius == 0 {
-						continue
-					}
-					forecastCircleTimeSeries = append(
-						forecastCircleTimeSeries,
-						model.ForecastCircle{
-							CenterPoint:          model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
-							CircleLongDirection:  usecase.DirectionToDegrees(warningArea.CircleLongDirection),
-							CircleLongRadius:     float64(warningArea.CircleLongRadius),
-							CircleShortDirection: usecase.DirectionToDegrees(warningArea.CircleShortDirection),
-							CircleShortRadius:    float64(warningArea.CircleShortRadius),
-						},
-					)
-				}
-			}
-		}
-
-		featureCollection := geojson.NewFeatureCollection()
-
-		// 暴風域のGeoJson追加
-		if len(stormAreaTimeSeries) > 0 {
-			stormAreaBorderPoints := service.CalcStormAreaPolygon(stormAreaTimeSeries)
-			stormAreaBorderPolygon := usecase.MakeGeojsonPolygon(stormAreaBorderPoints)
-			featureCollection.AddFeature(stormAreaBorderPolygon)
-		}
-
-		// 予報円のGeoJson追加
-		if len(forecastCircleTimeSeries) > 1 {
-			forecastCirclePolygons := service.CalcForecastCirclePolygons(forecastCircleTimeSeries)
-			for _, circle := range forecastCirclePolygons.ForecastCircles {
-				polygon := usecase.MakeGeojsonPolygon(circle)
-				featureCollection.AddFeature(polygon)
-			}
-			if len(forecastCirclePolygons.ForecastCircleBorder) > 0 {
-				forcastCircleBorderPolygon := usecase.MakeGeojsonPolygon(forecastCirclePolygons.ForecastCircleBorder)
-				featureCollection.AddFeature(forcastCircleBorderPolygon)
-			}
-
-			centerLineLineString := usecase.MakeGeojsonLineString(forecastCirclePolygons.CenterLine)
-			featureCollection.AddFeature(centerLineLineString)
-		}
-
-		// GeoJSONとしてエンコード
-		geoJSON, err := json.MarshalIndent(featureCollection, "", "  ")
-		if err != nil {
-			fmt.Println("Error encoding GeoJSON:", err)
-			return
-		}
-
-		// GeoJSONを出力
-		// fmt.Println(string(geoJSON))
-
 		// ファイルに保存する
 		savePath := strings.Replace(path, ".json", ".geojson", 1)
 		savePath = strings.Replace(savePath, "json/", "geojson/", 1)
-		err = usecase.SaveGeoJSONToFile(savePath, geoJSON)
+		featureCollection := service.MakeTyphoonFeatureCollection(typhoons, options)
+		err = usecase.SaveFeatureCollectionToFile(savePath, featureCollection)
 		if err != nil {
 			fmt.Println("Error saving GeoJSON to file:", err)
 			return
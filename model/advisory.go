@@ -0,0 +1,114 @@
+package model
+
+import "time"
+
+// 台風解析・予報情報の1電文分
+type Advisory struct {
+	EventID         string    `json:"event_id"` // 例: TC2410
+	Serial          int       `json:"serial"`
+	InfoType        string    `json:"info_type"` // 発表・訂正・取消
+	ReportDateTime  time.Time `json:"report_date_time"`
+	TargetDateTime  time.Time `json:"target_date_time"`
+	TyphoonNumber   string    `json:"typhoon_number"` // 例: 2409
+	TyphoonName     string    `json:"typhoon_name"`
+	TyphoonNameKana string    `json:"typhoon_name_kana"`
+	SourcePath      string    `json:"source_path"`
+	Typhoons        []Typhoon `json:"typhoons"`
+}
+
+// 同じ台風(EventID)の電文をまとめたもの
+// Advisoriesは報数(Serial)の順に並ぶ
+type Storm struct {
+	EventID         string     `json:"event_id"`
+	TyphoonNumber   string     `json:"typhoon_number"`
+	TyphoonName     string     `json:"typhoon_name"`
+	TyphoonNameKana string     `json:"typhoon_name_kana"`
+	Advisories      []Advisory `json:"advisories"`
+}
+
+// 実況(解析)の中心位置と強さ
+type TrackFix struct {
+	TargetTimestamp           string  `json:"target_timestamp"`
+	FixKind                   FixKind `json:"fix_kind"` // 実況・推定
+	Serial                    int     `json:"serial"`   // 解析が含まれていた電文の報数
+	CenterPoint               Point   `json:"center_point"`
+	CentralPressure           int     `json:"central_pressure"`
+	MaxWindSpeedNearTheCenter int     `json:"max_wind_speed_near_the_center"`
+	InstantaneousMaxWindSpeed int     `json:"instantaneous_max_wind_speed"`
+	TyphoonClass              string  `json:"typhoon_class"`
+	AreaClass                 string  `json:"area_class"`
+	IntensityClass            string  `json:"intensity_class"`
+}
+
+// 連続する電文の実況から組み立てた経路
+// 最新の電文に推定(1時間後)の位置があれば、最後に推定として入る
+type ObservedTrack struct {
+	EventID string     `json:"event_id"`
+	Fixes   []TrackFix `json:"fixes"` // 時刻順
+}
+
+// 実況の暴風域・強風域が実際に通過した範囲
+// 暴風域が途切れた期間があると、途切れるごとに別の範囲になる
+type ObservedSwath struct {
+	EventID         string  `json:"event_id"`
+	WarningAreaType string  `json:"warning_area_type"` // 暴風域・強風域
+	WindSpeed       int     `json:"wind_speed"`        // m/s
+	From            string  `json:"from"`              // 最初の実況のTargetTimestamp
+	To              string  `json:"to"`                // 最後の実況のTargetTimestamp
+	Polygon         []Point `json:"polygon"`
+}
+
+// 暴風域・強風域などが時間を区切ったそれぞれの間に初めてかかる範囲
+// 例: 風速25m/sで0〜12時間後に初めて暴風域に入る範囲
+type WindSwath struct {
+	EventID   string      `json:"event_id"`
+	WindSpeed int         `json:"wind_speed"` // m/s
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	LeadFrom  int         `json:"lead_from"` // 何時間後から
+	LeadTo    int         `json:"lead_to"`   // 何時間後まで
+	Polygons  [][][]Point `json:"polygons"`  // ポリゴンごとに外周・穴の順
+}
+
+// 同じ時刻の予報位置が2つの電文の間でどれだけ動いたか
+type TrackDisplacement struct {
+	TargetTimestamp       string  `json:"target_timestamp"`
+	TargetTimestampType   string  `json:"target_timestamp_type"` // 新しい電文での種別
+	FromPoint             Point   `json:"from_point"`
+	ToPoint               Point   `json:"to_point"`
+	Distance              float64 `json:"distance"` // km
+	CentralPressureChange int     `json:"central_pressure_change"`
+	MaxWindSpeedChange    int     `json:"max_wind_speed_change"`
+}
+
+// 2つの電文の予報円・予報経路の差分
+type AdvisoryDiff struct {
+	EventID       string              `json:"event_id"`
+	FromSerial    int                 `json:"from_serial"`
+	ToSerial      int                 `json:"to_serial"`
+	AddedArea     [][][]Point         `json:"added_area"`   // 新しい電文で予報円の範囲に加わった部分 (外周・穴の順に並んだリング)
+	RemovedArea   [][][]Point         `json:"removed_area"` // 新しい電文で予報円の範囲から外れた部分 (外周・穴の順に並んだリング)
+	Displacements []TrackDisplacement `json:"displacements"`
+}
+
+// 訂正・取消によって電文が差し替えられた記録
+type AdvisoryAuditEntry struct {
+	InfoType           string    `json:"info_type"` // 訂正・取消
+	EventID            string    `json:"event_id"`
+	Serial             int       `json:"serial"`
+	ReportDateTime     time.Time `json:"report_date_time"`
+	SourcePath         string    `json:"source_path"`          // 訂正・取消の電文
+	ReplacedSourcePath string    `json:"replaced_source_path"` // 差し替えられた電文 (無ければ空)
+}
+
+// 台風ごとの最新の電文
+type LatestProduct struct {
+	EventID        string    `json:"event_id"`
+	TyphoonNumber  string    `json:"typhoon_number"`
+	TyphoonName    string    `json:"typhoon_name"`
+	Serial         int       `json:"serial"`
+	InfoType       string    `json:"info_type"`
+	ReportDateTime time.Time `json:"report_date_time"`
+	SourcePath     string    `json:"source_path"`
+	GeoJSONPath    string    `json:"geojson_path"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// 拠点が電文の範囲に入っているかの通知
+type SiteAlert struct {
+	EventID         string     `json:"event_id"`
+	TyphoonName     string     `json:"typhoon_name"`
+	Serial          int        `json:"serial"`
+	ReportDateTime  time.Time  `json:"report_date_time"`
+	Site            string     `json:"site"`
+	Category        string     `json:"category"`
+	Product         string     `json:"product"` // forecast_cone・storm_warning_swath・gale_swath
+	Status          string     `json:"status"`  // new・continued・cleared (前の報数と比べて)
+	EarliestTime    *time.Time `json:"earliest_time,omitempty"`
+	EarliestLead    int        `json:"earliest_lead"`    // 範囲に入り始める何時間後
+	ClosestTime     time.Time  `json:"closest_time"`     // 中心が最も近づく時刻
+	ClosestDistance float64    `json:"closest_distance"` // 中心が最も近づく距離(km)
+	ConeDistance    float64    `json:"cone_distance"`    // 予報円の範囲の縁までの最短距離(km、範囲に入る場合は0)
+}
+
+const (
+	SiteAlertStatusNew       = "new"
+	SiteAlertStatusContinued = "continued"
+	SiteAlertStatusCleared   = "cleared"
+)
+
+// 拠点に台風の中心が最も近づくとき
+type ClosestApproach struct {
+	Time         time.Time `json:"time"`
+	LeadHours    int       `json:"lead_hours"`
+	CenterPoint  Point     `json:"center_point"`
+	Distance     float64   `json:"distance"`      // 中心までの距離(km)
+	InsideCone   bool      `json:"inside_cone"`   // 予報円の範囲に入るか
+	ConeDistance float64   `json:"cone_distance"` // 予報円の範囲の縁までの最短距離(km、範囲に入る場合は0)
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// アーカイブの索引の1電文分
+// 電文そのものとGeoJSONはPathに置き、索引には検索に使う項目だけを入れる
+type ArchiveEntry struct {
+	EventID        string       `json:"event_id"`
+	TyphoonNumber  string       `json:"typhoon_number"`
+	TyphoonName    string       `json:"typhoon_name"`
+	Serial         int          `json:"serial"`
+	InfoType       string       `json:"info_type"`
+	ReportDateTime time.Time    `json:"report_date_time"`
+	SourcePath     string       `json:"source_path"`
+	AdvisoryPath   string       `json:"advisory_path"` // アーカイブのディレクトリからの相対パス
+	GeoJSONPath    string       `json:"geojson_path"`  // アーカイブのディレクトリからの相対パス
+	ValidFrom      time.Time    `json:"valid_from"`    // 最初の実況・予報の時刻
+	ValidTo        time.Time    `json:"valid_to"`      // 最後の予報の時刻
+	Bounds         MapBounds    `json:"bounds"`        // 全ての実況・予報の暴風域・予報円などが入る範囲
+	Fixes          []ArchiveFix `json:"fixes"`         // 時刻順
+}
+
+// 索引に入れる実況・予報の1時刻分
+type ArchiveFix struct {
+	ValidTime time.Time `json:"valid_time"`
+	LeadHours int       `json:"lead_hours"`
+	Bounds    MapBounds `json:"bounds"` // 中心と暴風域・予報円などが入る範囲
+}
+
+// アーカイブの検索条件
+// 空・ゼロ値・nilの条件は使わない
+type ArchiveQuery struct {
+	EventID   string
+	Serial    int
+	ValidTime *time.Time // この時刻の実況・予報を含む電文
+	Bounds    *MapBounds // この範囲にかかる電文 (ValidTimeがあればその時刻の範囲で判定する)
+}
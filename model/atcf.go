@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// ATCF(JTWC・NHC)のa-deck・b-deckの1行
+// 同じ時刻・予報時間でも34・50・64ktの半径ごとに行が分かれる
+type ATCFRecord struct {
+	Basin         string    `json:"basin"` // WP・EPなど
+	CycloneNumber int       `json:"cyclone_number"`
+	InitTime      time.Time `json:"init_time"`
+	Tech          string    `json:"tech"` // 予報の種類 (JTWC・OFCL、b-deckはBEST)
+	Tau           int       `json:"tau"`  // 何時間後
+	Point         Point     `json:"point"`
+	MaxWind       int       `json:"max_wind"`    // kt
+	Pressure      int       `json:"pressure"`    // hPa
+	Class         string    `json:"class"`       // TD・TS・TYなど
+	RadiusWind    int       `json:"radius_wind"` // 34・50・64kt (無ければ0)
+	WindCode      string    `json:"wind_code"`   // AAA(全周)・NEQ(象限ごと)
+	Radii         [4]int    `json:"radii"`       // 北東・南東・南西・北西の半径(nm)
+	StormName     string    `json:"storm_name"`
+}
@@ -0,0 +1,30 @@
+package model
+
+// 拠点 (倉庫・工場など)
+type Site struct {
+	Name     string  `json:"name"`
+	Category string  `json:"category"` // 無ければ空
+	Point    Point   `json:"point"`
+	Value    float64 `json:"value"` // 資産額など (無ければ0)
+}
+
+// 1電文分の予報円の範囲・暴風警戒域や強風域の通過範囲を、何時間後の範囲ごとに分けたもの
+type ProductArea struct {
+	Product  string      `json:"product"`   // forecast_cone・storm_warning_swath・gale_swath
+	LeadFrom int         `json:"lead_from"` // 何時間後から
+	LeadTo   int         `json:"lead_to"`   // 何時間後まで
+	Polygons [][][]Point `json:"polygons"`  // ポリゴンごとに外周・穴の順
+}
+
+// 範囲に入る人口・拠点
+type ExposureReport struct {
+	EventID    string   `json:"event_id"`
+	Serial     int      `json:"serial"`
+	Product    string   `json:"product"`
+	LeadFrom   int      `json:"lead_from"`
+	LeadTo     int      `json:"lead_to"`
+	Area       float64  `json:"area"`       // km2
+	Population float64  `json:"population"` // 人口の格子が無ければ0
+	Sites      []string `json:"sites"`      // 範囲に入る拠点の名前
+	SiteValue  float64  `json:"site_value"` // 範囲に入る拠点のValueの合計
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type LinearRing struct {
 	Coordinates []Point
 }
@@ -20,6 +22,9 @@ type StormArea struct {
 	CircleLongRadius     float64
 	CircleShortDirection float64
 	CircleShortRadius    float64
+	QuadrantRadii        [4]float64 // 北東・南東・南西・北西の半径(km)。全て0なら長い方・短い方の半径の円
+	ValidTime            time.Time
+	LeadHours            int
 }
 
 type ForecastCircle struct {
@@ -28,6 +33,8 @@ type ForecastCircle struct {
 	CircleLongRadius     float64
 	CircleShortDirection float64
 	CircleShortRadius    float64
+	ValidTime            time.Time
+	LeadHours            int
 }
 
 type ForecastCirclePolygons struct {
@@ -43,6 +50,7 @@ type TyphoonWarningArea struct {
 	CircleLongRadius     int    `json:"circle_long_radius"`
 	CircleShortDirection string `json:"circle_short_direction"`
 	CircleShortRadius    int    `json:"circle_short_radius"`
+	QuadrantRadii        []int  `json:"quadrant_radii,omitempty"` // 北東・南東・南西・北西の半径(km) (ATCFなど、象限ごとに発表される場合)
 }
 
 type Typhoon struct {
@@ -56,5 +64,30 @@ type Typhoon struct {
 	CentralPressure           int                  `json:"central_pressure"`
 	MaxWindSpeedNearTheCenter int                  `json:"max_wind_speed_near_the_center"`
 	InstantaneousMaxWindSpeed int                  `json:"instantaneous_max_wind_speed"`
+	TyphoonClass              string               `json:"typhoon_class"`   // 例: 台風(TY)
+	AreaClass                 string               `json:"area_class"`      // 例: 大型
+	IntensityClass            string               `json:"intensity_class"` // 例: 非常に強い
 	WarningAreas              []TyphoonWarningArea `json:"warning_areas"`
+	// TargetTimestamp・TargetTimestampTypeをパースしたもの (usecase.NormalizeTyphoonsで埋める)
+	ValidTime time.Time `json:"valid_time"`
+	FixKind   FixKind   `json:"fix_kind"`
+	LeadHours int       `json:"lead_hours"` // 実況は0、推定　１時間後は1、予報　１２０時間後は120
+}
+
+// 中心位置の種別
+type FixKind string
+
+const (
+	FixKindAnalysis FixKind = "analysis" // 実況
+	FixKindEstimate FixKind = "estimate" // 推定　１時間後
+	FixKindForecast FixKind = "forecast" // 予報　ｎ時間後
+)
+
+// 電文から暴風域・予報円を作るときのオプション
+type ProductOptions struct {
+	StartFromEstimate bool // 実況ではなく推定(1時間後)の位置から描く
+	EstimatedPosition bool // 推定の位置と暴風域・強風域を別のfeatureとして入れる
+	MinLeadHours      int  // 何時間後の予報から使うか
+	MaxLeadHours      int  // 何時間後の予報まで使うか
+	HasMaxLeadHours   bool // MaxLeadHoursで制限するか (falseなら制限なし)
 }
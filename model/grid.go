@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// 緯度経度の等間隔の格子
+type Grid struct {
+	MinLatitude  float64     // 一番南の格子の南端
+	MinLongitude float64     // 一番西の格子の西端
+	CellSize     float64     // 格子の大きさ(度)
+	Rows         int         // 南北の数
+	Cols         int         // 東西の数
+	Values       [][]float64 // Values[row][col] (rowは南から、colは西から)
+}
+
+// 格子の値がLevel以上の範囲
+type GridContour struct {
+	Level    float64
+	Polygons [][][]Point // ポリゴンごとに外周・穴の順
+}
+
+// 予報円から中心位置・暴風の確率を求めるときのオプション
+type ProbabilityOptions struct {
+	CellSize     float64       // 格子の大きさ(度)
+	StrikeRadius float64       // 中心がこの距離(km)以内を通る確率を求める
+	WindSpeed    int           // この風速(m/s)以上の風が吹く確率を求める
+	Samples      int           // 中心位置の誤差をずらして試す回数
+	Step         time.Duration // 予報時刻の間を補間する間隔
+	Seed         int64         // 乱数の種 (同じなら同じ結果になる)
+}
@@ -0,0 +1,37 @@
+package model
+
+// 地図画像の描き方
+type MapStyle struct {
+	Fill        string  // "#rrggbb" (空なら塗らない)
+	FillOpacity float64 // 0〜1
+	Stroke      string  // "#rrggbb" (空なら線を描かない)
+	StrokeWidth float64 // px
+	PointRadius float64 // px
+}
+
+// 地図画像の1レイヤー
+type MapLayer struct {
+	Name     string
+	Style    MapStyle
+	Polygons [][]Point
+	Lines    [][]Point
+	Points   []Point
+}
+
+// 地図画像に描く範囲
+type MapBounds struct {
+	MinLatitude  float64
+	MaxLatitude  float64
+	MinLongitude float64
+	MaxLongitude float64
+}
+
+// 地図画像1枚分
+type MapScene struct {
+	Title      []string // 左上に書く行 (PNGはASCIIのみ描ける)
+	Width      int
+	Height     int
+	Projection string // "equirectangular" か "mercator"
+	Bounds     MapBounds
+	Layers     []MapLayer // 先頭から順に描く
+}
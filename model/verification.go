@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// 1つの予報を実況(ベストトラック)と比べた結果
+type ForecastVerification struct {
+	EventID        string    `json:"event_id"`
+	Serial         int       `json:"serial"`
+	BaseTime       time.Time `json:"base_time"` // 予報の元になった実況の時刻
+	LeadHours      int       `json:"lead_hours"`
+	ValidTime      time.Time `json:"valid_time"`
+	ForecastPoint  Point     `json:"forecast_point"`
+	ObservedPoint  Point     `json:"observed_point"`
+	TrackError     float64   `json:"track_error"`      // 予報位置と実況の距離(km)
+	CircleRadius   float64   `json:"circle_radius"`    // 予報円の半径(km、予報円が無ければ0)
+	InsideCircle   bool      `json:"inside_circle"`    // 実況の中心が予報円に入ったか
+	PressureError  int       `json:"pressure_error"`   // 予報-実況(hPa)
+	WindSpeedError int       `json:"wind_speed_error"` // 予報-実況(m/s)
+	HasWindSpeed   bool      `json:"has_wind_speed"`   // 予報・実況の両方に最大風速があったか
+}
+
+// 台風・年ごと、何時間後ごとの集計
+type VerificationSummary struct {
+	Group                 string  `json:"group"` // EventIDや年
+	LeadHours             int     `json:"lead_hours"`
+	Count                 int     `json:"count"`
+	MeanTrackError        float64 `json:"mean_track_error"`
+	MedianTrackError      float64 `json:"median_track_error"`
+	CircleCount           int     `json:"circle_count"` // 予報円のあった予報の数
+	HitCount              int     `json:"hit_count"`    // 実況が予報円に入った数
+	HitRate               float64 `json:"hit_rate"`     // %
+	MeanPressureError     float64 `json:"mean_pressure_error"`
+	MeanAbsPressureError  float64 `json:"mean_abs_pressure_error"`
+	WindSpeedCount        int     `json:"wind_speed_count"` // 最大風速を比べられた数
+	MeanWindSpeedError    float64 `json:"mean_wind_speed_error"`
+	MeanAbsWindSpeedError float64 `json:"mean_abs_wind_speed_error"`
+}
+
+// 予報円を確率pの円に縮めた・広げたときに、実況の中心が入った割合
+// pが0.7のときは予報円そのもの
+type CalibrationBin struct {
+	Group       string  `json:"group"` // EventIDや"all"
+	LeadHours   int     `json:"lead_hours"`
+	Probability float64 `json:"probability"` // 円に入るはずの確率
+	Count       int     `json:"count"`
+	HitCount    int     `json:"hit_count"`
+	HitRate     float64 `json:"hit_rate"`    // 実際に入った割合
+	LowerBound  float64 `json:"lower_bound"` // HitRateの95%信頼区間(Wilson)
+	UpperBound  float64 `json:"upper_bound"`
+}
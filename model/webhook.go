@@ -0,0 +1,30 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 新しい電文を知らせる送り先
+type WebhookEndpoint struct {
+	URL    string `json:"url"`
+	Format string `json:"format"` // slack・generic (省略時はgeneric)
+	Secret string `json:"secret"` // HMAC-SHA256で署名する鍵 (省略時は署名しない)
+}
+
+const (
+	WebhookFormatSlack   = "slack"
+	WebhookFormatGeneric = "generic"
+)
+
+// genericの送り先に送る内容
+type AdvisoryNotification struct {
+	EventID        string          `json:"event_id"`
+	TyphoonName    string          `json:"typhoon_name"`
+	Serial         int             `json:"serial"`
+	InfoType       string          `json:"info_type"`
+	ReportDateTime time.Time       `json:"report_date_time"`
+	SourcePath     string          `json:"source_path"`
+	Summary        string          `json:"summary"`
+	GeoJSON        json.RawMessage `json:"geojson"` // 取消の場合はnull
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strconv"
+	"strings"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// 暴風域・予報円の描き方に関するフラグを登録する
+// 返り値の関数はflags.Parseの後に呼ぶ
+func addProductFlags(flags *flag.FlagSet) func() model.ProductOptions {
+	startFromEstimate := flags.Bool("estimate", false, "実況ではなく推定(1時間後)の位置から暴風域・予報円を描く")
+	estimatedPosition := flags.Bool("estimated-position", false, "推定(1時間後)の位置と暴風域・強風域を別のfeatureとして書き出す")
+	leadRange := flags.String("lead", "", "使う予報の範囲 (例: 0-72, 24-120。省略時は全て)")
+
+	return func() model.ProductOptions {
+		options := model.ProductOptions{StartFromEstimate: *startFromEstimate, EstimatedPosition: *estimatedPosition}
+		if *leadRange != "" {
+			minLeadHours, maxLeadHours, err := usecase.ParseLeadRange(*leadRange)
+			if err != nil {
+				log.Fatal(err)
+			}
+			options.MinLeadHours = minLeadHours
+			options.MaxLeadHours = maxLeadHours
+			options.HasMaxLeadHours = true
+		}
+		return options
+	}
+}
+
+// "24,72,120"のような何時間後のリストをパースする
+func parseHorizons(s string) []int {
+	horizons := []int{}
+	for _, v := range strings.Split(s, ",") {
+		horizon, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || horizon < 0 {
+			log.Fatalf("無効な予報時間: %s", v)
+		}
+		horizons = append(horizons, horizon)
+	}
+	return horizons
+}
+
+// "10,50,90"のような値のリストをパースする
+func parseLevels(s string) []float64 {
+	levels := []float64{}
+	for _, v := range strings.Split(s, ",") {
+		level, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			log.Fatalf("無効な値: %s", v)
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// 新しい電文を知らせる送り先のフラグを登録する
+// 返り値の関数はflags.Parseの後に呼び、送り先が無ければnilを返す
+func addWebhookFlags(flags *flag.FlagSet) func() *service.Notifier {
+	configPath := flags.String("webhooks", "", "送り先の一覧のJSONのパス ([{\"url\":...,\"format\":\"slack|generic\",\"secret\":...}])")
+	genericURL := flags.String("webhook", "", "要約とGeoJSONをPOSTするURL")
+	slackURL := flags.String("slack", "", "要約を送るSlackのIncoming WebhookのURL")
+	secret := flags.String("webhook-secret", "", "-webhookの本文をHMAC-SHA256で署名する鍵")
+	retries := flags.Int("webhook-retries", 3, "送れなかった場合にやり直す回数")
+
+	return func() *service.Notifier {
+		endpoints := []model.WebhookEndpoint{}
+		if *configPath != "" {
+			configured, err := usecase.ReadWebhookEndpoints(*configPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			endpoints = append(endpoints, configured...)
+		}
+		if *genericURL != "" {
+			endpoints = append(endpoints, model.WebhookEndpoint{URL: *genericURL, Format: model.WebhookFormatGeneric, Secret: *secret})
+		}
+		if *slackURL != "" {
+			endpoints = append(endpoints, model.WebhookEndpoint{URL: *slackURL, Format: model.WebhookFormatSlack})
+		}
+		if len(endpoints) == 0 {
+			return nil
+		}
+		notifier := service.NewNotifier(endpoints)
+		notifier.Retries = *retries
+		return notifier
+	}
+}
+
+// "24:90,48:160"のような何時間後ごとの予報円の半径(km)をパースする
+func parseConeRadii(s string) map[int]int {
+	coneRadii := map[int]int{}
+	for _, v := range strings.Split(s, ",") {
+		tau, radius, ok := strings.Cut(strings.TrimSpace(v), ":")
+		hours, err1 := strconv.Atoi(tau)
+		km, err2 := strconv.Atoi(radius)
+		if !ok || err1 != nil || err2 != nil || hours <= 0 || km < 0 {
+			log.Fatalf("無効な予報円の半径: %s", v)
+		}
+		coneRadii[hours] = km
+	}
+	return coneRadii
+}
@@ -43,6 +43,10 @@ class TyphoonCircleForecastDetail(TypedDict):
     # 風の情報
     max_wind_speed_near_the_center: int
     instantaneous_max_wind_speed: int
+    # 階級
+    typhoon_class: str
+    area_class: str
+    intensity_class: str
     # WARNINGエリア
     warning_areas: List[WarningArea]
 
@@ -110,6 +114,20 @@ def parse_xml(xml_path: str):
             else "0"
         )
 
+        # 階級
+        class_part = meteorological_info.find("ClassPart")
+        typhoon_class, area_class, intensity_class = "", "", ""
+        if class_part is not None:
+            typhoon_class_elem = class_part.find("jmx_eb:TyphoonClass")
+            area_class_elem = class_part.find("jmx_eb:AreaClass")
+            intensity_class_elem = class_part.find("jmx_eb:IntensityClass")
+            if typhoon_class_elem is not None:
+                typhoon_class = typhoon_class_elem.text.strip()
+            if area_class_elem is not None:
+                area_class = area_class_elem.text.strip()
+            if intensity_class_elem is not None:
+                intensity_class = intensity_class_elem.text.strip()
+
         # WARNINGエリア
         warning_areas: List[WarningArea] = []
         warning_area_parts = meteorological_info.find_all(
@@ -171,6 +189,10 @@ def parse_xml(xml_path: str):
             # 風の情報
             max_wind_speed_near_the_center=int(max_wind_speed_near_the_center),
             instantaneous_max_wind_speed=int(instantaneous_max_wind_speed),
+            # 階級
+            typhoon_class=typhoon_class,
+            area_class=area_class,
+            intensity_class=intensity_class,
             warning_areas=warning_areas,
         )
         details.append(detail)
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// 気象庁のAtomフィードを定期的に読み、新しい台風解析・予報情報を取り込む
+func runPoll(args []string) {
+	flags := flag.NewFlagSet("poll", flag.ExitOnError)
+	feedURL := flags.String("feed", "https://www.data.jma.go.jp/developer/xml/feed/extra.xml", "AtomフィードのURL")
+	interval := flags.Duration("interval", time.Minute, "フィードを読む間隔")
+	once := flags.Bool("once", false, "1回だけ読んで終了する")
+	archiveDir := flags.String("archive", "", "取り込んだ電文を貯めるアーカイブのディレクトリ (省略時は貯めない)")
+	productOptions := addProductFlags(flags)
+	webhookNotifier := addWebhookFlags(flags)
+	flags.Parse(args)
+
+	poller := service.NewPoller(*feedURL)
+	poller.ProductOptions = productOptions()
+	poller.Notifier = webhookNotifier()
+	if *archiveDir != "" {
+		archive, err := usecase.OpenArchive(*archiveDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer archive.Close()
+		poller.Archive = archive
+	}
+
+	for {
+		advisories, err := poller.PollOnce()
+		for _, advisory := range advisories {
+			fmt.Printf("%s 第%d報 (%s) を取り込みました: %s\n", advisory.EventID, advisory.Serial, advisory.InfoType, advisory.SourcePath)
+		}
+		if err != nil {
+			fmt.Println("Error polling feed:", err)
+		}
+		if *once {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// 指定した台風の電文の予報円から、中心が近くを通る確率・暴風の吹く確率を格子とポリゴンで書き出す
+func runProbability(args []string) {
+	flags := flag.NewFlagSet("probability", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	serial := flags.Int("serial", 0, "使う電文の報数 (省略時は最新)")
+	cellSize := flags.Float64("cell", 0.25, "格子の大きさ(度)")
+	strikeRadius := flags.Float64("radius", 100, "中心がこの距離(km)以内を通る確率を求める")
+	windSpeed := flags.Int("wind", 25, "この風速(m/s)以上の風が吹く確率を求める")
+	samples := flags.Int("samples", 1000, "中心位置の誤差をずらして試す回数")
+	step := flags.Duration("step", time.Hour, "予報時刻の間を補間する間隔")
+	seed := flags.Int64("seed", 1, "乱数の種")
+	levels := flags.String("levels", "10,30,50,70,90", "ポリゴンにする確率(%)")
+	output := flags.String("o", "", "書き出すファイルのパス(拡張子なし) (省略時は<EventID>_<報数>)")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	advisory := selectAdvisory(storm, *serial)
+
+	options := model.ProbabilityOptions{
+		CellSize:     *cellSize,
+		StrikeRadius: *strikeRadius,
+		WindSpeed:    *windSpeed,
+		Samples:      *samples,
+		Step:         *step,
+		Seed:         *seed,
+	}
+	contourLevels := parseLevels(*levels)
+	strikeGrid, windGrid := usecase.CalcProbabilityGrids(advisory.Typhoons, options, productOptions())
+
+	basePath := *output
+	if basePath == "" {
+		basePath = filepath.Join("./geojson", fmt.Sprintf("%s_%d", storm.EventID, advisory.Serial))
+	}
+
+	for _, v := range []struct {
+		name            string
+		probabilityType string
+		grid            model.Grid
+	}{
+		{"strike", "strike_probability", strikeGrid},
+		{"wind", "wind_probability", windGrid},
+	} {
+		path := basePath + "_" + v.name
+		if err := usecase.SaveGridASCII(path+".asc", v.grid); err != nil {
+			log.Fatal(err)
+		}
+		if err := usecase.SaveGridCSV(path+".csv", v.grid, "probability"); err != nil {
+			log.Fatal(err)
+		}
+		contours := service.CalcGridContours(v.grid, contourLevels)
+		featureCollection := service.MakeProbabilityContourFeatureCollection(storm.EventID, v.probabilityType, contours)
+		if err := usecase.SaveFeatureCollectionToFile(path+".geojson", featureCollection); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s 第%d報の確率を %s.asc・.csv・.geojson に書き出しました\n", storm.EventID, advisory.Serial, path)
+	}
+}
+
+// ESRIのASCII Gridの値がそれぞれのlevel以上の範囲をGeoJSONに書き出す
+func runContour(args []string) {
+	flags := flag.NewFlagSet("contour", flag.ExitOnError)
+	gridPath := flags.String("grid", "", "ESRIのASCII Grid(.asc)のパス")
+	levels := flags.String("levels", "10,30,50,70,90", "ポリゴンにする値")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は.ascを.geojsonにしたもの)")
+	flags.Parse(args)
+
+	if *gridPath == "" {
+		log.Fatal("-grid を指定してください")
+	}
+	grid, err := usecase.ReadGridASCII(*gridPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	savePath := *output
+	if savePath == "" {
+		savePath = strings.TrimSuffix(*gridPath, filepath.Ext(*gridPath)) + ".geojson"
+	}
+	contours := service.CalcGridContours(grid, parseLevels(*levels))
+	if err := usecase.SaveFeatureCollectionToFile(savePath, service.MakeGridContourFeatureCollection(contours)); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%sの範囲を %s に書き出しました\n", *gridPath, savePath)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// 地図画像を描くフラグを登録する
+// 返り値の関数はflags.Parseの後に呼ぶ
+func addMapFlags(flags *flag.FlagSet) func() ([][]model.Point, int, string) {
+	coastlinePath := flags.String("coastline", "", "海岸線のGeoJSON (Natural Earthのne_50m_coastline.geojsonなど。noneで海岸線なし)")
+	width := flags.Int("width", 800, "画像の幅(px)")
+	projection := flags.String("projection", "equirectangular", "図法 (equirectangular か mercator)")
+
+	return func() ([][]model.Point, int, string) {
+		if *projection != "equirectangular" && *projection != "mercator" {
+			log.Fatalf("不明な図法: %s", *projection)
+		}
+		// 海岸線のファイルはリポジトリに含めていないので、指定が無ければ止める
+		// 海岸線なしで台風の情報だけを描く場合は none を指定する
+		switch *coastlinePath {
+		case "":
+			log.Fatal("-coastline に海岸線のGeoJSON(Natural Earthのne_50m_coastline.geojsonなど)を指定してください (海岸線なしで描く場合は -coastline none)")
+		case "none":
+			return [][]model.Point{}, *width, *projection
+		}
+		coastlines, err := usecase.ReadCoastlines(*coastlinePath)
+		if err != nil {
+			log.Fatalf("海岸線を読み込めませんでした: %v", err)
+		}
+		return coastlines, *width, *projection
+	}
+}
+
+// 指定した台風の電文の実況経路・予報円・暴風域をPNG・SVGの地図画像に書き出す
+func runRender(args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	serial := flags.Int("serial", 0, "描く電文の報数 (省略時は最新)")
+	output := flags.String("o", "", "書き出す画像のパス(拡張子なし) (省略時は<EventID>_<報数>)")
+	format := flags.String("format", "png", "画像の形式 (png・svg・both)")
+	productOptions := addProductFlags(flags)
+	mapOptions := addMapFlags(flags)
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	advisory := selectAdvisory(storm, *serial)
+
+	coastlines, width, projection := mapOptions()
+	scene := service.MakeAdvisoryMapScene(storm, advisory, coastlines, width, 0, projection, productOptions())
+
+	basePath := *output
+	if basePath == "" {
+		basePath = filepath.Join("./geojson", fmt.Sprintf("%s_%d", storm.EventID, advisory.Serial))
+	}
+
+	savePaths := []string{}
+	if *format == "png" || *format == "both" {
+		if err := usecase.SaveMapPNG(basePath+".png", scene); err != nil {
+			log.Fatal(err)
+		}
+		savePaths = append(savePaths, basePath+".png")
+	}
+	if *format == "svg" || *format == "both" {
+		if err := usecase.SaveMapSVG(basePath+".svg", scene); err != nil {
+			log.Fatal(err)
+		}
+		savePaths = append(savePaths, basePath+".svg")
+	}
+	if len(savePaths) == 0 {
+		log.Fatalf("不明な画像の形式: %s", *format)
+	}
+
+	for _, savePath := range savePaths {
+		fmt.Printf("%s 第%d報の地図画像を %s に書き出しました\n", storm.EventID, advisory.Serial, savePath)
+	}
+}
+
+// 台風の電文ごとの予報円・経路をコマにしてアニメーションGIF・APNGに書き出す
+// -stormでxmlディレクトリから、-jsonでjsonファイル(ファイル名の順)から電文を読む
+func runAnimate(args []string) {
+	flags := flag.NewFlagSet("animate", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	jsonPattern := flags.String("json", "", "-stormの代わりに使うjsonファイルのパターン (例: 'json/*_VPTW61_*.json')")
+	output := flags.String("o", "", "書き出す画像のパス (省略時はgeojson/<EventID>.gif か .png)")
+	format := flags.String("format", "gif", "画像の形式 (gif か apng)")
+	delay := flags.Duration("delay", 500*time.Millisecond, "1コマを表示する時間 (1/100秒単位、655.35秒まで)")
+	productOptions := addProductFlags(flags)
+	mapOptions := addMapFlags(flags)
+	flags.Parse(args)
+
+	var storm *model.Storm
+	var advisories []model.Advisory
+	name := ""
+	if *jsonPattern != "" {
+		jsonFiles, err := filepath.Glob(*jsonPattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(jsonFiles) == 0 {
+			log.Fatalf("%s に一致するjsonファイルがありません", *jsonPattern)
+		}
+		sort.Strings(jsonFiles)
+		for _, path := range jsonFiles {
+			typhoons, err := usecase.ReadTyphoonJSONFile(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			advisories = append(advisories, model.Advisory{SourcePath: path, Typhoons: typhoons})
+		}
+		name = "animation"
+	} else {
+		storm = loadStorm(*xmlDir, *stormQuery)
+		advisories = storm.Advisories
+		name = storm.EventID
+	}
+
+	coastlines, width, projection := mapOptions()
+	scenes := service.MakeAnimationMapScenes(storm, advisories, coastlines, width, projection, productOptions())
+
+	savePath := *output
+	if savePath == "" {
+		// APNGは普通のPNGとしても開けるように拡張子を.pngにする
+		extension := ".gif"
+		if *format == "apng" {
+			extension = ".png"
+		}
+		savePath = filepath.Join("./geojson", name+extension)
+	}
+
+	var err error
+	switch *format {
+	case "gif":
+		err = usecase.SaveMapGIF(savePath, scenes, *delay)
+	case "apng":
+		err = usecase.SaveMapAPNG(savePath, scenes, *delay)
+	default:
+		log.Fatalf("不明な画像の形式: %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%d報分のアニメーションを %s に書き出しました\n", len(scenes), savePath)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 1電文分の台風情報から暴風域・予報円・中心線のGeoJSONを作る
+// 指定があり推定(1時間後)の位置がある場合は、その位置と暴風域・強風域も入れる
+func MakeTyphoonFeatureCollection(typhoons []model.Typhoon, options model.ProductOptions) *geojson.FeatureCollection {
+	stormAreaTimeSeries := usecase.MakeStormAreaTimeSeries(typhoons, options)
+	forecastCircleTimeSeries := usecase.MakeForecastCircleTimeSeries(typhoons, options)
+
+	featureCollection := geojson.NewFeatureCollection()
+
+	// 暴風域のGeoJson追加
+	if len(stormAreaTimeSeries) > 0 {
+		stormAreaBorderPoints := CalcStormAreaPolygon(stormAreaTimeSeries)
+		stormAreaBorderPolygon := usecase.MakeGeojsonPolygon(stormAreaBorderPoints)
+		featureCollection.AddFeature(stormAreaBorderPolygon)
+	}
+
+	// 予報円のGeoJson追加
+	if len(forecastCircleTimeSeries) > 1 {
+		forecastCirclePolygons := CalcForecastCirclePolygons(forecastCircleTimeSeries, options)
+		for _, circle := range forecastCirclePolygons.ForecastCircles {
+			polygon := usecase.MakeGeojsonPolygon(circle)
+			featureCollection.AddFeature(polygon)
+		}
+		if len(forecastCirclePolygons.ForecastCircleBorder) > 0 {
+			forcastCircleBorderPolygon := usecase.MakeGeojsonPolygon(forecastCirclePolygons.ForecastCircleBorder)
+			featureCollection.AddFeature(forcastCircleBorderPolygon)
+		}
+
+		centerLineLineString := usecase.MakeGeojsonLineString(forecastCirclePolygons.CenterLine)
+		featureCollection.AddFeature(centerLineLineString)
+	}
+
+	// 推定位置のGeoJson追加
+	if estimated, ok := usecase.FindEstimatedTyphoon(typhoons); ok && options.EstimatedPosition {
+		for _, feature := range MakeEstimatedPositionFeatures(estimated) {
+			featureCollection.AddFeature(feature)
+		}
+	}
+
+	return featureCollection
+}
+
+// 推定(1時間後)の位置と、その時点の暴風域・強風域
+// 地図上で「現在の推定位置」として表示できるようにpropertiesで区別する
+func MakeEstimatedPositionFeatures(estimated model.Typhoon) []*geojson.Feature {
+	features := []*geojson.Feature{}
+
+	for _, warningArea := range estimated.WarningAreas {
+		if warningArea.CircleLongRadius == 0 {
+			continue
+		}
+		stormArea := usecase.MakeStormArea(estimated, warningArea)
+		polygon := usecase.MakeGeojsonPolygon(usecase.CalcStormAreaPoints(stormArea, 120))
+		polygon.SetProperty("type", "estimated_warning_area")
+		polygon.SetProperty("fix_kind", model.FixKindEstimate)
+		polygon.SetProperty("warning_area_type", warningArea.WarningAreaType)
+		polygon.SetProperty("wind_speed", warningArea.WindSpeed)
+		polygon.SetProperty("target_timestamp", estimated.TargetTimestamp)
+		features = append(features, polygon)
+	}
+
+	point := usecase.MakeGeojsonPoint(model.Point{Latitude: estimated.Latitude, Longitude: estimated.Longitude})
+	point.SetProperty("type", "estimated_position")
+	point.SetProperty("fix_kind", model.FixKindEstimate)
+	point.SetProperty("estimated_current_position", true)
+	point.SetProperty("target_timestamp", estimated.TargetTimestamp)
+	point.SetProperty("target_timestamp_type", estimated.TargetTimestampType)
+	point.SetProperty("central_pressure", estimated.CentralPressure)
+	point.SetProperty("max_wind_speed_near_the_center", estimated.MaxWindSpeedNearTheCenter)
+	features = append(features, point)
+
+	return features
+}
+
+// 1電文分の台風情報をGeoJSONに変換してファイルに保存する
+func SaveTyphoonGeoJSON(savePath string, typhoons []model.Typhoon, options model.ProductOptions) error {
+	featureCollection := MakeTyphoonFeatureCollection(typhoons, options)
+	return usecase.SaveFeatureCollectionToFile(savePath, featureCollection)
+}
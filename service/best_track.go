@@ -0,0 +1,128 @@
+package service
+
+import (
+	"sort"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 電文に含まれていた実況
+type observedTyphoon struct {
+	serial  int
+	typhoon model.Typhoon
+}
+
+// 台風の全ての電文から実況を集めて時刻順に並べる
+// 同じ時刻の実況が複数ある場合は報数の新しい方を使う
+func collectObservedTyphoons(storm *model.Storm) []observedTyphoon {
+	observed := map[string]observedTyphoon{}
+
+	for _, advisory := range storm.Advisories {
+		for _, typhoon := range advisory.Typhoons {
+			if typhoon.FixKind != model.FixKindAnalysis {
+				continue
+			}
+			observed[typhoon.TargetTimestamp] = observedTyphoon{serial: advisory.Serial, typhoon: typhoon}
+		}
+	}
+
+	observedTyphoons := make([]observedTyphoon, 0, len(observed))
+	for _, v := range observed {
+		observedTyphoons = append(observedTyphoons, v)
+	}
+	sort.Slice(observedTyphoons, func(i, j int) bool {
+		return observedTyphoons[i].typhoon.ValidTime.Before(observedTyphoons[j].typhoon.ValidTime)
+	})
+
+	return observedTyphoons
+}
+
+// 台風の全ての電文から実況を集めて経路を作る
+func MakeObservedTrack(storm *model.Storm) model.ObservedTrack {
+	track := model.ObservedTrack{EventID: storm.EventID, Fixes: []model.TrackFix{}}
+
+	observed := collectObservedTyphoons(storm)
+	for _, v := range observed {
+		track.Fixes = append(track.Fixes, makeTrackFix(v.typhoon, v.serial))
+	}
+
+	// 最新の電文の推定位置が最後の実況より新しければ、推定として最後に入れる
+	latest, ok := usecase.LatestAdvisory(storm)
+	if !ok {
+		return track
+	}
+	estimated, ok := usecase.FindEstimatedTyphoon(latest.Typhoons)
+	if ok && (len(observed) == 0 || observed[len(observed)-1].typhoon.ValidTime.Before(estimated.ValidTime)) {
+		track.Fixes = append(track.Fixes, makeTrackFix(estimated, latest.Serial))
+	}
+
+	return track
+}
+
+func makeTrackFix(typhoon model.Typhoon, serial int) model.TrackFix {
+	return model.TrackFix{
+		TargetTimestamp:           typhoon.TargetTimestamp,
+		FixKind:                   typhoon.FixKind,
+		Serial:                    serial,
+		CenterPoint:               model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
+		CentralPressure:           typhoon.CentralPressure,
+		MaxWindSpeedNearTheCenter: typhoon.MaxWindSpeedNearTheCenter,
+		InstantaneousMaxWindSpeed: typhoon.InstantaneousMaxWindSpeed,
+		TyphoonClass:              typhoon.TyphoonClass,
+		AreaClass:                 typhoon.AreaClass,
+		IntensityClass:            typhoon.IntensityClass,
+	}
+}
+
+// 実況経路(LineString)・各実況(Point)に最新の予報経路を重ねたGeoJSONを作る
+func MakeObservedTrackFeatureCollection(track model.ObservedTrack, latest model.Advisory) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	// 実況経路のGeoJson追加
+	if len(track.Fixes) > 1 {
+		trackPoints := make([]model.Point, 0, len(track.Fixes))
+		for _, fix := range track.Fixes {
+			trackPoints = append(trackPoints, fix.CenterPoint)
+		}
+		trackLineString := usecase.MakeGeojsonLineString(trackPoints)
+		trackLineString.SetProperty("type", "observed_track")
+		trackLineString.SetProperty("event_id", track.EventID)
+		featureCollection.AddFeature(trackLineString)
+	}
+
+	// 各実況のGeoJson追加
+	for _, fix := range track.Fixes {
+		fixPoint := usecase.MakeGeojsonPoint(fix.CenterPoint)
+		fixPoint.SetProperty("type", "observed_fix")
+		fixPoint.SetProperty("event_id", track.EventID)
+		fixPoint.SetProperty("fix_kind", fix.FixKind)
+		fixPoint.SetProperty("estimated_current_position", fix.FixKind == model.FixKindEstimate)
+		fixPoint.SetProperty("target_timestamp", fix.TargetTimestamp)
+		fixPoint.SetProperty("serial", fix.Serial)
+		fixPoint.SetProperty("central_pressure", fix.CentralPressure)
+		fixPoint.SetProperty("max_wind_speed_near_the_center", fix.MaxWindSpeedNearTheCenter)
+		fixPoint.SetProperty("instantaneous_max_wind_speed", fix.InstantaneousMaxWindSpeed)
+		fixPoint.SetProperty("typhoon_class", fix.TyphoonClass)
+		fixPoint.SetProperty("area_class", fix.AreaClass)
+		fixPoint.SetProperty("intensity_class", fix.IntensityClass)
+		featureCollection.AddFeature(fixPoint)
+	}
+
+	// 最新の予報経路のGeoJson追加
+	forecastCircleTimeSeries := usecase.MakeForecastCircleTimeSeries(latest.Typhoons, model.ProductOptions{})
+	if len(forecastCircleTimeSeries) > 1 {
+		centerLine := make([]model.Point, 0, len(forecastCircleTimeSeries))
+		for _, v := range forecastCircleTimeSeries {
+			centerLine = append(centerLine, v.CenterPoint)
+		}
+		forecastLineString := usecase.MakeGeojsonLineString(centerLine)
+		forecastLineString.SetProperty("type", "forecast_track")
+		forecastLineString.SetProperty("event_id", track.EventID)
+		forecastLineString.SetProperty("serial", latest.Serial)
+		featureCollection.AddFeature(forecastLineString)
+	}
+
+	return featureCollection
+}
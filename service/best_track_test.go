@@ -0,0 +1,132 @@
+package service
+
+import (
+	"testing"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// xmlディレクトリから台風の電文を読み込む
+func loadTestStorm(t *testing.T, query string) *model.Storm {
+	t.Helper()
+	registry, err := usecase.LoadStormRegistry("../xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storm, err := registry.FindStorm(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storm
+}
+
+// TC2412の150報分の実況が、時刻順に1つずつ経路に入ること
+func TestMakeObservedTrack(t *testing.T) {
+	storm := loadTestStorm(t, "TC2412")
+	track := MakeObservedTrack(storm)
+
+	if track.EventID != "TC2412" {
+		t.Errorf("EventID = %s, want TC2412", track.EventID)
+	}
+	// 最新の第150報には推定が無いので、実況だけになる
+	if len(track.Fixes) != 150 {
+		t.Fatalf("実況の数 = %d, want 150", len(track.Fixes))
+	}
+	for i, fix := range track.Fixes {
+		if fix.FixKind != model.FixKindAnalysis {
+			t.Errorf("%d番目 (%s) = %s, want 実況", i, fix.TargetTimestamp, fix.FixKind)
+		}
+		if i > 0 && fix.TargetTimestamp <= track.Fixes[i-1].TargetTimestamp {
+			t.Errorf("%d番目 %s が1つ前の %s より後でない", i, fix.TargetTimestamp, track.Fixes[i-1].TargetTimestamp)
+		}
+		if i > 0 && fix.Serial <= track.Fixes[i-1].Serial {
+			t.Errorf("%d番目の報数 %d が1つ前の %d より大きくない", i, fix.Serial, track.Fixes[i-1].Serial)
+		}
+	}
+
+	// 第1報の実況 (熱帯低気圧の頃)
+	first := track.Fixes[0]
+	want := model.TrackFix{
+		TargetTimestamp:           "2024-08-21 00:00:00 UTC",
+		FixKind:                   model.FixKindAnalysis,
+		Serial:                    1,
+		CenterPoint:               model.Point{Latitude: 15.9, Longitude: 143.9},
+		CentralPressure:           1008,
+		MaxWindSpeedNearTheCenter: 15,
+		TyphoonClass:              "熱帯低気圧(TD)",
+		AreaClass:                 first.AreaClass,
+		IntensityClass:            first.IntensityClass,
+		InstantaneousMaxWindSpeed: first.InstantaneousMaxWindSpeed,
+	}
+	if first != want {
+		t.Errorf("最初の実況 = %+v, want %+v", first, want)
+	}
+
+	// 発達して台風になり、中心気圧が下がっていく
+	minPressure := first.CentralPressure
+	for _, fix := range track.Fixes {
+		if fix.CentralPressure < minPressure {
+			minPressure = fix.CentralPressure
+		}
+	}
+	if minPressure >= first.CentralPressure {
+		t.Errorf("最低の中心気圧 = %dhPa, 最初の %dhPa より下がっていない", minPressure, first.CentralPressure)
+	}
+}
+
+// 最新の電文に実況より新しい推定があれば、推定として最後に入ること
+func TestMakeObservedTrackEstimate(t *testing.T) {
+	storm := loadTestStorm(t, "TC2412")
+	// 第45報 (2024-08-26 13時の推定がある) までしか届いていないとする
+	partial := &model.Storm{EventID: storm.EventID, Advisories: storm.Advisories[:45]}
+	track := MakeObservedTrack(partial)
+
+	if len(track.Fixes) != 46 {
+		t.Fatalf("経路の点の数 = %d, want 実況45 + 推定1", len(track.Fixes))
+	}
+	for i, fix := range track.Fixes[:45] {
+		if fix.FixKind != model.FixKindAnalysis {
+			t.Errorf("%d番目 (%s) = %s, want 実況", i, fix.TargetTimestamp, fix.FixKind)
+		}
+	}
+	last := track.Fixes[45]
+	if last.FixKind != model.FixKindEstimate || last.Serial != 45 || last.TargetTimestamp != "2024-08-26 13:00:00 UTC" ||
+		last.CentralPressure != 980 || last.CenterPoint != (model.Point{Latitude: 27.7, Longitude: 131.9}) {
+		t.Errorf("最後の点 = %+v, want 第45報の2024-08-26 13時の推定", last)
+	}
+}
+
+// 同じ時刻の実況が複数の電文にある場合は報数の新しい方を使うこと
+func TestMakeObservedTrackDuplicateAnalysis(t *testing.T) {
+	storm := loadTestStorm(t, "TC2412")
+	first := storm.Advisories[0]
+
+	// 第1報と同じ時刻の実況を持つ第2報 (中心気圧だけ違う)
+	second := first
+	second.Serial = 2
+	second.Typhoons = append([]model.Typhoon{}, first.Typhoons...)
+	for i := range second.Typhoons {
+		if second.Typhoons[i].FixKind == model.FixKindAnalysis {
+			second.Typhoons[i].CentralPressure = 1004
+		}
+	}
+
+	track := MakeObservedTrack(&model.Storm{EventID: "TC2412", Advisories: []model.Advisory{first, second}})
+	if len(track.Fixes) != 1 {
+		t.Fatalf("実況の数 = %d, want 1", len(track.Fixes))
+	}
+	if fix := track.Fixes[0]; fix.Serial != 2 || fix.CentralPressure != 1004 {
+		t.Errorf("実況 = 第%d報 %dhPa, want 第2報 1004hPa", fix.Serial, fix.CentralPressure)
+	}
+	if first.Typhoons[0].CentralPressure != 1008 {
+		t.Errorf("元の電文が書き換わっている")
+	}
+}
+
+// 電文が無ければ空の経路になること
+func TestMakeObservedTrackEmpty(t *testing.T) {
+	track := MakeObservedTrack(&model.Storm{EventID: "TC2499"})
+	if track.EventID != "TC2499" || track.Fixes == nil || len(track.Fixes) != 0 {
+		t.Errorf("経路 = %+v, want 空", track)
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"log"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/twpayne/go-geos"
+)
+
+// 格子の値がそれぞれのlevel以上の範囲を、穴のあるポリゴンにする
+// marching squaresで引いた境界を、GEOSで正しいポリゴンに直す (鞍点でリングが接する場合など)
+func CalcGridContours(grid model.Grid, levels []float64) []model.GridContour {
+	contours := []model.GridContour{}
+
+	for _, level := range levels {
+		contour := model.GridContour{Level: level, Polygons: [][][]model.Point{}}
+		if polygons := usecase.ContourGrid(grid, level); len(polygons) > 0 {
+			wkt := usecase.PolygonsWithHolesToWKT(polygons)
+			geom, err := geos.NewGeomFromWKT(wkt)
+			if err != nil {
+				log.Fatalf("CalcGridContours Error: %v", err)
+			}
+			contour.Polygons = geomToPolygonsWithHoles(geom.MakeValid())
+		}
+		contours = append(contours, contour)
+	}
+
+	return contours
+}
+
+// 格子の値の範囲のGeoJSONを作る
+func MakeGridContourFeatureCollection(contours []model.GridContour) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, contour := range contours {
+		for _, rings := range contour.Polygons {
+			polygon := usecase.MakeGeojsonPolygonWithHoles(rings)
+			polygon.SetProperty("type", "grid_contour")
+			polygon.SetProperty("level", contour.Level)
+			featureCollection.AddFeature(polygon)
+		}
+	}
+
+	return featureCollection
+}
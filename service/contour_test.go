@@ -0,0 +1,65 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+func TestCalcGridContours(t *testing.T) {
+	// (10.5, 10.5)から半径6の円に沿って高くなる尾根
+	grid := model.Grid{CellSize: 1, Rows: 21, Cols: 21, Values: make([][]float64, 21)}
+	for row := range grid.Values {
+		grid.Values[row] = make([]float64, 21)
+		for col := range grid.Values[row] {
+			r := math.Hypot(float64(row)-10, float64(col)-10)
+			grid.Values[row][col] = 9 - (r-6)*(r-6)
+		}
+	}
+
+	tests := []struct {
+		level    float64
+		polygons int
+		rings    int // 外周と穴を合わせた数
+	}{
+		{0, 1, 2},
+		{5, 1, 2},
+		{10, 0, 0},
+	}
+
+	contours := CalcGridContours(grid, []float64{0, 5, 10})
+	if len(contours) != len(tests) {
+		t.Fatalf("contours = %d件, want %d件", len(contours), len(tests))
+	}
+	for i, tt := range tests {
+		contour := contours[i]
+		if contour.Level != tt.level {
+			t.Errorf("%d件目のlevel = %g, want %g", i, contour.Level, tt.level)
+		}
+		if len(contour.Polygons) != tt.polygons {
+			t.Errorf("level %g: ポリゴン = %d個, want %d個", tt.level, len(contour.Polygons), tt.polygons)
+			continue
+		}
+		rings := 0
+		for _, polygon := range contour.Polygons {
+			rings += len(polygon)
+		}
+		if rings != tt.rings {
+			t.Errorf("level %g: リング = %d個, want %d個", tt.level, rings, tt.rings)
+		}
+	}
+}
+
+// 鞍点で2つの範囲が角で接する場合も、GEOSで正しいポリゴンに直る
+func TestCalcGridContoursSaddleTouch(t *testing.T) {
+	grid := model.Grid{CellSize: 1, Rows: 2, Cols: 2, Values: [][]float64{{1, 0}, {0, 1}}}
+	contours := CalcGridContours(grid, []float64{0.5})
+	if len(contours[0].Polygons) == 0 {
+		t.Fatal("鞍点の範囲が無い")
+	}
+	for _, polygon := range contours[0].Polygons {
+		if len(polygon[0]) < 4 {
+			t.Errorf("外周が閉じていない: %+v", polygon[0])
+		}
+	}
+}
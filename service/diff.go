@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 同じ台風の2つの電文で、予報円の範囲・予報位置・強さがどう変わったかを求める
+func CalcAdvisoryDiff(from, to model.Advisory) model.AdvisoryDiff {
+	diff := model.AdvisoryDiff{
+		EventID:       to.EventID,
+		FromSerial:    from.Serial,
+		ToSerial:      to.Serial,
+		AddedArea:     [][][]model.Point{},
+		RemovedArea:   [][][]model.Point{},
+		Displacements: []model.TrackDisplacement{},
+	}
+
+	// 予報円の範囲の差分
+	// 新しい予報円の範囲が古い範囲の内側に収まると、外れた部分は穴のあるポリゴンになる
+	fromBorder := calcForecastCircleBorder(from.Typhoons)
+	toBorder := calcForecastCircleBorder(to.Typhoons)
+	switch {
+	case len(fromBorder) > 0 && len(toBorder) > 0:
+		fromGeom := polygonToGeom(fromBorder)
+		toGeom := polygonToGeom(toBorder)
+		diff.AddedArea = geomToPolygonsWithHoles(toGeom.Difference(fromGeom))
+		diff.RemovedArea = geomToPolygonsWithHoles(fromGeom.Difference(toGeom))
+	case len(toBorder) > 0:
+		diff.AddedArea = [][][]model.Point{{toBorder}}
+	case len(fromBorder) > 0:
+		diff.RemovedArea = [][][]model.Point{{fromBorder}}
+	}
+
+	// 新しい電文の時刻ごとに、古い電文の経路を補間して位置と強さを比べる
+	diff.Displacements = usecase.CalcTrackDisplacements(from.Typhoons, to.Typhoons)
+
+	return diff
+}
+
+// 予報円をつなげた範囲 (予報円が無い場合は空配列)
+func calcForecastCircleBorder(typhoons []model.Typhoon) []model.Point {
+	forecastCircleTimeSeries := usecase.MakeForecastCircleTimeSeries(typhoons, model.ProductOptions{})
+	if len(forecastCircleTimeSeries) <= 1 {
+		return []model.Point{}
+	}
+	return CalcForecastCirclePolygons(forecastCircleTimeSeries, model.ProductOptions{}).ForecastCircleBorder
+}
+
+func MakeAdvisoryDiffFeatureCollection(diff model.AdvisoryDiff) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, area := range diff.AddedArea {
+		polygon := usecase.MakeGeojsonPolygonWithHoles(area)
+		polygon.SetProperty("type", "forecast_cone_added")
+		polygon.SetProperty("event_id", diff.EventID)
+		polygon.SetProperty("from_serial", diff.FromSerial)
+		polygon.SetProperty("to_serial", diff.ToSerial)
+		polygon.SetProperty("area", usecase.PolygonWithHolesArea(area))
+		featureCollection.AddFeature(polygon)
+	}
+	for _, area := range diff.RemovedArea {
+		polygon := usecase.MakeGeojsonPolygonWithHoles(area)
+		polygon.SetProperty("type", "forecast_cone_removed")
+		polygon.SetProperty("event_id", diff.EventID)
+		polygon.SetProperty("from_serial", diff.FromSerial)
+		polygon.SetProperty("to_serial", diff.ToSerial)
+		polygon.SetProperty("area", usecase.PolygonWithHolesArea(area))
+		featureCollection.AddFeature(polygon)
+	}
+
+	// 古い予報位置から新しい予報位置への線
+	for _, displacement := range diff.Displacements {
+		lineString := usecase.MakeGeojsonLineString([]model.Point{displacement.FromPoint, displacement.ToPoint})
+		lineString.SetProperty("type", "track_displacement")
+		lineString.SetProperty("event_id", diff.EventID)
+		lineString.SetProperty("from_serial", diff.FromSerial)
+		lineString.SetProperty("to_serial", diff.ToSerial)
+		lineString.SetProperty("target_timestamp", displacement.TargetTimestamp)
+		lineString.SetProperty("target_timestamp_type", displacement.TargetTimestampType)
+		lineString.SetProperty("distance", displacement.Distance)
+		lineString.SetProperty("central_pressure_change", displacement.CentralPressureChange)
+		lineString.SetProperty("max_wind_speed_change", displacement.MaxWindSpeedChange)
+		featureCollection.AddFeature(lineString)
+	}
+
+	return featureCollection
+}
+
+// 差分をテキストにまとめる
+func FormatAdvisoryDiffSummary(diff model.AdvisoryDiff) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s 第%d報 → 第%d報\n", diff.EventID, diff.FromSerial, diff.ToSerial)
+
+	addedArea := 0.0
+	for _, area := range diff.AddedArea {
+		addedArea += usecase.PolygonWithHolesArea(area)
+	}
+	removedArea := 0.0
+	for _, area := range diff.RemovedArea {
+		removedArea += usecase.PolygonWithHolesArea(area)
+	}
+	fmt.Fprintf(&b, "予報円の範囲: 追加 約%.0fkm2 / 削除 約%.0fkm2\n", addedArea, removedArea)
+
+	for _, displacement := range diff.Displacements {
+		fmt.Fprintf(
+			&b,
+			"%s (%s): 位置のずれ %.0fkm, 中心気圧 %+dhPa, 最大風速 %+dm/s\n",
+			displacement.TargetTimestamp,
+			displacement.TargetTimestampType,
+			displacement.Distance,
+			displacement.CentralPressureChange,
+			displacement.MaxWindSpeedChange,
+		)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// 実況と24・48時間後の予報円の電文
+func makeTestDiffAdvisory(serial int, analysis model.Point, forecasts []model.Point, radii []int) model.Advisory {
+	baseTime := time.Date(2024, 8, 28, 0, 0, 0, 0, time.UTC)
+	typhoons := []model.Typhoon{{
+		Latitude:     analysis.Latitude,
+		Longitude:    analysis.Longitude,
+		WarningAreas: []model.TyphoonWarningArea{},
+		ValidTime:    baseTime,
+		FixKind:      model.FixKindAnalysis,
+	}}
+	for i, point := range forecasts {
+		typhoons = append(typhoons, model.Typhoon{
+			Latitude:  point.Latitude,
+			Longitude: point.Longitude,
+			WarningAreas: []model.TyphoonWarningArea{
+				{WarningAreaType: "予報円", CircleLongRadius: radii[i], CircleShortRadius: radii[i]},
+			},
+			ValidTime: baseTime.Add(time.Duration(24*(i+1)) * time.Hour),
+			FixKind:   model.FixKindForecast,
+			LeadHours: 24 * (i + 1),
+		})
+	}
+	return model.Advisory{EventID: "TC2410", Serial: serial, Typhoons: typhoons}
+}
+
+// 新しい予報円の範囲が古い範囲の内側に収まると、外れた部分は穴のあるポリゴンになる
+func TestCalcAdvisoryDiffNestedCone(t *testing.T) {
+	from := makeTestDiffAdvisory(1,
+		model.Point{Latitude: 20, Longitude: 130},
+		[]model.Point{{Latitude: 25, Longitude: 130}, {Latitude: 30, Longitude: 130}},
+		[]int{300, 400},
+	)
+	to := makeTestDiffAdvisory(2,
+		model.Point{Latitude: 22, Longitude: 130},
+		[]model.Point{{Latitude: 25, Longitude: 130}, {Latitude: 28, Longitude: 130}},
+		[]int{100, 150},
+	)
+
+	diff := CalcAdvisoryDiff(from, to)
+	if len(diff.AddedArea) != 0 {
+		t.Errorf("加わった部分 = %d個, want 0個", len(diff.AddedArea))
+	}
+	if len(diff.RemovedArea) != 1 || len(diff.RemovedArea[0]) != 2 {
+		t.Fatalf("外れた部分 = %d個, want 穴が1つのポリゴン1個", len(diff.RemovedArea))
+	}
+
+	fromArea := usecase.PolygonArea(calcForecastCircleBorder(from.Typhoons))
+	toArea := usecase.PolygonArea(calcForecastCircleBorder(to.Typhoons))
+	removedArea := usecase.PolygonWithHolesArea(diff.RemovedArea[0])
+	if math.Abs(removedArea-(fromArea-toArea)) > 0.01*fromArea {
+		t.Errorf("外れた部分の面積 = %.0fkm2, want %.0fkm2", removedArea, fromArea-toArea)
+	}
+}
+
+// 穴のある部分の面積は穴を除いて求める
+func TestAdvisoryDiffWithHoles(t *testing.T) {
+	square := func(minLatitude, maxLatitude, minLongitude, maxLongitude float64) []model.Point {
+		return []model.Point{
+			{Latitude: minLatitude, Longitude: minLongitude},
+			{Latitude: minLatitude, Longitude: maxLongitude},
+			{Latitude: maxLatitude, Longitude: maxLongitude},
+			{Latitude: maxLatitude, Longitude: minLongitude},
+			{Latitude: minLatitude, Longitude: minLongitude},
+		}
+	}
+	outer := square(20, 30, 130, 140)
+	hole := square(24, 26, 134, 136)
+	diff := model.AdvisoryDiff{
+		EventID:       "TC2410",
+		FromSerial:    1,
+		ToSerial:      2,
+		AddedArea:     [][][]model.Point{},
+		RemovedArea:   [][][]model.Point{{outer, hole}},
+		Displacements: []model.TrackDisplacement{},
+	}
+	want := usecase.PolygonArea(outer) - usecase.PolygonArea(hole)
+
+	featureCollection := MakeAdvisoryDiffFeatureCollection(diff)
+	if len(featureCollection.Features) != 1 {
+		t.Fatalf("地物 = %d個, want 1個", len(featureCollection.Features))
+	}
+	feature := featureCollection.Features[0]
+	if rings := feature.Geometry.Polygon; len(rings) != 2 {
+		t.Errorf("リング = %d個, want 外周と穴の2個", len(rings))
+	}
+	if area, _ := feature.PropertyFloat64("area"); area != want {
+		t.Errorf("面積 = %.0fkm2, want %.0fkm2", area, want)
+	}
+
+	if summary := FormatAdvisoryDiffSummary(diff); !strings.Contains(summary, fmt.Sprintf("削除 約%.0fkm2", want)) {
+		t.Errorf("要約 = %s, want 削除 約%.0fkm2", summary, want)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// 1電文分の予報円の範囲・暴風警戒域の通過範囲・強風域の通過範囲を、何時間後の範囲ごとに作る
+// windowsは何時間後の範囲(MinLeadHours・MaxLeadHours)ごとのオプション
+func MakeProductAreas(typhoons []model.Typhoon, windows []model.ProductOptions) []model.ProductArea {
+	areas := []model.ProductArea{}
+
+	for _, windowOptions := range windows {
+		selected := usecase.SelectProductTyphoons(typhoons, windowOptions)
+		if len(selected) == 0 {
+			continue
+		}
+		leadFrom, leadTo := windowOptions.MinLeadHours, selected[len(selected)-1].LeadHours
+
+		// 予報円の範囲
+		forecastCircleTimeSeries := usecase.MakeForecastCircleTimeSeries(typhoons, windowOptions)
+		if len(forecastCircleTimeSeries) > 1 {
+			border := CalcForecastCirclePolygons(forecastCircleTimeSeries, windowOptions).ForecastCircleBorder
+			if len(border) > 0 {
+				areas = append(areas, model.ProductArea{
+					Product:  "forecast_cone",
+					LeadFrom: leadFrom,
+					LeadTo:   leadTo,
+					Polygons: [][][]model.Point{{border}},
+				})
+			}
+		}
+
+		// 暴風域・暴風警戒域の通過範囲
+		if stormAreaTimeSeries := usecase.MakeStormAreaTimeSeries(typhoons, windowOptions); len(stormAreaTimeSeries) > 0 {
+			areas = append(areas, model.ProductArea{
+				Product:  "storm_warning_swath",
+				LeadFrom: leadFrom,
+				LeadTo:   leadTo,
+				Polygons: [][][]model.Point{{CalcStormAreaPolygon(stormAreaTimeSeries)}},
+			})
+		}
+
+		// 強風域の通過範囲 (強風域は実況・推定にしか無い)
+		if galeAreaTimeSeries := usecase.MakeWindAreaTimeSeries(typhoons, 15, windowOptions); len(galeAreaTimeSeries) > 0 {
+			areas = append(areas, model.ProductArea{
+				Product:  "gale_swath",
+				LeadFrom: leadFrom,
+				LeadTo:   leadTo,
+				Polygons: [][][]model.Point{{CalcStormAreaPolygon(galeAreaTimeSeries)}},
+			})
+		}
+	}
+
+	return areas
+}
+
+// それぞれの範囲に入る人口と拠点を求める
+// populationがnilの場合は人口を数えない
+func CalcExposure(advisory model.Advisory, areas []model.ProductArea, population *model.Grid, sites []model.Site) []model.ExposureReport {
+	reports := []model.ExposureReport{}
+
+	for _, area := range areas {
+		report := model.ExposureReport{
+			EventID:  advisory.EventID,
+			Serial:   advisory.Serial,
+			Product:  area.Product,
+			LeadFrom: area.LeadFrom,
+			LeadTo:   area.LeadTo,
+			Sites:    []string{},
+		}
+		for _, rings := range area.Polygons {
+			report.Area += usecase.PolygonWithHolesArea(rings)
+			if population != nil {
+				report.Population += usecase.SumGridInPolygon(*population, rings)
+			}
+		}
+		for _, site := range sites {
+			for _, rings := range area.Polygons {
+				if usecase.PointInPolygon(site.Point, rings) {
+					report.Sites = append(report.Sites, site.Name)
+					report.SiteValue += site.Value
+					break
+				}
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	return reports
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// CSVから読んだ拠点と一様な人口の格子で、範囲ごとの面積・人口・拠点が求まること
+func TestCalcExposure(t *testing.T) {
+	dir := t.TempDir()
+	sitesPath := filepath.Join(dir, "sites.csv")
+	csv := "name,latitude,longitude,category,value\n" +
+		"A,22,132,warehouse,100\n" + // 外周の内側
+		"B,25,135,warehouse,200\n" + // 穴の内側
+		"C,28,138,factory,300\n" + // 外周の内側
+		"D,35,135,factory,400\n" // 外側
+	if err := os.WriteFile(sitesPath, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sites, err := usecase.ReadSitesCSV(sitesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	square := func(minLatitude, maxLatitude, minLongitude, maxLongitude float64) []model.Point {
+		return []model.Point{
+			{Latitude: minLatitude, Longitude: minLongitude},
+			{Latitude: minLatitude, Longitude: maxLongitude},
+			{Latitude: maxLatitude, Longitude: maxLongitude},
+			{Latitude: maxLatitude, Longitude: minLongitude},
+			{Latitude: minLatitude, Longitude: minLongitude},
+		}
+	}
+	outer := square(20, 30, 130, 140)
+	hole := square(24, 26, 134, 136)
+	areas := []model.ProductArea{
+		{Product: "forecast_cone", LeadFrom: 0, LeadTo: 24, Polygons: [][][]model.Point{{outer, hole}}},
+		{Product: "gale_swath", LeadFrom: 0, LeadTo: 24, Polygons: [][][]model.Point{}},
+	}
+
+	// 1度ごとの格子で、どの格子も1000人
+	population := usecase.NewGrid(model.MapBounds{MinLatitude: 10, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 150}, 1)
+	for row := range population.Values {
+		for col := range population.Values[row] {
+			population.Values[row][col] = 1000
+		}
+	}
+
+	advisory := model.Advisory{EventID: "TC2410", Serial: 20}
+	reports := CalcExposure(advisory, areas, &population, sites)
+	if len(reports) != 2 {
+		t.Fatalf("報告の数 = %d, want 2", len(reports))
+	}
+
+	cone := reports[0]
+	if cone.EventID != "TC2410" || cone.Serial != 20 || cone.Product != "forecast_cone" || cone.LeadTo != 24 {
+		t.Errorf("範囲の情報 = %+v", cone)
+	}
+	// 10x10度から2x2度の穴を除いた96個の格子
+	if cone.Population != 96*1000 {
+		t.Errorf("人口 = %v, want %v", cone.Population, 96*1000)
+	}
+	wantArea := usecase.PolygonArea(outer) - usecase.PolygonArea(hole)
+	if math.Abs(cone.Area-wantArea) > 0.1 {
+		t.Errorf("面積 = %.1fkm2, want %.1fkm2", cone.Area, wantArea)
+	}
+	if !reflect.DeepEqual(cone.Sites, []string{"A", "C"}) || cone.SiteValue != 400 {
+		t.Errorf("拠点 = %v (%v), want [A C] (400)", cone.Sites, cone.SiteValue)
+	}
+
+	gale := reports[1]
+	if gale.Area != 0 || gale.Population != 0 || len(gale.Sites) != 0 {
+		t.Errorf("ポリゴンの無い範囲 = %+v, want 空", gale)
+	}
+
+	// 人口の格子が無ければ人口は数えない
+	if reports := CalcExposure(advisory, areas, nil, sites); reports[0].Population != 0 {
+		t.Errorf("格子が無いときの人口 = %v, want 0", reports[0].Population)
+	}
+
+	// 書き出したjsonを読み直して同じになること
+	reportsPath := filepath.Join(dir, "exposure.json")
+	if err := usecase.SaveExposureReports(reportsPath, reports); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(reportsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := []model.ExposureReport{}
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, reports) {
+		t.Errorf("読み直した報告 = %+v, want %+v", read, reports)
+	}
+}
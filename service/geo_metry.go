@@ -9,46 +9,8 @@ import (
 )
 
 func CalcStormAreaPolygon(stormAreaTimeSeries []model.StormArea) []model.Point {
-	stormAreaPairs := [][]model.Point{}
-
-	if len(stormAreaTimeSeries) >= 2 {
-		for i := range stormAreaTimeSeries[:len(stormAreaTimeSeries)-1] {
-			stormAreaPairs = append(
-				stormAreaPairs,
-				usecase.ConvexHull(usecase.ConcatPoints(
-					usecase.CalcTyphoonPoints(
-						stormAreaTimeSeries[i].CenterPoint.Latitude,
-						stormAreaTimeSeries[i].CenterPoint.Longitude,
-						stormAreaTimeSeries[i].CircleLongRadius,
-						stormAreaTimeSeries[i].CircleShortRadius,
-						stormAreaTimeSeries[i].CircleLongDirection,
-						120,
-					),
-					usecase.CalcTyphoonPoints(
-						stormAreaTimeSeries[i+1].CenterPoint.Latitude,
-						stormAreaTimeSeries[i+1].CenterPoint.Longitude,
-						stormAreaTimeSeries[i+1].CircleLongRadius,
-						stormAreaTimeSeries[i+1].CircleShortRadius,
-						stormAreaTimeSeries[i+1].CircleLongDirection,
-						120,
-					),
-				)),
-			)
-		}
-	} else if len(stormAreaTimeSeries) == 1 {
-		// 暴風域が一つしかない場合はそれをそのままstormAreaPairsとする
-		stormAreaPairs = append(
-			stormAreaPairs,
-			usecase.CalcTyphoonPoints(
-				stormAreaTimeSeries[0].CenterPoint.Latitude,
-				stormAreaTimeSeries[0].CenterPoint.Longitude,
-				stormAreaTimeSeries[0].CircleLongRadius,
-				stormAreaTimeSeries[0].CircleShortRadius,
-				stormAreaTimeSeries[0].CircleLongDirection,
-				120,
-			),
-		)
-	} else {
+	stormAreaPairs := calcStormAreaHulls(stormAreaTimeSeries)
+	if len(stormAreaPairs) == 0 {
 		// 暴風域がない場合は空配列を返す
 		return []model.Point{}
 	}
@@ -70,7 +32,35 @@ func CalcStormAreaPolygon(stormAreaTimeSeries []model.StormArea) []model.Point {
 	return bufferedPoints
 }
 
-func CalcForecastCirclePolygons(forecastCircleTimeSeries []model.ForecastCircle) model.ForecastCirclePolygons {
+// 隣り合う時刻の暴風域を凸包でつないだもの
+// i番目はi番目とi+1番目の時刻の暴風域をつないだもの
+func calcStormAreaHulls(stormAreaTimeSeries []model.StormArea) [][]model.Point {
+	stormAreaPairs := [][]model.Point{}
+
+	if len(stormAreaTimeSeries) >= 2 {
+		for i := range stormAreaTimeSeries[:len(stormAreaTimeSeries)-1] {
+			stormAreaPairs = append(
+				stormAreaPairs,
+				usecase.ConvexHull(usecase.ConcatPoints(
+					usecase.CalcStormAreaPoints(stormAreaTimeSeries[i], 120),
+					usecase.CalcStormAreaPoints(stormAreaTimeSeries[i+1], 120),
+				)),
+			)
+		}
+	} else if len(stormAreaTimeSeries) == 1 {
+		// 暴風域が一つしかない場合はそれをそのままstormAreaPairsとする
+		stormAreaPairs = append(
+			stormAreaPairs,
+			usecase.CalcStormAreaPoints(stormAreaTimeSeries[0], 120),
+		)
+	}
+
+	return stormAreaPairs
+}
+
+// optionsで何時間後の範囲を指定すると、その範囲の予報円だけで描く
+func CalcForecastCirclePolygons(forecastCircleTimeSeries []model.ForecastCircle, options model.ProductOptions) model.ForecastCirclePolygons {
+	forecastCircleTimeSeries = usecase.FilterForecastCirclesByLeadRange(forecastCircleTimeSeries, options)
 	forecastCircles := [][]model.Point{}
 	centerLine := []model.Point{}
 
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"log"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	"github.com/twpayne/go-geos"
+)
+
+// []PointのポリゴンをGEOSのジオメトリにする
+func polygonToGeom(points []model.Point) *geos.Geom {
+	wkt := "POLYGON" + usecase.PointsToPolygonWKT(points)
+	geom, err := geos.NewGeomFromWKT(wkt)
+	if err != nil {
+		log.Fatalf("polygonToGeom Error: %v, wkt: %v", err, wkt)
+	}
+	return geom
+}
+
+// GEOSのジオメトリに含まれるポリゴンを、外周・穴の順に並んだリングとして取り出す
+func geomToPolygonsWithHoles(geom *geos.Geom) [][][]model.Point {
+	polygons := [][][]model.Point{}
+	if geom == nil || geom.IsEmpty() {
+		return polygons
+	}
+
+	switch geom.TypeID() {
+	case geos.TypeIDPolygon:
+		rings := [][]model.Point{geomToRing(geom.ExteriorRing())}
+		for i := 0; i < geom.NumInteriorRings(); i++ {
+			rings = append(rings, geomToRing(geom.InteriorRing(i)))
+		}
+		polygons = append(polygons, rings)
+	case geos.TypeIDMultiPolygon, geos.TypeIDGeometryCollection:
+		for i := 0; i < geom.NumGeometries(); i++ {
+			polygons = append(polygons, geomToPolygonsWithHoles(geom.Geometry(i))...)
+		}
+	}
+
+	return polygons
+}
+
+func geomToRing(ring *geos.Geom) []model.Point {
+	coords := ring.CoordSeq().ToCoords()
+	points := make([]model.Point, 0, len(coords))
+	for _, coord := range coords {
+		points = append(points, model.Point{Latitude: coord[1], Longitude: coord[0]})
+	}
+	return points
+}
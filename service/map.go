@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+const mapTitleTimeLayout = "2006-01-02 15:04 UTC"
+
+// 地図画像のレイヤーの描き方
+var (
+	coastlineStyle      = model.MapStyle{Stroke: "#7a7a7a", StrokeWidth: 1}
+	forecastCircleStyle = model.MapStyle{Fill: "#ffffff", FillOpacity: 0.3, Stroke: "#3060c0", StrokeWidth: 1}
+	forecastConeStyle   = model.MapStyle{Fill: "#f0d040", FillOpacity: 0.35, Stroke: "#c09000", StrokeWidth: 1.5}
+	stormSwathStyle     = model.MapStyle{Fill: "#e03030", FillOpacity: 0.4, Stroke: "#b00000", StrokeWidth: 1.5}
+	observedTrackStyle  = model.MapStyle{Stroke: "#202020", StrokeWidth: 2}
+	forecastTrackStyle  = model.MapStyle{Stroke: "#3060c0", StrokeWidth: 1.5}
+	trackFixStyle       = model.MapStyle{Fill: "#202020", PointRadius: 3}
+	forecastFixStyle    = model.MapStyle{Fill: "#3060c0", PointRadius: 3}
+)
+
+// 1電文分の実況経路・予報円・予報円の範囲・暴風域の通過範囲を地図画像にする
+// stormがある場合は、その電文までの実況経路も描く
+// heightが0の場合は描く範囲の縦横比から決める
+func MakeAdvisoryMapScene(
+	storm *model.Storm,
+	advisory model.Advisory,
+	coastlines [][]model.Point,
+	width, height int,
+	projection string,
+	options model.ProductOptions,
+) model.MapScene {
+	layers := []model.MapLayer{}
+	points := []model.Point{}
+
+	// 予報円・予報円の範囲・予報経路
+	forecastCircleTimeSeries := usecase.MakeForecastCircleTimeSeries(advisory.Typhoons, options)
+	var forecastCirclePolygons model.ForecastCirclePolygons
+	if len(forecastCircleTimeSeries) > 1 {
+		forecastCirclePolygons = CalcForecastCirclePolygons(forecastCircleTimeSeries, options)
+	}
+	if len(forecastCirclePolygons.ForecastCircleBorder) > 0 {
+		layers = append(layers, model.MapLayer{
+			Name:     "forecast_cone",
+			Style:    forecastConeStyle,
+			Polygons: [][]model.Point{forecastCirclePolygons.ForecastCircleBorder},
+		})
+		points = append(points, forecastCirclePolygons.ForecastCircleBorder...)
+	}
+	if len(forecastCirclePolygons.ForecastCircles) > 0 {
+		layers = append(layers, model.MapLayer{
+			Name:     "forecast_circle",
+			Style:    forecastCircleStyle,
+			Polygons: forecastCirclePolygons.ForecastCircles,
+		})
+	}
+
+	// 暴風域の通過範囲
+	stormAreaTimeSeries := usecase.MakeStormAreaTimeSeries(advisory.Typhoons, options)
+	if len(stormAreaTimeSeries) > 0 {
+		stormSwath := CalcStormAreaPolygon(stormAreaTimeSeries)
+		layers = append(layers, model.MapLayer{
+			Name:     "storm_swath",
+			Style:    stormSwathStyle,
+			Polygons: [][]model.Point{stormSwath},
+		})
+		points = append(points, stormSwath...)
+	}
+
+	// この電文までの実況経路
+	if storm != nil {
+		observedTrack := []model.Point{}
+		for _, fix := range MakeObservedTrack(truncateStorm(storm, advisory.Serial)).Fixes {
+			observedTrack = append(observedTrack, fix.CenterPoint)
+		}
+		if len(observedTrack) > 0 {
+			layers = append(layers,
+				model.MapLayer{Name: "observed_track", Style: observedTrackStyle, Lines: [][]model.Point{observedTrack}},
+				model.MapLayer{Name: "observed_fix", Style: trackFixStyle, Points: observedTrack},
+			)
+			points = append(points, observedTrack...)
+		}
+	}
+
+	forecastTrack := []model.Point{}
+	for _, typhoon := range usecase.SelectProductTyphoons(advisory.Typhoons, options) {
+		forecastTrack = append(forecastTrack, model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude})
+	}
+	if len(forecastTrack) > 0 {
+		layers = append(layers,
+			model.MapLayer{Name: "forecast_track", Style: forecastTrackStyle, Lines: [][]model.Point{forecastTrack}},
+			model.MapLayer{Name: "forecast_fix", Style: forecastFixStyle, Points: forecastTrack},
+		)
+		points = append(points, forecastTrack...)
+	}
+
+	bounds := usecase.MakeMapBounds(points, 3)
+	if height == 0 {
+		height = usecase.MapHeightForWidth(bounds, width, projection)
+	}
+
+	// 海岸線は一番下に描く
+	layers = append([]model.MapLayer{{Name: "coastline", Style: coastlineStyle, Lines: coastlines}}, layers...)
+
+	return model.MapScene{
+		Title:      MakeAdvisoryMapTitle(advisory),
+		Width:      width,
+		Height:     height,
+		Projection: projection,
+		Bounds:     bounds,
+		Layers:     layers,
+	}
+}
+
+// 電文ごとの地図画像を、アニメーションのコマとして同じ範囲・大きさで作る
+// stormがある場合は、各電文までの実況経路も描く
+func MakeAnimationMapScenes(
+	storm *model.Storm,
+	advisories []model.Advisory,
+	coastlines [][]model.Point,
+	width int,
+	projection string,
+	options model.ProductOptions,
+) []model.MapScene {
+	scenes := []model.MapScene{}
+	for _, advisory := range advisories {
+		scenes = append(scenes, MakeAdvisoryMapScene(storm, advisory, coastlines, width, 0, projection, options))
+	}
+	if len(scenes) == 0 {
+		return scenes
+	}
+
+	// コマごとに範囲が動かないように、全てのコマが入る範囲にそろえる
+	bounds := scenes[0].Bounds
+	for _, scene := range scenes[1:] {
+		bounds = usecase.UnionMapBounds(bounds, scene.Bounds)
+	}
+	height := usecase.MapHeightForWidth(bounds, width, projection)
+	for i := range scenes {
+		scenes[i].Bounds = bounds
+		scenes[i].Height = height
+	}
+
+	return scenes
+}
+
+// 台風名・報数・発表時刻・実況の時刻 (PNGに描けるようにASCIIのみ)
+// jsonから読んだ電文のように分からない項目は省く
+func MakeAdvisoryMapTitle(advisory model.Advisory) []string {
+	name := advisory.EventID
+	if name == "" {
+		name = filepath.Base(advisory.SourcePath)
+	}
+	if advisory.TyphoonName != "" {
+		name += " " + advisory.TyphoonName
+	}
+	if advisory.TyphoonNumber != "" {
+		name += " (T" + advisory.TyphoonNumber + ")"
+	}
+	title := []string{name}
+
+	if advisory.Serial != 0 {
+		serial := fmt.Sprintf("Serial %d", advisory.Serial)
+		if advisory.InfoType == "訂正" {
+			serial += " (correction)"
+		}
+		title = append(title, serial)
+	}
+	if !advisory.ReportDateTime.IsZero() {
+		title = append(title, "Issued "+advisory.ReportDateTime.UTC().Format(mapTitleTimeLayout))
+	}
+	for _, typhoon := range advisory.Typhoons {
+		if typhoon.FixKind == model.FixKindAnalysis {
+			title = append(title, "Analysis "+typhoon.ValidTime.UTC().Format(mapTitleTimeLayout))
+			break
+		}
+	}
+
+	return title
+}
+
+// 指定した報数までの電文だけにした台風
+func truncateStorm(storm *model.Storm, serial int) *model.Storm {
+	truncated := *storm
+	truncated.Advisories = []model.Advisory{}
+	for _, advisory := range storm.Advisories {
+		if advisory.Serial <= serial {
+			truncated.Advisories = append(truncated.Advisories, advisory)
+		}
+	}
+	return &truncated
+}
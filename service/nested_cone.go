@@ -0,0 +1,70 @@
+package service
+
+import (
+	"sort"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 何時間後までかを変えた予報円・暴風警戒域を入れ子にしたGeoJSONを作る
+// 例: horizons=[24, 72, 120]で24時間・3日・5日の予報円
+// optionsで何時間後までかが指定されていれば、それより長いhorizonはその時間までにする
+// 小さい範囲が上に描かれるように、長い予報から順に入れる
+func MakeNestedConeFeatureCollection(typhoons []model.Typhoon, horizons []int, options model.ProductOptions) *geojson.FeatureCollection {
+	clamped := map[int]bool{}
+	for _, horizon := range horizons {
+		if options.HasMaxLeadHours && horizon > options.MaxLeadHours {
+			horizon = options.MaxLeadHours
+		}
+		clamped[horizon] = true
+	}
+	sortedHorizons := []int{}
+	for horizon := range clamped {
+		sortedHorizons = append(sortedHorizons, horizon)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sortedHorizons)))
+
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, horizon := range sortedHorizons {
+		horizonOptions := options
+		horizonOptions.MaxLeadHours = horizon
+		horizonOptions.HasMaxLeadHours = true
+
+		// 暴風警戒域のGeoJson追加
+		stormAreaTimeSeries := usecase.MakeStormAreaTimeSeries(typhoons, horizonOptions)
+		if len(stormAreaTimeSeries) > 0 {
+			stormAreaBorderPolygon := usecase.MakeGeojsonPolygon(CalcStormAreaPolygon(stormAreaTimeSeries))
+			stormAreaBorderPolygon.SetProperty("type", "storm_area_swath")
+			setHorizonProperties(stormAreaBorderPolygon, horizonOptions)
+			featureCollection.AddFeature(stormAreaBorderPolygon)
+		}
+
+		// 予報円のGeoJson追加
+		forecastCircleTimeSeries := usecase.MakeForecastCircleTimeSeries(typhoons, horizonOptions)
+		if len(forecastCircleTimeSeries) <= 1 {
+			continue
+		}
+		forecastCirclePolygons := CalcForecastCirclePolygons(forecastCircleTimeSeries, horizonOptions)
+		if len(forecastCirclePolygons.ForecastCircleBorder) > 0 {
+			forecastConePolygon := usecase.MakeGeojsonPolygon(forecastCirclePolygons.ForecastCircleBorder)
+			forecastConePolygon.SetProperty("type", "forecast_cone")
+			setHorizonProperties(forecastConePolygon, horizonOptions)
+			featureCollection.AddFeature(forecastConePolygon)
+		}
+		centerLineLineString := usecase.MakeGeojsonLineString(forecastCirclePolygons.CenterLine)
+		centerLineLineString.SetProperty("type", "center_line")
+		setHorizonProperties(centerLineLineString, horizonOptions)
+		featureCollection.AddFeature(centerLineLineString)
+	}
+
+	return featureCollection
+}
+
+func setHorizonProperties(feature *geojson.Feature, options model.ProductOptions) {
+	feature.SetProperty("horizon", options.MaxLeadHours)
+	feature.SetProperty("lead_from", options.MinLeadHours)
+	feature.SetProperty("lead_to", options.MaxLeadHours)
+}
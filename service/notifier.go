@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 新しい電文を送り先に知らせる
+// Clientと送り先のURLは差し替えられるので、手元のHTTPサーバでも確認できる
+type Notifier struct {
+	Endpoints []model.WebhookEndpoint
+	Client    *http.Client
+	Retries   int
+	RetryWait time.Duration
+}
+
+func NewNotifier(endpoints []model.WebhookEndpoint) *Notifier {
+	return &Notifier{
+		Endpoints: endpoints,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		Retries:   3,
+		RetryWait: 2 * time.Second,
+	}
+}
+
+// 電文の要約とGeoJSONを全ての送り先に送る
+// featureCollectionがnil(取消など)の場合は要約だけを送る
+// 送れなかった送り先があっても残りには送り、まとめてエラーを返す
+func (n *Notifier) NotifyAdvisory(advisory model.Advisory, featureCollection *geojson.FeatureCollection) error {
+	notification := model.AdvisoryNotification{
+		EventID:        advisory.EventID,
+		TyphoonName:    advisory.TyphoonName,
+		Serial:         advisory.Serial,
+		InfoType:       advisory.InfoType,
+		ReportDateTime: advisory.ReportDateTime,
+		SourcePath:     advisory.SourcePath,
+		Summary:        usecase.MakeAdvisorySummary(advisory),
+		GeoJSON:        json.RawMessage("null"),
+	}
+	if featureCollection != nil {
+		data, err := featureCollection.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		notification.GeoJSON = data
+	}
+
+	errs := []error{}
+	for _, endpoint := range n.Endpoints {
+		body, err := usecase.MakeWebhookBody(endpoint, notification)
+		if err == nil {
+			err = usecase.PostWebhook(n.Client, endpoint, body, n.Retries, n.RetryWait)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// 電文の情報が送り先に届くこと
+func TestNotifyAdvisory(t *testing.T) {
+	received := []model.AdvisoryNotification{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !usecase.VerifyWebhookSignature("s3cret", r.Header.Get("X-Typhoon-Timestamp"), r.Header.Get("X-Typhoon-Signature"), body) {
+			t.Errorf("署名が正しくない")
+		}
+		notification := model.AdvisoryNotification{}
+		if err := json.Unmarshal(body, &notification); err != nil {
+			t.Error(err)
+		}
+		received = append(received, notification)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier([]model.WebhookEndpoint{{URL: server.URL, Format: model.WebhookFormatGeneric, Secret: "s3cret"}})
+	notifier.Client = server.Client()
+	notifier.RetryWait = time.Millisecond
+
+	advisory := model.Advisory{EventID: "TC2410", Serial: 8, InfoType: "取消", ReportDateTime: time.Date(2024, 8, 19, 15, 45, 0, 0, time.UTC)}
+	if err := notifier.NotifyAdvisory(advisory, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("受け取った数 = %d, want 1", len(received))
+	}
+	got := received[0]
+	if got.EventID != "TC2410" || got.Serial != 8 || got.InfoType != "取消" || string(got.GeoJSON) != "null" {
+		t.Errorf("受け取った内容 = %+v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 実況の暴風域(または強風域)をつなげて、台風の一生で実際に通過した範囲を求める
+// warningAreaTypeには"暴風域"か"強風域"を指定する
+func CalcObservedSwaths(storm *model.Storm, warningAreaType string) []model.ObservedSwath {
+	swaths := []model.ObservedSwath{}
+
+	var stormAreaTimeSeries []model.StormArea
+	var swath model.ObservedSwath
+
+	// 円が無くなった時点で区切り、それまでの円をつなげて1つの範囲にする
+	flush := func() {
+		if len(stormAreaTimeSeries) > 0 {
+			swath.Polygon = CalcStormAreaPolygon(stormAreaTimeSeries)
+			swaths = append(swaths, swath)
+		}
+		stormAreaTimeSeries = nil
+	}
+
+	for _, v := range collectObservedTyphoons(storm) {
+		found := false
+		for _, warningArea := range v.typhoon.WarningAreas {
+			if warningArea.WarningAreaType != warningAreaType || warningArea.CircleLongRadius == 0 {
+				continue
+			}
+			if len(stormAreaTimeSeries) == 0 {
+				swath = model.ObservedSwath{
+					EventID:         storm.EventID,
+					WarningAreaType: warningAreaType,
+					WindSpeed:       warningArea.WindSpeed,
+					From:            v.typhoon.TargetTimestamp,
+				}
+			}
+			swath.To = v.typhoon.TargetTimestamp
+			stormAreaTimeSeries = append(stormAreaTimeSeries, usecase.MakeStormArea(v.typhoon, warningArea))
+			found = true
+			break
+		}
+		if !found {
+			flush()
+		}
+	}
+	flush()
+
+	return swaths
+}
+
+func MakeObservedSwathFeatureCollection(swaths []model.ObservedSwath) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, swath := range swaths {
+		polygon := usecase.MakeGeojsonPolygon(swath.Polygon)
+		polygon.SetProperty("type", "observed_swath")
+		polygon.SetProperty("event_id", swath.EventID)
+		polygon.SetProperty("warning_area_type", swath.WarningAreaType)
+		polygon.SetProperty("wind_speed", swath.WindSpeed)
+		polygon.SetProperty("from", swath.From)
+		polygon.SetProperty("to", swath.To)
+		featureCollection.AddFeature(polygon)
+	}
+
+	return featureCollection
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"testing"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// 範囲がwarningAreaTypeの円のある実況の中心をすべて含むこと
+func checkObservedSwathCenters(t *testing.T, swath model.ObservedSwath, storm *model.Storm) {
+	t.Helper()
+	for _, v := range collectObservedTyphoons(storm) {
+		if v.typhoon.TargetTimestamp < swath.From || v.typhoon.TargetTimestamp > swath.To {
+			continue
+		}
+		center := model.Point{Latitude: v.typhoon.Latitude, Longitude: v.typhoon.Longitude}
+		if !usecase.PointInPolygon(center, [][]model.Point{swath.Polygon}) {
+			t.Errorf("%s の中心 %v が %s-%s の範囲に入っていない", v.typhoon.TargetTimestamp, center, swath.From, swath.To)
+		}
+	}
+}
+
+// TC2412の暴風域・強風域は途切れずに続いているので、それぞれ1つの範囲になること
+func TestCalcObservedSwaths(t *testing.T) {
+	storm := loadTestStorm(t, "TC2412")
+
+	cases := []struct {
+		warningAreaType string
+		windSpeed       int
+		from, to        string
+	}{
+		// 第15報で暴風域ができ、第119報で無くなる
+		{"暴風域", 25, "2024-08-22 18:00:00 UTC", "2024-08-29 14:00:00 UTC"},
+		// 第7報で強風域ができ、最後の第150報で無くなる
+		{"強風域", 15, "2024-08-21 18:00:00 UTC", "2024-09-01 00:00:00 UTC"},
+	}
+	for _, c := range cases {
+		swaths := CalcObservedSwaths(storm, c.warningAreaType)
+		if len(swaths) != 1 {
+			t.Errorf("%sの範囲の数 = %d, want 1", c.warningAreaType, len(swaths))
+			continue
+		}
+		swath := swaths[0]
+		if swath.EventID != "TC2412" || swath.WarningAreaType != c.warningAreaType || swath.WindSpeed != c.windSpeed ||
+			swath.From != c.from || swath.To != c.to {
+			t.Errorf("%sの範囲 = %s %s %dm/s %s-%s, want TC2412 %s %dm/s %s-%s", c.warningAreaType,
+				swath.EventID, swath.WarningAreaType, swath.WindSpeed, swath.From, swath.To,
+				c.warningAreaType, c.windSpeed, c.from, c.to)
+		}
+		if len(swath.Polygon) < 4 {
+			t.Errorf("%sの範囲のポリゴンの点の数 = %d", c.warningAreaType, len(swath.Polygon))
+			continue
+		}
+		checkObservedSwathCenters(t, swath, storm)
+	}
+
+	// 強風域は暴風域より広い
+	storm25 := CalcObservedSwaths(storm, "暴風域")
+	storm15 := CalcObservedSwaths(storm, "強風域")
+	if len(storm25) == 1 && len(storm15) == 1 && usecase.PolygonArea(storm25[0].Polygon) >= usecase.PolygonArea(storm15[0].Polygon) {
+		t.Errorf("暴風域の範囲 %.0fkm2 が強風域の範囲 %.0fkm2 より広い", usecase.PolygonArea(storm25[0].Polygon), usecase.PolygonArea(storm15[0].Polygon))
+	}
+}
+
+// 暴風域の無い実況で範囲が途切れ、前後で別の範囲になること
+func TestCalcObservedSwathsGap(t *testing.T) {
+	storm := loadTestStorm(t, "TC2412")
+
+	// 第60報 (2024-08-27 03時) の実況から暴風域を除く
+	advisories := append([]model.Advisory{}, storm.Advisories...)
+	for i, advisory := range advisories {
+		if advisory.Serial != 60 {
+			continue
+		}
+		typhoons := append([]model.Typhoon{}, advisory.Typhoons...)
+		for j, typhoon := range typhoons {
+			if typhoon.FixKind != model.FixKindAnalysis {
+				continue
+			}
+			warningAreas := []model.TyphoonWarningArea{}
+			for _, warningArea := range typhoon.WarningAreas {
+				if warningArea.WarningAreaType != "暴風域" {
+					warningAreas = append(warningAreas, warningArea)
+				}
+			}
+			typhoons[j].WarningAreas = warningAreas
+		}
+		advisories[i].Typhoons = typhoons
+	}
+	gapped := &model.Storm{EventID: storm.EventID, Advisories: advisories}
+
+	swaths := CalcObservedSwaths(gapped, "暴風域")
+	want := [][2]string{
+		{"2024-08-22 18:00:00 UTC", "2024-08-27 02:00:00 UTC"},
+		{"2024-08-27 04:00:00 UTC", "2024-08-29 14:00:00 UTC"},
+	}
+	if len(swaths) != len(want) {
+		t.Fatalf("範囲の数 = %d, want %d", len(swaths), len(want))
+	}
+	for i, w := range want {
+		if swaths[i].From != w[0] || swaths[i].To != w[1] {
+			t.Errorf("%d番目の範囲 = %s-%s, want %s-%s", i, swaths[i].From, swaths[i].To, w[0], w[1])
+		}
+		checkObservedSwathCenters(t, swaths[i], gapped)
+	}
+
+	// 強風域は途切れていないので1つのまま
+	if swaths := CalcObservedSwaths(gapped, "強風域"); len(swaths) != 1 {
+		t.Errorf("強風域の範囲の数 = %d, want 1", len(swaths))
+	}
+	// 元の台風は書き換わっていない
+	if swaths := CalcObservedSwaths(storm, "暴風域"); len(swaths) != 1 {
+		t.Errorf("元の台風の暴風域の範囲の数 = %d, want 1", len(swaths))
+	}
+}
+
+// 暴風域が一度も無い台風(TC2416)は範囲が無いこと
+func TestCalcObservedSwathsNone(t *testing.T) {
+	storm := loadTestStorm(t, "TC2416")
+	if swaths := CalcObservedSwaths(storm, "暴風域"); len(swaths) != 0 {
+		t.Errorf("範囲の数 = %d, want 0", len(swaths))
+	}
+	if swaths := CalcObservedSwaths(storm, "強風域"); len(swaths) != 0 {
+		t.Errorf("強風域の範囲の数 = %d, want 0", len(swaths))
+	}
+}
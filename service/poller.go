@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+)
+
+// 気象庁のAtomフィード(PULL型)を読み、新しい台風解析・予報情報を取り込む
+type Poller struct {
+	FeedURL        string
+	Client         *http.Client
+	XMLDir         string
+	JSONDir        string
+	GeoJSONDir     string
+	IndexPath      string // 台風ごとの最新の電文の一覧
+	AuditLogPath   string // 訂正・取消による差し替えの記録
+	ProductOptions model.ProductOptions
+	Notifier       *Notifier        // 取り込んだ電文を知らせる (nilなら知らせない)
+	Archive        *usecase.Archive // 取り込んだ電文を貯める (nilなら貯めない)
+
+	registry *usecase.StormRegistry
+}
+
+func NewPoller(feedURL string) *Poller {
+	return &Poller{
+		FeedURL:      feedURL,
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		XMLDir:       "./xml",
+		JSONDir:      "./json",
+		GeoJSONDir:   "./geojson",
+		IndexPath:    "./geojson/index.json",
+		AuditLogPath: "./geojson/audit.jsonl",
+	}
+}
+
+// フィードを1回読み、未取得のVPTW60〜62を取得・変換・保存する
+// 取り込んだ電文を古い順に返す
+// 取り込めなかった電文があっても残りは取り込み、エラーはまとめて返す
+func (p *Poller) PollOnce() ([]model.Advisory, error) {
+	if p.registry == nil {
+		registry, err := usecase.LoadStormRegistry(p.XMLDir)
+		if err != nil {
+			return nil, err
+		}
+		p.registry = registry
+	}
+
+	feedData, err := usecase.FetchURL(p.Client, p.FeedURL)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := usecase.ParseAtomFeed(feedData)
+	if err != nil {
+		return nil, fmt.Errorf("フィードのパースに失敗: %v", err)
+	}
+
+	entries := usecase.FilterTyphoonAdvisoryEntries(feed)
+
+	advisories := []model.Advisory{}
+	errs := []error{}
+	// フィードは新しい順に並んでいるので古い方から処理する
+	for i := len(entries) - 1; i >= 0; i-- {
+		xmlURL := entries[i].XMLURL()
+		xmlPath := filepath.Join(p.XMLDir, path.Base(xmlURL))
+		if _, err := os.Stat(xmlPath); err == nil {
+			// 取得済み
+			continue
+		}
+
+		// 壊れた電文や取得できない電文があっても残りの電文の取り込みは続ける
+		// xmlを保存していないので次のPollOnceでもう一度取得する
+		advisory, err := p.ingest(xmlURL, xmlPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s の取り込みに失敗: %v", xmlURL, err))
+			continue
+		}
+		advisories = append(advisories, advisory)
+
+		if entry, ok := p.registry.Add(advisory); ok {
+			if err := p.retract(entry); err != nil {
+				errs = append(errs, fmt.Errorf("%s 第%d報の差し替えに失敗: %v", advisory.EventID, advisory.Serial, err))
+			}
+		}
+
+		// 知らせられなくても・貯められなくても取り込みは続ける
+		// 貯められなかった電文は archive コマンドで後から入れられる
+		if err := p.notify(advisory); err != nil {
+			errs = append(errs, fmt.Errorf("%s 第%d報の通知に失敗: %v", advisory.EventID, advisory.Serial, err))
+		}
+		if err := p.archive(advisory); err != nil {
+			errs = append(errs, fmt.Errorf("%s 第%d報のアーカイブに失敗: %v", advisory.EventID, advisory.Serial, err))
+		}
+	}
+
+	if len(advisories) > 0 {
+		products := usecase.MakeLatestProducts(p.registry, p.GeoJSONDir)
+		if err := usecase.SaveLatestProducts(p.IndexPath, products); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return advisories, errors.Join(errs...)
+}
+
+func (p *Poller) notify(advisory model.Advisory) error {
+	if p.Notifier == nil {
+		return nil
+	}
+	if advisory.InfoType == "取消" {
+		return p.Notifier.NotifyAdvisory(advisory, nil)
+	}
+	return p.Notifier.NotifyAdvisory(advisory, MakeTyphoonFeatureCollection(advisory.Typhoons, p.ProductOptions))
+}
+
+func (p *Poller) archive(advisory model.Advisory) error {
+	if p.Archive == nil {
+		return nil
+	}
+	return p.Archive.Put(advisory, MakeTyphoonFeatureCollection(advisory.Typhoons, p.ProductOptions))
+}
+
+// 訂正・取消で差し替えられた電文の変換結果を取り除き、記録を残す
+// 元のxmlは記録のために残しておく
+func (p *Poller) retract(entry model.AdvisoryAuditEntry) error {
+	if err := usecase.RetractProducts(entry, p.JSONDir, p.GeoJSONDir); err != nil {
+		return err
+	}
+	return usecase.AppendAuditLog(p.AuditLogPath, entry)
+}
+
+func (p *Poller) ingest(xmlURL, xmlPath string) (model.Advisory, error) {
+	data, err := usecase.FetchURL(p.Client, xmlURL)
+	if err != nil {
+		return model.Advisory{}, err
+	}
+	advisory, err := usecase.ParseTyphoonXML(data)
+	if err != nil {
+		return model.Advisory{}, fmt.Errorf("%s: %v", xmlURL, err)
+	}
+	advisory.SourcePath = xmlPath
+
+	baseName := strings.TrimSuffix(filepath.Base(xmlPath), ".xml")
+	jsonPath := filepath.Join(p.JSONDir, baseName+".json")
+	geojsonPath := filepath.Join(p.GeoJSONDir, baseName+".geojson")
+
+	// 取消には変換するものが無い
+	if advisory.InfoType == "取消" {
+		return advisory, os.WriteFile(xmlPath, data, 0644)
+	}
+
+	// 変換に失敗したときに取得済み扱いにならないよう、xmlは最後に保存する
+	if err := usecase.SaveTyphoonJSONFile(jsonPath, advisory.Typhoons); err != nil {
+		return model.Advisory{}, err
+	}
+	if err := SaveTyphoonGeoJSON(geojsonPath, advisory.Typhoons, p.ProductOptions); err != nil {
+		return model.Advisory{}, err
+	}
+	if err := os.WriteFile(xmlPath, data, 0644); err != nil {
+		return model.Advisory{}, err
+	}
+
+	return advisory, nil
+}
+
+// 取り込んだ電文を台風ごとにまとめたもの (まだ読んでいなければnil)
+func (p *Poller) Registry() *usecase.StormRegistry {
+	return p.registry
+}
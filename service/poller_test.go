@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"typhoon-polygon/usecase"
+)
+
+// リポジトリのxmlディレクトリの電文を、フィードと同じように新しい順に並べて配る
+func newFeedServer(t *testing.T, names []string) *httptest.Server {
+	t.Helper()
+	return newFeedServerWithFiles(t, names, nil)
+}
+
+// filesにある名前はxmlディレクトリの代わりにその中身を配る
+func newFeedServerWithFiles(t *testing.T, names []string, files map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="utf-8"?><feed xmlns="http://www.w3.org/2005/Atom"><updated>2024-08-20T00:41:07+09:00</updated>`)
+		// 台風解析・予報情報以外のエントリは読まれない
+		fmt.Fprintf(&b, `<entry><title>府県天気予報</title><id>%s/xml/20240820004107_0_VPFD50_010000.xml</id></entry>`, server.URL)
+		for i := len(names) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, `<entry><title>台風解析・予報情報</title><id>urn:uuid:%d</id><link type="application/xml" href="%s/xml/%s"/></entry>`, i, server.URL, names[i])
+		}
+		b.WriteString(`</feed>`)
+		w.Write([]byte(b.String()))
+	})
+	mux.HandleFunc("/xml/", func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.URL.Path)
+		if data, ok := files[name]; ok {
+			w.Write(data)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("..", "xml", name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	return server
+}
+
+func newTestPoller(t *testing.T, feedURL string) *Poller {
+	t.Helper()
+
+	dir := t.TempDir()
+	poller := NewPoller(feedURL)
+	poller.XMLDir = filepath.Join(dir, "xml")
+	poller.JSONDir = filepath.Join(dir, "json")
+	poller.GeoJSONDir = filepath.Join(dir, "geojson")
+	poller.IndexPath = filepath.Join(dir, "geojson", "index.json")
+	poller.AuditLogPath = filepath.Join(dir, "geojson", "audit.jsonl")
+	for _, d := range []string{poller.XMLDir, poller.JSONDir, poller.GeoJSONDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return poller
+}
+
+func TestPollOnce(t *testing.T) {
+	names := []string{
+		"20240819124216_0_VPTW60_010000.xml",
+		"20240819154223_0_VPTW60_010000.xml",
+		"20240819184131_0_VPTW60_010000.xml",
+	}
+	server := newFeedServer(t, names)
+	poller := newTestPoller(t, server.URL+"/feed.xml")
+
+	advisories, err := poller.PollOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advisories) != len(names) {
+		t.Fatalf("取り込んだ電文 = %d件, want %d件", len(advisories), len(names))
+	}
+	// フィードは新しい順なので、古い順に取り込まれていること
+	for i, advisory := range advisories {
+		if filepath.Base(advisory.SourcePath) != names[i] {
+			t.Errorf("%d件目 = %s, want %s", i, filepath.Base(advisory.SourcePath), names[i])
+		}
+		if i > 0 && advisory.Serial <= advisories[i-1].Serial {
+			t.Errorf("%d件目の報数 %d が前の報数 %d より新しくない", i, advisory.Serial, advisories[i-1].Serial)
+		}
+	}
+
+	for _, name := range names {
+		baseName := strings.TrimSuffix(name, ".xml")
+		for _, path := range []string{
+			filepath.Join(poller.XMLDir, name),
+			filepath.Join(poller.JSONDir, baseName+".json"),
+			filepath.Join(poller.GeoJSONDir, baseName+".geojson"),
+		} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("%s が書き出されていない: %v", path, err)
+			}
+		}
+	}
+	index, err := os.ReadFile(poller.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), names[len(names)-1]) {
+		t.Errorf("index.json が最新の電文を指していない: %s", index)
+	}
+
+	// 2回目は取得済みの電文を飛ばす
+	advisories, err = poller.PollOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advisories) != 0 {
+		t.Errorf("2回目に取り込んだ電文 = %d件, want 0件", len(advisories))
+	}
+	if got := len(poller.Registry().Storms()[0].Advisories); got != len(names) {
+		t.Errorf("台風の電文 = %d件, want %d件", got, len(names))
+	}
+}
+
+// TC2412 第104報の訂正で、元の電文のjson・geojsonが取り除かれ記録が残る
+func TestPollOnceCorrection(t *testing.T) {
+	names := []string{
+		"20240828234034_0_VPTW60_010000.xml",
+		"20240829000150_0_VPTW60_010000.xml",
+	}
+	server := newFeedServer(t, names)
+	poller := newTestPoller(t, server.URL+"/feed.xml")
+
+	if _, err := poller.PollOnce(); err != nil {
+		t.Fatal(err)
+	}
+
+	original := strings.TrimSuffix(names[0], ".xml")
+	for _, path := range []string{
+		filepath.Join(poller.JSONDir, original+".json"),
+		filepath.Join(poller.GeoJSONDir, original+".geojson"),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("差し替えられた %s が残っている", path)
+		}
+	}
+	// 元のxmlは記録のために残す
+	if _, err := os.Stat(filepath.Join(poller.XMLDir, names[0])); err != nil {
+		t.Error(err)
+	}
+
+	auditLog, err := os.ReadFile(poller.AuditLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(auditLog)), "\n"); len(lines) != 1 ||
+		!strings.Contains(lines[0], `"info_type":"訂正"`) || !strings.Contains(lines[0], names[0]) || !strings.Contains(lines[0], names[1]) {
+		t.Errorf("audit.jsonl = %s", auditLog)
+	}
+	advisory, err := usecase.FindAdvisory(poller.Registry().Storms()[0], 104)
+	if err != nil || filepath.Base(advisory.SourcePath) != names[1] {
+		t.Errorf("第104報 = %s, %v", advisory.SourcePath, err)
+	}
+}
+
+// 壊れた電文・取得できない電文があっても残りは取り込み、index.jsonも書き直す
+func TestPollOncePartialFailure(t *testing.T) {
+	const (
+		brokenName  = "20240819150000_0_VPTW60_010000.xml" // xmlとして読めない
+		missingName = "20240819160000_0_VPTW60_010000.xml" // 404
+	)
+	names := []string{
+		"20240819124216_0_VPTW60_010000.xml",
+		brokenName,
+		"20240819154223_0_VPTW60_010000.xml",
+		missingName,
+		"20240819184131_0_VPTW60_010000.xml",
+	}
+	server := newFeedServerWithFiles(t, names, map[string][]byte{
+		brokenName: []byte(`<?xml version="1.0" encoding="UTF-8"?><Report><Control>`),
+	})
+	poller := newTestPoller(t, server.URL+"/feed.xml")
+
+	advisories, err := poller.PollOnce()
+	if err == nil {
+		t.Fatal("壊れた電文・取得できない電文のエラーが返らない")
+	}
+	for _, name := range []string{brokenName, missingName} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("エラーに %s が含まれていない: %v", name, err)
+		}
+	}
+
+	want := []string{names[0], names[2], names[4]}
+	if len(advisories) != len(want) {
+		t.Fatalf("取り込んだ電文 = %d件, want %d件", len(advisories), len(want))
+	}
+	for i, advisory := range advisories {
+		if filepath.Base(advisory.SourcePath) != want[i] {
+			t.Errorf("%d件目 = %s, want %s", i, filepath.Base(advisory.SourcePath), want[i])
+		}
+	}
+
+	// 失敗した電文は取得済みにならない
+	for _, name := range []string{brokenName, missingName} {
+		if _, err := os.Stat(filepath.Join(poller.XMLDir, name)); err == nil {
+			t.Errorf("取り込めなかった %s が保存されている", name)
+		}
+	}
+	index, err := os.ReadFile(poller.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), names[4]) {
+		t.Errorf("index.json が最新の電文を指していない: %s", index)
+	}
+
+	// 2回目は取り込めた電文を飛ばし、失敗した電文だけをもう一度取りに行く
+	advisories, err = poller.PollOnce()
+	if len(advisories) != 0 {
+		t.Errorf("2回目に取り込んだ電文 = %d件, want 0件", len(advisories))
+	}
+	if err == nil || !strings.Contains(err.Error(), brokenName) || !strings.Contains(err.Error(), missingName) {
+		t.Errorf("2回目のエラー = %v, want %s と %s の失敗", err, brokenName, missingName)
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// 確率の範囲のGeoJSONを作る
+// probabilityTypeには"strike_probability"か"wind_probability"を指定する
+func MakeProbabilityContourFeatureCollection(eventID, probabilityType string, contours []model.GridContour) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, contour := range contours {
+		for _, rings := range contour.Polygons {
+			polygon := usecase.MakeGeojsonPolygonWithHoles(rings)
+			polygon.SetProperty("type", probabilityType)
+			polygon.SetProperty("event_id", eventID)
+			polygon.SetProperty("probability", contour.Level)
+			featureCollection.AddFeature(polygon)
+		}
+	}
+
+	return featureCollection
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"log"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/twpayne/go-geos"
+)
+
+// 初めてかかる時刻で範囲を区切るときの1区切り [from, to)
+type onsetWindow struct {
+	from, to time.Time
+}
+
+// 指定した風速の円(15m/sの強風域、25m/sの暴風域・暴風警戒域)がかかる範囲を求める
+// 予報時刻の間はstepごとに円を補間してつなげ、windowごとに区切った時間の間に初めてかかる範囲に分ける
+// (NHCのwind speed swathのように、いつ頃から風が強まるかが分かる)
+func CalcWindSwaths(
+	eventID string,
+	typhoons []model.Typhoon,
+	windSpeed int,
+	step, window time.Duration,
+	options model.ProductOptions,
+) []model.WindSwath {
+	windAreaTimeSeries := usecase.InterpolateStormAreaTimeSeries(
+		usecase.MakeWindAreaTimeSeries(typhoons, windSpeed, options),
+		step,
+	)
+	if len(windAreaTimeSeries) == 0 {
+		return []model.WindSwath{}
+	}
+
+	// 最初の円の時刻からwindowごとに区切る
+	start := windAreaTimeSeries[0]
+	last := windAreaTimeSeries[len(windAreaTimeSeries)-1]
+	windows := []onsetWindow{}
+	for from := start.ValidTime; ; from = from.Add(window) {
+		to := from.Add(window)
+		if window <= 0 || !to.Before(last.ValidTime) {
+			windows = append(windows, onsetWindow{from: from, to: last.ValidTime})
+			break
+		}
+		windows = append(windows, onsetWindow{from: from, to: to})
+	}
+
+	return makeOnsetSwaths(eventID, windSpeed, windAreaTimeSeries, windows)
+}
+
+// 指定した風速の円がかかり始める時刻で範囲を分ける (暴風域に入る時刻の目安)
+// hoursは何時間後までに初めてかかるかの区切り (例: 12,24,48)で、最後の区切りより後にかかる範囲は含めない
+func CalcArrivalIsochrones(
+	eventID string,
+	typhoons []model.Typhoon,
+	windSpeed int,
+	step time.Duration,
+	hours []int,
+	options model.ProductOptions,
+) []model.WindSwath {
+	windAreaTimeSeries := usecase.InterpolateStormAreaTimeSeries(
+		usecase.MakeWindAreaTimeSeries(typhoons, windSpeed, options),
+		step,
+	)
+	if len(windAreaTimeSeries) == 0 {
+		return []model.WindSwath{}
+	}
+
+	// 何時間後は実況(0時間後)の時刻から数える
+	start := windAreaTimeSeries[0]
+	baseTime := start.ValidTime.Add(-time.Duration(start.LeadHours) * time.Hour)
+	windows := []onsetWindow{}
+	from := baseTime
+	for _, hour := range hours {
+		to := baseTime.Add(time.Duration(hour) * time.Hour)
+		if to.After(from) {
+			windows = append(windows, onsetWindow{from: from, to: to})
+			from = to
+		}
+	}
+
+	return makeOnsetSwaths(eventID, windSpeed, windAreaTimeSeries, windows)
+}
+
+// 円の時系列をCalcStormAreaPolygonと同じように凸包でつなぎ、つないだ始まりの時刻が入る区切りに分ける
+// それより前の区切りで既にかかっている範囲は除くので、区切りごとの範囲は重ならない
+func makeOnsetSwaths(eventID string, windSpeed int, windAreaTimeSeries []model.StormArea, windows []onsetWindow) []model.WindSwath {
+	hulls := calcStormAreaHulls(windAreaTimeSeries)
+	start := windAreaTimeSeries[0]
+	baseTime := start.ValidTime.Add(-time.Duration(start.LeadHours) * time.Hour)
+
+	swaths := []model.WindSwath{}
+	var covered *geos.Geom
+	for _, window := range windows {
+		pieces := [][]model.Point{}
+		for i, hull := range hulls {
+			t := windAreaTimeSeries[i].ValidTime
+			// 円が1つだけの場合は区切りの長さが0になるので、始まりの時刻と同じものも入れる
+			if !t.Before(window.from) && (t.Before(window.to) || t.Equal(window.from)) {
+				pieces = append(pieces, hull)
+			}
+		}
+		if len(pieces) == 0 {
+			continue
+		}
+
+		wkt := usecase.MultiPolygonToWKT(pieces)
+		geom, err := geos.NewGeomFromWKT(wkt)
+		if err != nil {
+			log.Fatalf("makeOnsetSwaths Error: %v, wkt: %v", err, wkt)
+		}
+		geom = geom.UnaryUnion()
+
+		part := geom
+		if covered != nil {
+			part = geom.Difference(covered)
+			covered = covered.Union(geom)
+		} else {
+			covered = geom
+		}
+
+		swaths = append(swaths, model.WindSwath{
+			EventID:   eventID,
+			WindSpeed: windSpeed,
+			From:      window.from,
+			To:        window.to,
+			LeadFrom:  int(window.from.Sub(baseTime).Hours()),
+			LeadTo:    int(window.to.Sub(baseTime).Hours()),
+			Polygons:  geomToPolygonsWithHoles(part),
+		})
+	}
+
+	return swaths
+}
+
+func MakeWindSwathFeatureCollection(swaths []model.WindSwath) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, swath := range swaths {
+		for _, rings := range swath.Polygons {
+			polygon := usecase.MakeGeojsonPolygonWithHoles(rings)
+			polygon.SetProperty("type", "wind_swath")
+			polygon.SetProperty("event_id", swath.EventID)
+			polygon.SetProperty("wind_speed", swath.WindSpeed)
+			polygon.SetProperty("from", swath.From.UTC().Format(usecase.TargetTimestampLayout))
+			polygon.SetProperty("to", swath.To.UTC().Format(usecase.TargetTimestampLayout))
+			polygon.SetProperty("lead_from", swath.LeadFrom)
+			polygon.SetProperty("lead_to", swath.LeadTo)
+			featureCollection.AddFeature(polygon)
+		}
+	}
+
+	return featureCollection
+}
+
+func MakeArrivalIsochroneFeatureCollection(isochrones []model.WindSwath) *geojson.FeatureCollection {
+	featureCollection := geojson.NewFeatureCollection()
+
+	for _, isochrone := range isochrones {
+		for _, rings := range isochrone.Polygons {
+			polygon := usecase.MakeGeojsonPolygonWithHoles(rings)
+			polygon.SetProperty("type", "arrival_isochrone")
+			polygon.SetProperty("event_id", isochrone.EventID)
+			polygon.SetProperty("wind_speed", isochrone.WindSpeed)
+			polygon.SetProperty("onset_from", isochrone.From.UTC().Format(usecase.TargetTimestampLayout))
+			polygon.SetProperty("onset_to", isochrone.To.UTC().Format(usecase.TargetTimestampLayout))
+			polygon.SetProperty("lead_from", isochrone.LeadFrom)
+			polygon.SetProperty("lead_to", isochrone.LeadTo)
+			featureCollection.AddFeature(polygon)
+		}
+	}
+
+	return featureCollection
+}
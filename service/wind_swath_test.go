@@ -0,0 +1,229 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/usecase"
+
+	"github.com/twpayne/go-geos"
+)
+
+var testSwathBaseTime = time.Date(2024, 8, 28, 0, 0, 0, 0, time.UTC)
+
+// 真北に進む台風の暴風域 (leadHoursごとの中心の緯度と半径)
+// 半径が0の時刻は暴風域が無い
+func makeTestSwathTyphoons(leadHours []int, latitudes []float64, radii []int) []model.Typhoon {
+	typhoons := []model.Typhoon{}
+	for i, lead := range leadHours {
+		typhoon := model.Typhoon{
+			Latitude:     latitudes[i],
+			Longitude:    130,
+			WarningAreas: []model.TyphoonWarningArea{},
+			ValidTime:    testSwathBaseTime.Add(time.Duration(lead) * time.Hour),
+			FixKind:      model.FixKindForecast,
+			LeadHours:    lead,
+		}
+		if lead == 0 {
+			typhoon.FixKind = model.FixKindAnalysis
+		}
+		if radii[i] > 0 {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, model.TyphoonWarningArea{
+				WarningAreaType:   "暴風域",
+				WindSpeed:         25,
+				CircleLongRadius:  radii[i],
+				CircleShortRadius: radii[i],
+			})
+		}
+		typhoons = append(typhoons, typhoon)
+	}
+	return typhoons
+}
+
+// 外周・穴の順に並んだリングのポリゴンをまとめて1つのジオメトリにする
+func swathToGeom(polygons [][][]model.Point) *geos.Geom {
+	var geom *geos.Geom
+	for _, rings := range polygons {
+		polygon := polygonToGeom(rings[0])
+		for _, hole := range rings[1:] {
+			polygon = polygon.Difference(polygonToGeom(hole))
+		}
+		if geom == nil {
+			geom = polygon
+		} else {
+			geom = geom.Union(polygon)
+		}
+	}
+	return geom
+}
+
+func testPointGeom(latitude, longitude float64) *geos.Geom {
+	geom, err := geos.NewGeomFromWKT(fmt.Sprintf("POINT(%f %f)", longitude, latitude))
+	if err != nil {
+		panic(err)
+	}
+	return geom
+}
+
+// 区切りごとの範囲が重ならず、合わせると区切らずに求めた範囲と同じになること
+func checkOnsetSwathPartition(t *testing.T, swaths []model.WindSwath, full *geos.Geom) {
+	t.Helper()
+	geoms := []*geos.Geom{}
+	for _, swath := range swaths {
+		if len(swath.Polygons) == 0 {
+			t.Errorf("%d-%d時間後の範囲が空", swath.LeadFrom, swath.LeadTo)
+			continue
+		}
+		geoms = append(geoms, swathToGeom(swath.Polygons))
+	}
+	if len(geoms) == 0 {
+		t.Fatalf("範囲がありません")
+	}
+
+	fullArea := full.Area()
+	union := geoms[0]
+	for i := range geoms {
+		for j := i + 1; j < len(geoms); j++ {
+			if overlap := geoms[i].Intersection(geoms[j]).Area(); overlap > 1e-6*fullArea {
+				t.Errorf("%d番目と%d番目の範囲が重なっている (%.6f度2)", i, j, overlap)
+			}
+		}
+		if i > 0 {
+			union = union.Union(geoms[i])
+		}
+	}
+	if diff := union.SymDifference(full).Area(); diff > 1e-3*fullArea {
+		t.Errorf("区切った範囲を合わせたものが全体と違う (ずれ %.6f度2 / 全体 %.6f度2)", diff, fullArea)
+	}
+}
+
+// 一定の長さの区切りで分けると、区切りの時刻と何時間後が正しく、範囲は重ならずに全体を覆うこと
+func TestCalcWindSwaths(t *testing.T) {
+	typhoons := makeTestSwathTyphoons(
+		[]int{0, 24, 48},
+		[]float64{20, 25, 30},
+		[]int{100, 120, 150},
+	)
+	swaths := CalcWindSwaths("TC2410", typhoons, 25, 6*time.Hour, 18*time.Hour, model.ProductOptions{})
+
+	// 0-18, 18-36, 36-48時間後 (最後の区切りは最後の円の時刻まで)
+	wantLeads := [][2]int{{0, 18}, {18, 36}, {36, 48}}
+	if len(swaths) != len(wantLeads) {
+		t.Fatalf("区切りの数 = %d, want %d", len(swaths), len(wantLeads))
+	}
+	for i, want := range wantLeads {
+		swath := swaths[i]
+		if swath.LeadFrom != want[0] || swath.LeadTo != want[1] {
+			t.Errorf("%d番目の区切り = %d-%d時間後, want %d-%d時間後", i, swath.LeadFrom, swath.LeadTo, want[0], want[1])
+		}
+		wantFrom := testSwathBaseTime.Add(time.Duration(want[0]) * time.Hour)
+		wantTo := testSwathBaseTime.Add(time.Duration(want[1]) * time.Hour)
+		if !swath.From.Equal(wantFrom) || !swath.To.Equal(wantTo) {
+			t.Errorf("%d番目の区切り = %s-%s, want %s-%s", i, swath.From, swath.To, wantFrom, wantTo)
+		}
+		if swath.EventID != "TC2410" || swath.WindSpeed != 25 {
+			t.Errorf("%d番目の区切り = %s %dm/s, want TC2410 25m/s", i, swath.EventID, swath.WindSpeed)
+		}
+	}
+
+	windAreaTimeSeries := usecase.InterpolateStormAreaTimeSeries(
+		usecase.MakeWindAreaTimeSeries(typhoons, 25, model.ProductOptions{}),
+		6*time.Hour,
+	)
+	full := polygonToGeom(CalcStormAreaPolygon(windAreaTimeSeries))
+	checkOnsetSwathPartition(t, swaths, full)
+}
+
+// 区切りをまたぐ凸包は始まりの時刻の区切りに入り、次の区切りには重ねて入れないこと
+func TestCalcWindSwathsHullAcrossWindow(t *testing.T) {
+	typhoons := makeTestSwathTyphoons(
+		[]int{0, 24},
+		[]float64{20, 25},
+		[]int{100, 100},
+	)
+	// 6時間ごとの円を9時間で区切ると、6-12時間後の凸包が1つ目の区切りをまたぐ
+	swaths := CalcWindSwaths("TC2410", typhoons, 25, 6*time.Hour, 9*time.Hour, model.ProductOptions{})
+	if len(swaths) < 2 {
+		t.Fatalf("区切りの数 = %d, want 2以上", len(swaths))
+	}
+
+	// 12時間後の中心 (北緯22.5度) は1つ目の区切りだけに入る
+	center12 := testPointGeom(22.5, 130)
+	if !swathToGeom(swaths[0].Polygons).Contains(center12) {
+		t.Errorf("12時間後の中心が0-9時間後の範囲に入っていない")
+	}
+	if swathToGeom(swaths[1].Polygons).Contains(center12) {
+		t.Errorf("12時間後の中心が9-18時間後の範囲にも入っている")
+	}
+	// 18時間後の中心 (北緯23.75度) は12-18時間後の凸包なので2つ目の区切りに入る
+	if !swathToGeom(swaths[1].Polygons).Contains(testPointGeom(23.75, 130)) {
+		t.Errorf("18時間後の中心が9-18時間後の範囲に入っていない")
+	}
+
+	windAreaTimeSeries := usecase.InterpolateStormAreaTimeSeries(
+		usecase.MakeWindAreaTimeSeries(typhoons, 25, model.ProductOptions{}),
+		6*time.Hour,
+	)
+	full := polygonToGeom(CalcStormAreaPolygon(windAreaTimeSeries))
+	checkOnsetSwathPartition(t, swaths, full)
+}
+
+// 暴風域が予報の途中から始まっても、何時間後は実況の時刻から数えること
+func TestCalcArrivalIsochrones(t *testing.T) {
+	typhoons := makeTestSwathTyphoons(
+		[]int{0, 12, 24, 48},
+		[]float64{18, 20, 22, 26},
+		[]int{0, 80, 100, 150},
+	)
+	// 重なる区切りや前に戻る区切りは飛ばす
+	isochrones := CalcArrivalIsochrones("TC2410", typhoons, 25, 3*time.Hour, []int{12, 12, 24, 48}, model.ProductOptions{})
+
+	// 0-12時間後には暴風域が無いので、12-24, 24-48時間後の2つになる
+	wantLeads := [][2]int{{12, 24}, {24, 48}}
+	if len(isochrones) != len(wantLeads) {
+		t.Fatalf("区切りの数 = %d, want %d", len(isochrones), len(wantLeads))
+	}
+	for i, want := range wantLeads {
+		isochrone := isochrones[i]
+		if isochrone.LeadFrom != want[0] || isochrone.LeadTo != want[1] {
+			t.Errorf("%d番目の区切り = %d-%d時間後, want %d-%d時間後", i, isochrone.LeadFrom, isochrone.LeadTo, want[0], want[1])
+		}
+		wantFrom := testSwathBaseTime.Add(time.Duration(want[0]) * time.Hour)
+		if !isochrone.From.Equal(wantFrom) {
+			t.Errorf("%d番目の区切りの始まり = %s, want %s", i, isochrone.From, wantFrom)
+		}
+	}
+
+	// 12時間後の中心は最初にかかる12-24時間後に入る
+	if !swathToGeom(isochrones[0].Polygons).Contains(testPointGeom(20, 130)) {
+		t.Errorf("12時間後の中心が12-24時間後の範囲に入っていない")
+	}
+
+	windAreaTimeSeries := usecase.InterpolateStormAreaTimeSeries(
+		usecase.MakeWindAreaTimeSeries(typhoons, 25, model.ProductOptions{}),
+		3*time.Hour,
+	)
+	full := polygonToGeom(CalcStormAreaPolygon(windAreaTimeSeries))
+	checkOnsetSwathPartition(t, isochrones, full)
+}
+
+// 最後の区切りより後にかかり始める範囲は含めないこと
+func TestCalcArrivalIsochronesLastWindow(t *testing.T) {
+	typhoons := makeTestSwathTyphoons(
+		[]int{0, 24, 48},
+		[]float64{20, 25, 30},
+		[]int{100, 100, 100},
+	)
+	isochrones := CalcArrivalIsochrones("TC2410", typhoons, 25, 6*time.Hour, []int{24}, model.ProductOptions{})
+	if len(isochrones) != 1 || isochrones[0].LeadFrom != 0 || isochrones[0].LeadTo != 24 {
+		t.Fatalf("区切り = %v, want 0-24時間後の1つ", isochrones)
+	}
+	geom := swathToGeom(isochrones[0].Polygons)
+	if !geom.Contains(testPointGeom(22.5, 130)) {
+		t.Errorf("12時間後の中心が0-24時間後の範囲に入っていない")
+	}
+	if geom.Contains(testPointGeom(30, 130)) {
+		t.Errorf("48時間後の中心が0-24時間後の範囲に入っている")
+	}
+}
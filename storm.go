@@ -0,0 +1,353 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// xmlディレクトリにある台風の一覧を表示する
+func runStorms(args []string) {
+	flags := flag.NewFlagSet("storms", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	flags.Parse(args)
+
+	registry, err := usecase.LoadStormRegistry(*xmlDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, storm := range registry.Storms() {
+		first := storm.Advisories[0]
+		latest, _ := usecase.LatestAdvisory(storm)
+		// 熱帯低気圧のままの場合は台風番号・名前が無い
+		number := "-"
+		if storm.TyphoonNumber != "" {
+			number = "台風" + storm.TyphoonNumber + "号"
+		}
+		fmt.Printf(
+			"%s\t%s\t%s\t第%d報〜第%d報\t%d件\n",
+			storm.EventID, number, storm.TyphoonName, first.Serial, latest.Serial, len(storm.Advisories),
+		)
+	}
+}
+
+// 指定した台風の最新の電文をGeoJSONに書き出す
+func runLatest(args []string) {
+	flags := flag.NewFlagSet("latest", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は<EventID>_latest.geojson)")
+	productOptions := addProductFlags(flags)
+	horizons := flags.String("horizons", "", "何時間後までの予報円を入れ子にして書き出す (例: 24,72,120)")
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	advisory, ok := usecase.LatestAdvisory(storm)
+	if !ok {
+		log.Fatalf("%s の電文がありません", storm.EventID)
+	}
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", storm.EventID+"_latest.geojson")
+	}
+	options := productOptions()
+	if *horizons != "" {
+		featureCollection := service.MakeNestedConeFeatureCollection(advisory.Typhoons, parseHorizons(*horizons), options)
+		if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+			log.Fatal(err)
+		}
+	} else if err := service.SaveTyphoonGeoJSON(savePath, advisory.Typhoons, options); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s 第%d報のGeoJSONを %s に書き出しました\n", storm.EventID, advisory.Serial, savePath)
+}
+
+// 指定した台風の全ての電文をgeojsonディレクトリに書き出す
+// notifierを指定した場合は、書き出した電文を送り先に知らせる
+func runConvertStorm(xmlDir, stormQuery string, options model.ProductOptions, notifier *service.Notifier) {
+	storm := loadStorm(xmlDir, stormQuery)
+
+	for _, advisory := range storm.Advisories {
+		baseName := strings.TrimSuffix(filepath.Base(advisory.SourcePath), ".xml")
+		savePath := filepath.Join("./geojson", baseName+".geojson")
+		featureCollection := service.MakeTyphoonFeatureCollection(advisory.Typhoons, options)
+		if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+			fmt.Println("Error saving GeoJSON to file:", err)
+			return
+		}
+
+		fmt.Printf("GeoJSON successfully written to %s\n", savePath)
+
+		if notifier != nil {
+			if err := notifier.NotifyAdvisory(advisory, featureCollection); err != nil {
+				fmt.Println("Error notifying webhooks:", err)
+			}
+		}
+	}
+}
+
+func loadStorm(xmlDir, stormQuery string) *model.Storm {
+	if stormQuery == "" {
+		log.Fatal("-storm を指定してください")
+	}
+
+	registry, err := usecase.LoadStormRegistry(xmlDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	storm, err := registry.FindStorm(stormQuery)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return storm
+}
+
+// 報数を指定した場合はその電文、0の場合は最新の電文
+func selectAdvisory(storm *model.Storm, serial int) model.Advisory {
+	if serial != 0 {
+		advisory, err := usecase.FindAdvisory(storm, serial)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return advisory
+	}
+	advisory, ok := usecase.LatestAdvisory(storm)
+	if !ok {
+		log.Fatalf("%s の電文がありません", storm.EventID)
+	}
+	return advisory
+}
+
+// 指定した台風の実況経路に最新の予報経路を重ねてGeoJSONに書き出す
+func runTrack(args []string) {
+	flags := flag.NewFlagSet("track", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は<EventID>_track.geojson)")
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	latest, _ := usecase.LatestAdvisory(storm)
+	track := service.MakeObservedTrack(storm)
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", storm.EventID+"_track.geojson")
+	}
+	featureCollection := service.MakeObservedTrackFeatureCollection(track, latest)
+	if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s の実況%d件の経路を %s に書き出しました\n", storm.EventID, len(track.Fixes), savePath)
+}
+
+// 指定した台風の実況の暴風域・強風域が通過した範囲をGeoJSONに書き出す
+func runSwath(args []string) {
+	flags := flag.NewFlagSet("swath", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は<EventID>_swath.geojson)")
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+
+	// 強風域の上に暴風域が重なるように、強風域を先に入れる
+	swaths := service.CalcObservedSwaths(storm, "強風域")
+	swaths = append(swaths, service.CalcObservedSwaths(storm, "暴風域")...)
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", storm.EventID+"_swath.geojson")
+	}
+	featureCollection := service.MakeObservedSwathFeatureCollection(swaths)
+	if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s の実況の暴風域・強風域の範囲を %s に書き出しました\n", storm.EventID, savePath)
+}
+
+// 指定した台風の電文の暴風域・強風域などが時間ごとに初めてかかる範囲をGeoJSONに書き出す
+func runWindSwath(args []string) {
+	flags := flag.NewFlagSet("windswath", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	serial := flags.Int("serial", 0, "使う電文の報数 (省略時は最新)")
+	windSpeed := flags.Int("wind", 25, "風速(m/s) (25で暴風域・暴風警戒域、15で強風域)")
+	step := flags.Duration("step", time.Hour, "予報時刻の間の円を補間する間隔")
+	window := flags.Duration("window", 12*time.Hour, "範囲を区切る時間")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は<EventID>_windswath_<風速>.geojson)")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	advisory := selectAdvisory(storm, *serial)
+
+	swaths := service.CalcWindSwaths(storm.EventID, advisory.Typhoons, *windSpeed, *step, *window, productOptions())
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", fmt.Sprintf("%s_windswath_%d.geojson", storm.EventID, *windSpeed))
+	}
+	featureCollection := service.MakeWindSwathFeatureCollection(swaths)
+	if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s 第%d報の%dm/s以上の範囲を%d区切りで %s に書き出しました\n", storm.EventID, advisory.Serial, *windSpeed, len(swaths), savePath)
+}
+
+// 指定した台風の電文の暴風域・強風域などが何時間後までにかかり始めるかの範囲をGeoJSONに書き出す
+func runIsochrone(args []string) {
+	flags := flag.NewFlagSet("isochrone", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	serial := flags.Int("serial", 0, "使う電文の報数 (省略時は最新)")
+	windSpeed := flags.Int("wind", 25, "風速(m/s) (25で暴風域・暴風警戒域、15で強風域)")
+	step := flags.Duration("step", time.Hour, "予報時刻の間の円を補間する間隔")
+	hours := flags.String("hours", "12,24,48,72,120", "何時間後までにかかり始めるかの区切り")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は<EventID>_isochrone_<風速>.geojson)")
+	sitesPath := flags.String("sites", "", "かかり始める時刻と最接近を表示する拠点のCSVのパス")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+	advisory := selectAdvisory(storm, *serial)
+	options := productOptions()
+
+	isochrones := service.CalcArrivalIsochrones(storm.EventID, advisory.Typhoons, *windSpeed, *step, parseHorizons(*hours), options)
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", fmt.Sprintf("%s_isochrone_%d.geojson", storm.EventID, *windSpeed))
+	}
+	featureCollection := service.MakeArrivalIsochroneFeatureCollection(isochrones)
+	if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s 第%d報の%dm/s以上の風がかかり始める範囲を%d区切りで %s に書き出しました\n", storm.EventID, advisory.Serial, *windSpeed, len(isochrones), savePath)
+
+	if *sitesPath != "" {
+		sites, err := usecase.ReadSitesCSV(*sitesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printSiteArrivals(advisory, sites, isochrones, options)
+	}
+}
+
+// 拠点ごとに、風がかかり始める区切りと中心が最も近づく時刻・距離を表示する
+func printSiteArrivals(advisory model.Advisory, sites []model.Site, isochrones []model.WindSwath, options model.ProductOptions) {
+	for _, site := range sites {
+		arrival := "かからない"
+	isochroneLoop:
+		for _, isochrone := range isochrones {
+			for _, rings := range isochrone.Polygons {
+				if usecase.PointInPolygon(site.Point, rings) {
+					arrival = fmt.Sprintf("%3d〜%3d時間後", isochrone.LeadFrom, isochrone.LeadTo)
+					break isochroneLoop
+				}
+			}
+		}
+
+		closestApproach, ok := usecase.CalcClosestApproach(advisory.Typhoons, site.Point, options)
+		if !ok {
+			fmt.Printf("%-20s %s\n", site.Name, arrival)
+			continue
+		}
+		fmt.Printf(
+			"%-20s %s\t最接近 %s (%d時間後) 約%.0fkm\t予報円の範囲まで 約%.0fkm\n",
+			site.Name, arrival, closestApproach.Time.UTC().Format(usecase.TargetTimestampLayout), closestApproach.LeadHours,
+			closestApproach.Distance, closestApproach.ConeDistance,
+		)
+	}
+}
+
+// 同じ台風の2つの電文の予報円・予報経路・強さの差分をGeoJSONとテキストに書き出す
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか")
+	fromSerial := flags.Int("from", 0, "比較元の報数 (省略時は比較先の1つ前に受け取った電文)")
+	toSerial := flags.Int("to", 0, "比較先の報数 (省略時は最新)")
+	output := flags.String("o", "", "書き出すGeoJSONのパス (省略時は<EventID>_diff_<from>_<to>.geojson)")
+	flags.Parse(args)
+
+	storm := loadStorm(*xmlDir, *stormQuery)
+
+	to, ok := usecase.LatestAdvisory(storm)
+	if !ok {
+		log.Fatalf("%s の電文がありません", storm.EventID)
+	}
+	var err error
+	if *toSerial != 0 {
+		if to, err = usecase.FindAdvisory(storm, *toSerial); err != nil {
+			log.Fatal(err)
+		}
+	}
+	from, err := usecase.PreviousAdvisory(storm, to.Serial)
+	if *fromSerial != 0 {
+		from, err = usecase.FindAdvisory(storm, *fromSerial)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diff := service.CalcAdvisoryDiff(from, to)
+
+	savePath := *output
+	if savePath == "" {
+		savePath = filepath.Join("./geojson", fmt.Sprintf("%s_diff_%d_%d.geojson", storm.EventID, from.Serial, to.Serial))
+	}
+	featureCollection := service.MakeAdvisoryDiffFeatureCollection(diff)
+	if err := usecase.SaveFeatureCollectionToFile(savePath, featureCollection); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Print(service.FormatAdvisoryDiffSummary(diff))
+	fmt.Printf("差分のGeoJSONを %s に書き出しました\n", savePath)
+}
+
+// xmlディレクトリから台風ごとの最新の電文の一覧を作り直し、訂正・取消による差し替えを表示する
+func runIndex(args []string) {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	jsonDir := flags.String("json", "./json", "電文ごとのJSONがあるディレクトリ")
+	geojsonDir := flags.String("geojson", "./geojson", "電文ごとのGeoJSONがあるディレクトリ")
+	output := flags.String("o", "./geojson/index.json", "書き出す一覧のパス")
+	flags.Parse(args)
+
+	registry, err := usecase.LoadStormRegistry(*xmlDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	retractSuperseded(registry, *jsonDir, *geojsonDir)
+
+	products := usecase.MakeLatestProducts(registry, *geojsonDir)
+	if err := usecase.SaveLatestProducts(*output, products); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%d件の台風の最新の電文の一覧を %s に書き出しました\n", len(products), *output)
+}
+
+// 訂正・取消で差し替えられた電文のjson・geojsonを、pollと同じように取り除く
+func retractSuperseded(registry *usecase.StormRegistry, jsonDir, geojsonDir string) {
+	for _, entry := range registry.AuditLog() {
+		fmt.Printf("%s %s 第%d報: %s → %s\n", entry.InfoType, entry.EventID, entry.Serial, entry.ReplacedSourcePath, entry.SourcePath)
+		if err := usecase.RetractProducts(entry, jsonDir, geojsonDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,512 @@
+package usecase
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+	"typhoon-polygon/model"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+const (
+	// 索引の記録(追記していくログ)のファイル名
+	archiveLogName = "archive.log"
+	// 記録を読み書きする間、flockで排他するファイル名
+	archiveLockName = "archive.lock"
+	// 以前の索引のファイル名 (記録が無ければここから移す)
+	archiveLegacyIndexName = "index.json"
+	// 書き込み用に開くときに、記録の行数が電文の数の2倍を超えていて、この行数より多ければ詰め直す
+	archiveCompactMinRecords = 64
+)
+
+// シーズンを通した電文と生成したGeoJSONを1つのディレクトリに貯める
+// 電文は<EventID>/<報数>.json、GeoJSONは<EventID>/<報数>.geojsonに置き、
+// 索引は入れる・取り除くたびに1行ずつarchive.logに追記する (索引全体は書き直さない)
+// 開くときに記録を読み、台風・時刻・範囲の索引(archiveIndex)を作って探す
+//
+// poll・archive・queryが同じディレクトリを同時に使えるよう、記録を読む間はarchive.lockを共有で、
+// 書き込む・切り捨てる・詰め直す間は排他でflockする
+// 書き込む前とRefreshでは他のプロセスが追記した記録を読み込み、詰め直されて置き換わっていれば初めから読み直す
+type Archive struct {
+	dir      string
+	readOnly bool
+	lock     *os.File                      // archive.lock (読み込み用でディレクトリが無ければnil)
+	log      *os.File                      // archive.log (読み込み用で記録が無ければnil)
+	offset   int64                         // archive.logの読み込んだ位置
+	records  int                           // archive.logの読み込んだ行数
+	entries  map[string]model.ArchiveEntry // EventID/Serial
+	index    *archiveIndex
+}
+
+// archive.logの1行
+type archiveRecord struct {
+	Op      string              `json:"op"` // put か remove
+	EventID string              `json:"event_id"`
+	Serial  int                 `json:"serial"`
+	Entry   *model.ArchiveEntry `json:"entry,omitempty"`
+}
+
+// アーカイブのディレクトリを書き込み用に開く
+// ディレクトリや記録が無ければ空のアーカイブになる
+// 途中で止まって最後の行が書きかけになっていれば、その行を切り捨てる
+func OpenArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	archive, err := openArchive(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	err = archive.withLock(true, func() error {
+		if err := archive.refresh(); err != nil {
+			return err
+		}
+		if archive.records == 0 {
+			if err := archive.importLegacyIndex(); err != nil {
+				return err
+			}
+		}
+		if archive.records > 2*len(archive.entries) && archive.records > archiveCompactMinRecords {
+			return archive.compact()
+		}
+		return nil
+	})
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return archive, nil
+}
+
+// アーカイブのディレクトリを読み込み用に開く (queryなど)
+// 記録の切り捨て・詰め直しはせず、ディレクトリや記録が無ければ空のアーカイブになる
+func OpenArchiveReadOnly(dir string) (*Archive, error) {
+	archive, err := openArchive(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := archive.Refresh(); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return archive, nil
+}
+
+func openArchive(dir string, readOnly bool) (*Archive, error) {
+	archive := &Archive{dir: dir, readOnly: readOnly, entries: map[string]model.ArchiveEntry{}, index: newArchiveIndex()}
+	lock, err := os.OpenFile(filepath.Join(dir, archiveLockName), os.O_RDONLY|os.O_CREATE, 0644)
+	if readOnly && errors.Is(err, os.ErrNotExist) {
+		return archive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	archive.lock = lock
+	return archive, nil
+}
+
+// 記録を読む間は共有、書き込む間は排他でarchive.lockをロックする
+func (a *Archive) withLock(exclusive bool, fn func() error) error {
+	if a.lock == nil {
+		return fn()
+	}
+	if err := lockFile(a.lock, exclusive); err != nil {
+		return fmt.Errorf("%s: %v", archiveLockName, err)
+	}
+	defer unlockFile(a.lock)
+	return fn()
+}
+
+// 他のプロセスが追記した記録を読み込む
+func (a *Archive) Refresh() error {
+	return a.withLock(false, a.refresh)
+}
+
+// 記録の続きを読み込む (ロックしてから呼ぶ)
+// 記録が他のプロセスに詰め直されて置き換わっていれば、開き直して初めから読む
+// 書き込み用なら書きかけの最後の行を切り捨てる (排他している間は書きかけの行は止まったプロセスのものしか無い)
+func (a *Archive) refresh() error {
+	path := filepath.Join(a.dir, archiveLogName)
+	info, err := os.Stat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if a.log == nil || info == nil || !a.isLogFile(info) {
+		if info == nil && a.readOnly {
+			a.reset(nil)
+			return nil
+		}
+		flag := os.O_RDWR | os.O_CREATE | os.O_APPEND
+		if a.readOnly {
+			flag = os.O_RDONLY
+		}
+		f, err := os.OpenFile(path, flag, 0644)
+		if err != nil {
+			return err
+		}
+		a.reset(f)
+	}
+
+	if err := a.replay(); err != nil {
+		return fmt.Errorf("%s: %v", archiveLogName, err)
+	}
+	if a.readOnly {
+		return nil
+	}
+	info, err = a.log.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > a.offset {
+		return a.log.Truncate(a.offset)
+	}
+	return nil
+}
+
+// 開いている記録がpathのファイルと同じか
+func (a *Archive) isLogFile(info os.FileInfo) bool {
+	logInfo, err := a.log.Stat()
+	return err == nil && os.SameFile(logInfo, info)
+}
+
+// 記録を開き直し、索引を空にする
+func (a *Archive) reset(f *os.File) {
+	if a.log != nil {
+		a.log.Close()
+	}
+	a.log = f
+	a.offset = 0
+	a.records = 0
+	a.entries = map[string]model.ArchiveEntry{}
+	a.index = newArchiveIndex()
+}
+
+// 読み込んだ位置から記録を読んで索引に反映する
+// 改行の無い最後の行は書きかけなので読まない
+func (a *Archive) replay() error {
+	if _, err := a.log.Seek(a.offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(a.log)
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		record := archiveRecord{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("%d行目: %v", a.records+1, err)
+		}
+		a.apply(record)
+		a.records++
+		a.offset += int64(len(data))
+	}
+}
+
+// 以前の索引(index.json)の電文を記録に移す
+// index.jsonはそのまま残すが、記録があれば読まない
+func (a *Archive) importLegacyIndex() error {
+	data, err := os.ReadFile(filepath.Join(a.dir, archiveLegacyIndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries := []model.ArchiveEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %v", archiveLegacyIndexName, err)
+	}
+	for _, entry := range entries {
+		if err := a.append(archiveRecord{Op: "put", EventID: entry.EventID, Serial: entry.Serial, Entry: &entry}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 記録を今ある電文だけに詰め直す (排他してから呼ぶ)
+// 途中で止まっても壊れた記録が残らないよう、一時ファイルに書いてから置き換える
+// 記録を開いたままの他のプロセスは、次に読み書きするときに置き換わったことに気づいて開き直す
+func (a *Archive) compact() error {
+	path := filepath.Join(a.dir, archiveLogName)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range a.Entries() {
+		data, err := json.Marshal(archiveRecord{Op: "put", EventID: entry.EventID, Serial: entry.Serial, Entry: &entry})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	a.log.Close()
+	a.log = nil
+	return a.refresh()
+}
+
+// 1行を記録に追記してから索引に反映する (排他してから呼ぶ)
+func (a *Archive) append(record archiveRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := a.log.Write(data); err != nil {
+		return err
+	}
+	if err := a.log.Sync(); err != nil {
+		return err
+	}
+	a.apply(record)
+	a.records++
+	a.offset += int64(len(data))
+	return nil
+}
+
+func (a *Archive) apply(record archiveRecord) {
+	key := archiveKey(record.EventID, record.Serial)
+	if old, ok := a.entries[key]; ok {
+		a.index.remove(key, old)
+		delete(a.entries, key)
+	}
+	if record.Op == "put" && record.Entry != nil {
+		a.entries[key] = *record.Entry
+		a.index.add(key, *record.Entry)
+	}
+}
+
+func archiveKey(eventID string, serial int) string {
+	return fmt.Sprintf("%s/%d", eventID, serial)
+}
+
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+// 記録とロックのファイルを閉じる
+func (a *Archive) Close() error {
+	var err error
+	if a.log != nil {
+		err = a.log.Close()
+	}
+	if a.lock != nil {
+		a.lock.Close()
+	}
+	return err
+}
+
+// 同じ電文(EventID・報数・元のファイル)が既に入っているか
+func (a *Archive) Has(advisory model.Advisory) bool {
+	entry, ok := a.entries[archiveKey(advisory.EventID, advisory.Serial)]
+	return ok && entry.SourcePath == advisory.SourcePath
+}
+
+// 電文とGeoJSONを書き出して索引に入れる
+// 同じ報数の電文があれば差し替え、取消の電文なら取り除く
+func (a *Archive) Put(advisory model.Advisory, featureCollection *geojson.FeatureCollection) error {
+	if advisory.InfoType == "取消" {
+		return a.Remove(advisory.EventID, advisory.Serial)
+	}
+	if a.readOnly {
+		return errArchiveReadOnly
+	}
+
+	entry := MakeArchiveEntry(advisory)
+	return a.withLock(true, func() error {
+		if err := a.refresh(); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(a.dir, advisory.EventID), 0755); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(advisory, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(a.dir, entry.AdvisoryPath), data, 0644); err != nil {
+			return err
+		}
+		if err := SaveFeatureCollectionToFile(filepath.Join(a.dir, entry.GeoJSONPath), featureCollection); err != nil {
+			return err
+		}
+		return a.append(archiveRecord{Op: "put", EventID: entry.EventID, Serial: entry.Serial, Entry: &entry})
+	})
+}
+
+var errArchiveReadOnly = errors.New("読み込み用に開いたアーカイブには書き込めません")
+
+// 電文を索引とディレクトリから取り除く
+func (a *Archive) Remove(eventID string, serial int) error {
+	if a.readOnly {
+		return errArchiveReadOnly
+	}
+	return a.withLock(true, func() error {
+		if err := a.refresh(); err != nil {
+			return err
+		}
+		entry, ok := a.entries[archiveKey(eventID, serial)]
+		if !ok {
+			return nil
+		}
+		if err := a.append(archiveRecord{Op: "remove", EventID: eventID, Serial: serial}); err != nil {
+			return err
+		}
+		for _, path := range []string{entry.AdvisoryPath, entry.GeoJSONPath} {
+			if err := os.Remove(filepath.Join(a.dir, path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// EventID・報数の順に全ての電文の索引を返す
+func (a *Archive) Entries() []model.ArchiveEntry {
+	entries := make([]model.ArchiveEntry, 0, len(a.entries))
+	for _, entry := range a.entries {
+		entries = append(entries, entry)
+	}
+	sortArchiveEntries(entries)
+	return entries
+}
+
+// 条件に合う電文の索引をEventID・報数の順に返す
+// 台風・時刻・範囲の索引で候補を絞ってから条件と照らし合わせる
+func (a *Archive) Query(query model.ArchiveQuery) []model.ArchiveEntry {
+	entries := []model.ArchiveEntry{}
+	for _, key := range a.index.candidates(query, a.entries) {
+		if entry := a.entries[key]; MatchArchiveEntry(entry, query) {
+			entries = append(entries, entry)
+		}
+	}
+	sortArchiveEntries(entries)
+	return entries
+}
+
+func sortArchiveEntries(entries []model.ArchiveEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].EventID != entries[j].EventID {
+			return entries[i].EventID < entries[j].EventID
+		}
+		return entries[i].Serial < entries[j].Serial
+	})
+}
+
+// 索引から電文を読み込む
+func (a *Archive) LoadAdvisory(entry model.ArchiveEntry) (model.Advisory, error) {
+	advisory := model.Advisory{}
+	data, err := os.ReadFile(filepath.Join(a.dir, entry.AdvisoryPath))
+	if err != nil {
+		return advisory, err
+	}
+	err = json.Unmarshal(data, &advisory)
+	return advisory, err
+}
+
+// 電文から索引を作る
+// 範囲は中心と全ての暴風域・強風域・予報円・暴風警戒域が入る緯度経度
+func MakeArchiveEntry(advisory model.Advisory) model.ArchiveEntry {
+	baseName := fmt.Sprintf("%03d", advisory.Serial)
+	entry := model.ArchiveEntry{
+		EventID:        advisory.EventID,
+		TyphoonNumber:  advisory.TyphoonNumber,
+		TyphoonName:    advisory.TyphoonName,
+		Serial:         advisory.Serial,
+		InfoType:       advisory.InfoType,
+		ReportDateTime: advisory.ReportDateTime,
+		SourcePath:     advisory.SourcePath,
+		AdvisoryPath:   filepath.Join(advisory.EventID, baseName+".json"),
+		GeoJSONPath:    filepath.Join(advisory.EventID, baseName+".geojson"),
+		Fixes:          []model.ArchiveFix{},
+	}
+
+	allPoints := []model.Point{}
+	for _, typhoon := range advisory.Typhoons {
+		points := []model.Point{{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude}}
+		for _, warningArea := range typhoon.WarningAreas {
+			points = append(points, CalcStormAreaPoints(MakeStormArea(typhoon, warningArea), 36)...)
+		}
+		allPoints = append(allPoints, points...)
+		entry.Fixes = append(entry.Fixes, model.ArchiveFix{
+			ValidTime: typhoon.ValidTime,
+			LeadHours: typhoon.LeadHours,
+			Bounds:    MakeMapBounds(points, 0),
+		})
+	}
+	sort.SliceStable(entry.Fixes, func(i, j int) bool {
+		return entry.Fixes[i].ValidTime.Before(entry.Fixes[j].ValidTime)
+	})
+	if len(entry.Fixes) > 0 {
+		entry.ValidFrom = entry.Fixes[0].ValidTime
+		entry.ValidTo = entry.Fixes[len(entry.Fixes)-1].ValidTime
+		entry.Bounds = MakeMapBounds(allPoints, 0)
+	}
+
+	return entry
+}
+
+// 索引が検索条件に合うか
+// ValidTimeとBoundsの両方がある場合は、その時刻の前後の実況・予報の範囲がBoundsにかかるかで判定する
+func MatchArchiveEntry(entry model.ArchiveEntry, query model.ArchiveQuery) bool {
+	if query.EventID != "" && entry.EventID != query.EventID {
+		return false
+	}
+	if query.Serial != 0 && entry.Serial != query.Serial {
+		return false
+	}
+
+	bounds := entry.Bounds
+	if query.ValidTime != nil {
+		fixBounds, ok := archiveBoundsAt(entry, *query.ValidTime)
+		if !ok {
+			return false
+		}
+		bounds = fixBounds
+	}
+	if query.Bounds != nil && !IntersectMapBounds(bounds, *query.Bounds) {
+		return false
+	}
+	return len(entry.Fixes) > 0
+}
+
+// 時刻tを挟む実況・予報の範囲を合わせた範囲
+// tが実況・予報の時刻の範囲外ならfalseを返す
+func archiveBoundsAt(entry model.ArchiveEntry, t time.Time) (model.MapBounds, bool) {
+	for i, fix := range entry.Fixes {
+		if fix.ValidTime.Equal(t) {
+			return fix.Bounds, true
+		}
+		if i > 0 && entry.Fixes[i-1].ValidTime.Before(t) && fix.ValidTime.After(t) {
+			return UnionMapBounds(entry.Fixes[i-1].Bounds, fix.Bounds), true
+		}
+	}
+	return model.MapBounds{}, false
+}
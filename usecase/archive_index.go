@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"math"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 範囲の索引の格子の大きさ(度)
+const archiveCellDegrees = 10
+
+// 範囲の索引の格子
+type archiveCell struct {
+	latitude  int
+	longitude int
+}
+
+// アーカイブの電文を台風・日・範囲から引く索引
+// 値はEventID/Serialのキーの集合で、検索条件に合うかは最後にMatchArchiveEntryで確かめる
+type archiveIndex struct {
+	byEvent map[string]archiveKeys      // EventID
+	byDay   map[int64]archiveKeys       // 最初〜最後の実況・予報の時刻がかかる日 (UTCの0時のUnix時間)
+	byCell  map[archiveCell]archiveKeys // Boundsがかかる格子
+}
+
+// EventID/Serialのキーの集合
+type archiveKeys map[string]bool
+
+func newArchiveIndex() *archiveIndex {
+	return &archiveIndex{
+		byEvent: map[string]archiveKeys{},
+		byDay:   map[int64]archiveKeys{},
+		byCell:  map[archiveCell]archiveKeys{},
+	}
+}
+
+func (x *archiveIndex) add(key string, entry model.ArchiveEntry) {
+	x.byEvent[entry.EventID] = x.byEvent[entry.EventID].with(key)
+	// 実況・予報の無い電文はどの時刻・範囲の条件にも合わない
+	if len(entry.Fixes) == 0 {
+		return
+	}
+	for _, day := range archiveDays(entry.ValidFrom, entry.ValidTo) {
+		x.byDay[day] = x.byDay[day].with(key)
+	}
+	for _, cell := range archiveCells(entry.Bounds) {
+		x.byCell[cell] = x.byCell[cell].with(key)
+	}
+}
+
+func (x *archiveIndex) remove(key string, entry model.ArchiveEntry) {
+	if x.byEvent[entry.EventID].without(key) {
+		delete(x.byEvent, entry.EventID)
+	}
+	if len(entry.Fixes) == 0 {
+		return
+	}
+	for _, day := range archiveDays(entry.ValidFrom, entry.ValidTo) {
+		if x.byDay[day].without(key) {
+			delete(x.byDay, day)
+		}
+	}
+	for _, cell := range archiveCells(entry.Bounds) {
+		if x.byCell[cell].without(key) {
+			delete(x.byCell, cell)
+		}
+	}
+}
+
+// 検索条件に合いうるキー
+// 使える索引のうち一番候補の少ないものを使い、条件が無ければ全てのキーを返す
+func (x *archiveIndex) candidates(query model.ArchiveQuery, entries map[string]model.ArchiveEntry) []string {
+	var best archiveKeys
+	found := false
+	use := func(keys archiveKeys) {
+		if !found || len(keys) < len(best) {
+			best, found = keys, true
+		}
+	}
+	if query.EventID != "" {
+		use(x.byEvent[query.EventID])
+	}
+	if query.ValidTime != nil {
+		use(x.byDay[archiveDay(*query.ValidTime)])
+	}
+	if query.Bounds != nil {
+		use(x.cellCandidates(*query.Bounds))
+	}
+
+	keys := []string{}
+	if !found {
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	for key := range best {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// 範囲にかかる格子の電文
+// 範囲の格子より索引の格子の方が少なければ、索引の格子を順に見る
+func (x *archiveIndex) cellCandidates(bounds model.MapBounds) archiveKeys {
+	cells := archiveCells(bounds)
+	keys := archiveKeys{}
+	if len(cells) > len(x.byCell) {
+		for cell, cellKeys := range x.byCell {
+			if IntersectMapBounds(archiveCellBounds(cell), bounds) {
+				for key := range cellKeys {
+					keys[key] = true
+				}
+			}
+		}
+		return keys
+	}
+	for _, cell := range cells {
+		for key := range x.byCell[cell] {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// キーを加えた集合 (nilなら新しく作る)
+func (k archiveKeys) with(key string) archiveKeys {
+	if k == nil {
+		k = archiveKeys{}
+	}
+	k[key] = true
+	return k
+}
+
+// キーを取り除き、空になったらtrueを返す
+func (k archiveKeys) without(key string) bool {
+	delete(k, key)
+	return len(k) == 0
+}
+
+func archiveDay(t time.Time) int64 {
+	return t.UTC().Truncate(24 * time.Hour).Unix()
+}
+
+// fromからtoまでにかかる日
+func archiveDays(from, to time.Time) []int64 {
+	days := []int64{}
+	for day := archiveDay(from); day <= archiveDay(to); day += 24 * 60 * 60 {
+		days = append(days, day)
+	}
+	return days
+}
+
+// 範囲にかかる格子
+// 格子の境目にかかる場合は両側の格子に入れる
+func archiveCells(bounds model.MapBounds) []archiveCell {
+	if math.IsInf(bounds.MinLatitude, 0) || math.IsInf(bounds.MaxLatitude, 0) || math.IsInf(bounds.MinLongitude, 0) || math.IsInf(bounds.MaxLongitude, 0) {
+		return nil
+	}
+	cells := []archiveCell{}
+	for latitude := archiveCellOf(bounds.MinLatitude); latitude <= archiveCellOf(bounds.MaxLatitude); latitude++ {
+		for longitude := archiveCellOf(bounds.MinLongitude); longitude <= archiveCellOf(bounds.MaxLongitude); longitude++ {
+			cells = append(cells, archiveCell{latitude: latitude, longitude: longitude})
+		}
+	}
+	return cells
+}
+
+func archiveCellOf(degrees float64) int {
+	return int(math.Floor(degrees / archiveCellDegrees))
+}
+
+func archiveCellBounds(cell archiveCell) model.MapBounds {
+	return model.MapBounds{
+		MinLatitude:  float64(cell.latitude * archiveCellDegrees),
+		MaxLatitude:  float64((cell.latitude + 1) * archiveCellDegrees),
+		MinLongitude: float64(cell.longitude * archiveCellDegrees),
+		MaxLongitude: float64((cell.longitude + 1) * archiveCellDegrees),
+	}
+}
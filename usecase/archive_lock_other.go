@@ -0,0 +1,14 @@
+//go:build !unix
+
+package usecase
+
+import "os"
+
+// flockの無い環境ではロックしない (同じアーカイブを同時に書き込むプロセスは1つにする)
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 書き込み側が排他している間は、読み込み側は書きかけの行を読まずに待つ
+func TestArchiveReaderWaitsForWriter(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	line := `{"op":"put","event_id":"TC2410","serial":1,"entry":{"event_id":"TC2410","serial":1,"fixes":[]}}` + "\n"
+	f, err := os.OpenFile(filepath.Join(dir, archiveLogName), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	done := make(chan *Archive)
+	errs := make(chan error, 1)
+	lockFile(writer.lock, true)
+	f.WriteString(line[:20])
+	go func() {
+		reader, err := OpenArchiveReadOnly(dir)
+		if err != nil {
+			errs <- err
+			return
+		}
+		done <- reader
+	}()
+	select {
+	case <-done:
+		t.Fatal("書き込み中なのに読み込み側が開けた")
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	f.WriteString(line[20:])
+	unlockFile(writer.lock)
+
+	select {
+	case reader := <-done:
+		defer reader.Close()
+		if got := archiveEntryKeys(reader.Entries()); !equalStrings(got, []string{"TC2410/1"}) {
+			t.Errorf("書き込みの後: %v", got)
+		}
+		if got := reader.Query(model.ArchiveQuery{EventID: "TC2410"}); len(got) != 0 {
+			t.Errorf("実況・予報の無い電文が見つかる: %v", archiveEntryKeys(got))
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("読み込み側が開けない")
+	}
+}
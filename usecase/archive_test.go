@@ -0,0 +1,396 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+var testArchiveBaseTime = time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)
+
+// 実況と24時間後の予報(北へ5度)の電文
+func makeTestArchiveAdvisory(eventID string, serial int, hours int, latitude, longitude float64) model.Advisory {
+	validTime := testArchiveBaseTime.Add(time.Duration(hours) * time.Hour)
+	return model.Advisory{
+		EventID:        eventID,
+		Serial:         serial,
+		InfoType:       "発表",
+		ReportDateTime: validTime,
+		TargetDateTime: validTime,
+		SourcePath:     eventID + ".xml",
+		Typhoons: []model.Typhoon{
+			{
+				Latitude: latitude, Longitude: longitude,
+				WarningAreas: []model.TyphoonWarningArea{{WarningAreaType: "強風域", WindSpeed: 15, CircleLongRadius: 100, CircleShortRadius: 100}},
+				ValidTime:    validTime, FixKind: model.FixKindAnalysis,
+			},
+			{
+				Latitude: latitude + 5, Longitude: longitude,
+				WarningAreas: []model.TyphoonWarningArea{{WarningAreaType: "予報円", CircleLongRadius: 150, CircleShortRadius: 150}},
+				ValidTime:    validTime.Add(24 * time.Hour), FixKind: model.FixKindForecast, LeadHours: 24,
+			},
+		},
+	}
+}
+
+func archiveEntryKeys(entries []model.ArchiveEntry) []string {
+	keys := []string{}
+	for _, entry := range entries {
+		keys = append(keys, archiveKey(entry.EventID, entry.Serial))
+	}
+	return keys
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestArchivePutRemoveQuery(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	advisories := []model.Advisory{
+		makeTestArchiveAdvisory("TC2410", 2, 6, 21, 130),
+		makeTestArchiveAdvisory("TC2410", 1, 0, 20, 130),
+		makeTestArchiveAdvisory("TC2411", 1, 0, 15, 160),
+	}
+	for _, advisory := range advisories {
+		if err := archive.Put(advisory, geojson.NewFeatureCollection()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	at := func(hours int) *time.Time {
+		t := testArchiveBaseTime.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	tests := []struct {
+		name  string
+		query model.ArchiveQuery
+		want  []string
+	}{
+		{"全て", model.ArchiveQuery{}, []string{"TC2410/1", "TC2410/2", "TC2411/1"}},
+		{"台風", model.ArchiveQuery{EventID: "TC2410"}, []string{"TC2410/1", "TC2410/2"}},
+		{"台風と報数", model.ArchiveQuery{EventID: "TC2410", Serial: 2}, []string{"TC2410/2"}},
+		{"無い台風", model.ArchiveQuery{EventID: "TC2499"}, []string{}},
+		{"時刻", model.ArchiveQuery{ValidTime: at(3)}, []string{"TC2410/1", "TC2411/1"}},
+		{"翌日の0時", model.ArchiveQuery{ValidTime: at(24)}, []string{"TC2410/1", "TC2410/2", "TC2411/1"}},
+		{"翌日の時刻", model.ArchiveQuery{ValidTime: at(28)}, []string{"TC2410/2"}},
+		{"範囲外の時刻", model.ArchiveQuery{ValidTime: at(48)}, []string{}},
+		{"範囲", model.ArchiveQuery{Bounds: &model.MapBounds{MinLatitude: 10, MaxLatitude: 20, MinLongitude: 155, MaxLongitude: 165}}, []string{"TC2411/1"}},
+		// 格子の境目(130度)をまたぐ範囲
+		{"格子の境目", model.ArchiveQuery{Bounds: &model.MapBounds{MinLatitude: 24, MaxLatitude: 27, MinLongitude: 129.5, MaxLongitude: 130.5}}, []string{"TC2410/1", "TC2410/2"}},
+		{"広い範囲", model.ArchiveQuery{Bounds: &model.MapBounds{MinLatitude: -80, MaxLatitude: 80, MinLongitude: -180, MaxLongitude: 360}}, []string{"TC2410/1", "TC2410/2", "TC2411/1"}},
+		// 0時の時点ではTC2410第1報は20度付近にあり、26度付近にはかからない
+		{"時刻と範囲", model.ArchiveQuery{ValidTime: at(0), Bounds: &model.MapBounds{MinLatitude: 25.5, MaxLatitude: 27, MinLongitude: 129, MaxLongitude: 131}}, []string{}},
+		{"時刻と範囲(予報)", model.ArchiveQuery{ValidTime: at(30), Bounds: &model.MapBounds{MinLatitude: 25.5, MaxLatitude: 27, MinLongitude: 129, MaxLongitude: 131}}, []string{"TC2410/2"}},
+	}
+	check := func(archive *Archive) {
+		t.Helper()
+		for _, tt := range tests {
+			if got := archiveEntryKeys(archive.Query(tt.query)); !equalStrings(got, tt.want) {
+				t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+	check(archive)
+
+	// 同じ報数の電文は差し替え、索引も新しい位置になる
+	moved := makeTestArchiveAdvisory("TC2411", 1, 0, 35, 170)
+	if err := archive.Put(moved, geojson.NewFeatureCollection()); err != nil {
+		t.Fatal(err)
+	}
+	if got := archive.Query(model.ArchiveQuery{Bounds: &model.MapBounds{MinLatitude: 10, MaxLatitude: 20, MinLongitude: 155, MaxLongitude: 165}}); len(got) != 0 {
+		t.Errorf("差し替える前の範囲で見つかる: %v", archiveEntryKeys(got))
+	}
+	if err := archive.Put(advisories[2], geojson.NewFeatureCollection()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 開き直しても記録から同じ索引になる
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err = OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(archive)
+	if !archive.Has(advisories[0]) {
+		t.Error("入れた電文が無い")
+	}
+	entry := archive.Query(model.ArchiveQuery{EventID: "TC2410", Serial: 1})[0]
+	loaded, err := archive.LoadAdvisory(entry)
+	if err != nil || loaded.EventID != "TC2410" || loaded.Serial != 1 || len(loaded.Typhoons) != 2 {
+		t.Errorf("電文を読み込めない: %+v, %v", loaded, err)
+	}
+
+	// 取消の電文は索引とディレクトリから取り除く
+	cancel := model.Advisory{EventID: "TC2410", Serial: 1, InfoType: "取消"}
+	if err := archive.Put(cancel, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := archiveEntryKeys(archive.Query(model.ArchiveQuery{EventID: "TC2410"})); !equalStrings(got, []string{"TC2410/2"}) {
+		t.Errorf("取消の後: %v", got)
+	}
+	if got := archiveEntryKeys(archive.Query(model.ArchiveQuery{ValidTime: at(3)})); !equalStrings(got, []string{"TC2411/1"}) {
+		t.Errorf("取消の後の時刻の索引: %v", got)
+	}
+	for _, path := range []string{entry.AdvisoryPath, entry.GeoJSONPath} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s が残っている", path)
+		}
+	}
+	// 入っていない電文の取消は何もしない
+	if err := archive.Remove("TC2499", 1); err != nil {
+		t.Error(err)
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err = OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if got := archiveEntryKeys(archive.Entries()); !equalStrings(got, []string{"TC2410/2", "TC2411/1"}) {
+		t.Errorf("取消の後に開き直す: %v", got)
+	}
+}
+
+func TestArchiveLog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, archiveLogName)
+	archive, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	advisory := makeTestArchiveAdvisory("TC2410", 1, 0, 20, 130)
+	if err := archive.Put(advisory, geojson.NewFeatureCollection()); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+
+	// 書きかけの最後の行は切り捨てる
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"put","event_id":"TC2410","ser`)
+	f.Close()
+	archive, err = OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := archiveEntryKeys(archive.Entries()); !equalStrings(got, []string{"TC2410/1"}) {
+		t.Errorf("書きかけの行の後: %v", got)
+	}
+
+	// 入れる・取り除くたびに1行ずつ追記し、全体は書き直さない
+	for serial := 2; serial <= 40; serial++ {
+		if err := archive.Put(makeTestArchiveAdvisory("TC2410", serial, serial, 20, 130), geojson.NewFeatureCollection()); err != nil {
+			t.Fatal(err)
+		}
+		if err := archive.Remove("TC2410", serial); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive.Close()
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 79 {
+		t.Errorf("記録の行数 = %d, want 79", lines)
+	}
+
+	// 取り除いた電文の行が多ければ、開くときに詰め直す
+	archive, err = OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	data, err = os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 1 {
+		t.Errorf("詰め直した記録の行数 = %d, want 1", lines)
+	}
+	if err := archive.Put(makeTestArchiveAdvisory("TC2411", 1, 0, 15, 160), geojson.NewFeatureCollection()); err != nil {
+		t.Fatal(err)
+	}
+	if got := archiveEntryKeys(archive.Query(model.ArchiveQuery{ValidTime: &testArchiveBaseTime})); !equalStrings(got, []string{"TC2410/1", "TC2411/1"}) {
+		t.Errorf("詰め直した後: %v", got)
+	}
+}
+
+func TestArchiveImportLegacyIndex(t *testing.T) {
+	dir := t.TempDir()
+	entries := []model.ArchiveEntry{
+		MakeArchiveEntry(makeTestArchiveAdvisory("TC2410", 1, 0, 20, 130)),
+		MakeArchiveEntry(makeTestArchiveAdvisory("TC2410", 2, 6, 21, 130)),
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, archiveLegacyIndexName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if got := archiveEntryKeys(archive.Query(model.ArchiveQuery{EventID: "TC2410"})); !equalStrings(got, []string{"TC2410/1", "TC2410/2"}) {
+		t.Errorf("index.jsonから移した電文: %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, archiveLogName)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestArchiveWriterAndReader(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, archiveLogName)
+	put := func(archive *Archive, serial int) {
+		t.Helper()
+		if err := archive.Put(makeTestArchiveAdvisory("TC2410", serial, serial, 20, 130), geojson.NewFeatureCollection()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keysOf := func(archive *Archive) []string {
+		return archiveEntryKeys(archive.Query(model.ArchiveQuery{EventID: "TC2410"}))
+	}
+
+	// pollのように開いたままにする
+	poller, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+	put(poller, 1)
+
+	// 読み込み用に開いても記録を書き換えない (止まったプロセスの書きかけの行も残す)
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"put","event_id":"TC2410","ser`)
+	f.Close()
+	before, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := OpenArchiveReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if got := keysOf(reader); !equalStrings(got, []string{"TC2410/1"}) {
+		t.Errorf("読み込み用: %v", got)
+	}
+	if after, _ := os.ReadFile(logPath); !bytes.Equal(before, after) {
+		t.Error("読み込み用に開いて記録が変わった")
+	}
+	if err := reader.Put(makeTestArchiveAdvisory("TC2410", 9, 0, 20, 130), geojson.NewFeatureCollection()); err == nil {
+		t.Error("読み込み用のアーカイブに書き込める")
+	}
+
+	// 書き込み側は書きかけの行を切り捨ててから追記し、読み込み側はRefreshで読み込む
+	put(poller, 2)
+	if err := reader.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if got := keysOf(reader); !equalStrings(got, []string{"TC2410/1", "TC2410/2"}) {
+		t.Errorf("Refreshの後: %v", got)
+	}
+
+	// 別のプロセス(archiveコマンド)が詰め直しても、開いたままの書き込み側の記録は失われない
+	for serial := 10; serial < 50; serial++ {
+		put(poller, serial)
+		if err := poller.Remove("TC2410", serial); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(logPath)
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Errorf("詰め直した記録の行数 = %d, want 2", lines)
+	}
+	put(poller, 3)
+	put(other, 4)
+	other.Close()
+	if err := reader.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"TC2410/1", "TC2410/2", "TC2410/3", "TC2410/4"}
+	if got := keysOf(reader); !equalStrings(got, want) {
+		t.Errorf("詰め直した後の読み込み側: %v, want %v", got, want)
+	}
+	put(poller, 5)
+	if got := keysOf(poller); !equalStrings(got, append(want, "TC2410/5")) {
+		t.Errorf("詰め直した後の書き込み側: %v", got)
+	}
+
+	// 2つの書き込み側と読み込み側を同時に動かしても、行が混ざらず全て残る
+	second, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	errs := make(chan error, 3)
+	write := func(archive *Archive, from int) {
+		for serial := from; serial < from+20; serial++ {
+			if err := archive.Put(makeTestArchiveAdvisory("TC2411", serial, 0, 15, 160), geojson.NewFeatureCollection()); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}
+	go write(poller, 100)
+	go write(second, 200)
+	go func() {
+		for i := 0; i < 20; i++ {
+			if err := reader.Refresh(); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reader.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(reader.Query(model.ArchiveQuery{EventID: "TC2411"})); got != 40 {
+		t.Errorf("同時に書き込んだ電文 = %d, want 40", got)
+	}
+}
@@ -0,0 +1,301 @@
+package usecase
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+)
+
+const (
+	knotToMeterPerSecond = 0.514444
+	nauticalMileToKm     = 1.852
+)
+
+// 象限の並び(北東・南東・南西・北西)に対応する16方位
+var quadrantDirections = [4]string{"北東", "南東", "南西", "北西"}
+
+// ATCFのa-deck・b-deckを読み込む
+func ReadATCFDeck(path string) ([]model.ATCFRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := ParseATCFDeck(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return records, nil
+}
+
+// ATCFのカンマ区切りの行をパースする
+// 例: WP, 10, 2024081900, 03, JTWC,   0, 250N, 1280E,  35,  995, TS,  34, NEQ,   60,   50,   40,   60, ...
+func ParseATCFDeck(r io.Reader) ([]model.ATCFRecord, error) {
+	records := []model.ATCFRecord{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("%d行目: 列が足りません", line)
+		}
+		field := func(i int) string {
+			if i >= len(fields) {
+				return ""
+			}
+			return fields[i]
+		}
+		intField := func(i int) int {
+			v, _ := strconv.Atoi(field(i))
+			return v
+		}
+
+		cycloneNumber, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: 無効な番号: %s", line, fields[1])
+		}
+		initTime, err := time.Parse("2006010215", fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: 無効な時刻: %s", line, fields[2])
+		}
+		tau, err := strconv.Atoi(fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: 無効な予報時間: %s", line, fields[5])
+		}
+		latitude, err := parseATCFCoordinate(fields[6], "N", "S")
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %v", line, err)
+		}
+		longitude, err := parseATCFCoordinate(fields[7], "E", "W")
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %v", line, err)
+		}
+
+		records = append(records, model.ATCFRecord{
+			Basin:         strings.ToUpper(fields[0]),
+			CycloneNumber: cycloneNumber,
+			InitTime:      initTime,
+			Tech:          strings.ToUpper(fields[4]),
+			Tau:           tau,
+			Point:         model.Point{Latitude: latitude, Longitude: longitude},
+			MaxWind:       intField(8),
+			Pressure:      intField(9),
+			Class:         field(10),
+			RadiusWind:    intField(11),
+			WindCode:      strings.ToUpper(field(12)),
+			Radii:         [4]int{intField(13), intField(14), intField(15), intField(16)},
+			StormName:     field(27),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// "250N"・"1280E"のような0.1度単位の緯度・経度をパースする
+// 東経180度を越える西経は、日付変更線をまたいでもつながるように180度より大きい東経にする
+func parseATCFCoordinate(s, positive, negative string) (float64, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("無効な緯度・経度: %s", s)
+	}
+	hemisphere := strings.ToUpper(s[len(s)-1:])
+	v, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || (hemisphere != positive && hemisphere != negative) {
+		return 0, fmt.Errorf("無効な緯度・経度: %s", s)
+	}
+	degrees := float64(v) / 10
+	if hemisphere == negative {
+		if negative == "W" {
+			return 360 - degrees, nil
+		}
+		return -degrees, nil
+	}
+	return degrees, nil
+}
+
+// 指定したtechの行を初期時刻ごとの電文にまとめ、台風(EventIDは例えばWP102024)にする
+// 34ktの半径は強風域(15m/s)、50ktは暴風域(25m/s)、64ktは64kt域(33m/s)として、象限ごとの半径で入れる
+// ATCFに予報円は無いので、coneRadiiに何時間後ごとの予報円の半径(km)があれば、その間を線形に補間して予報円を入れる
+func MakeATCFStorm(records []model.ATCFRecord, tech string, coneRadii map[int]int) (*model.Storm, error) {
+	tech = strings.ToUpper(tech)
+	byInitTime := map[time.Time][]model.ATCFRecord{}
+	for _, record := range records {
+		if record.Tech == tech {
+			byInitTime[record.InitTime] = append(byInitTime[record.InitTime], record)
+		}
+	}
+	if len(byInitTime) == 0 {
+		return nil, fmt.Errorf("%sの行がありません", tech)
+	}
+	initTimes := make([]time.Time, 0, len(byInitTime))
+	for initTime := range byInitTime {
+		initTimes = append(initTimes, initTime)
+	}
+	sort.Slice(initTimes, func(i, j int) bool {
+		return initTimes[i].Before(initTimes[j])
+	})
+
+	first := byInitTime[initTimes[0]][0]
+	storm := &model.Storm{
+		EventID:    fmt.Sprintf("%s%02d%04d", first.Basin, first.CycloneNumber, first.InitTime.Year()),
+		Advisories: []model.Advisory{},
+	}
+	for i, initTime := range initTimes {
+		advisory := model.Advisory{
+			EventID:        storm.EventID,
+			Serial:         i + 1,
+			InfoType:       "発表",
+			ReportDateTime: initTime,
+			TargetDateTime: initTime,
+			Typhoons:       makeATCFTyphoons(byInitTime[initTime], coneRadii),
+		}
+		for _, record := range byInitTime[initTime] {
+			if record.StormName != "" {
+				advisory.TyphoonName = record.StormName
+			}
+		}
+		if advisory.TyphoonName != "" {
+			storm.TyphoonName = advisory.TyphoonName
+		}
+		storm.Advisories = append(storm.Advisories, advisory)
+	}
+
+	return storm, nil
+}
+
+// 1つの初期時刻の行を予報時間ごとのTyphoonにする
+func makeATCFTyphoons(records []model.ATCFRecord, coneRadii map[int]int) []model.Typhoon {
+	byTau := map[int][]model.ATCFRecord{}
+	taus := []int{}
+	for _, record := range records {
+		if _, ok := byTau[record.Tau]; !ok {
+			taus = append(taus, record.Tau)
+		}
+		byTau[record.Tau] = append(byTau[record.Tau], record)
+	}
+	sort.Ints(taus)
+
+	typhoons := []model.Typhoon{}
+	for _, tau := range taus {
+		record := byTau[tau][0]
+		typhoon := model.Typhoon{
+			TargetTimestamp:           record.InitTime.Add(time.Duration(tau) * time.Hour).Format(TargetTimestampLayout),
+			TargetTimestampType:       "実況",
+			Latitude:                  record.Point.Latitude,
+			Longitude:                 record.Point.Longitude,
+			CentralPressure:           record.Pressure,
+			MaxWindSpeedNearTheCenter: int(math.Round(float64(record.MaxWind) * knotToMeterPerSecond)),
+			TyphoonClass:              record.Class,
+			WarningAreas:              []model.TyphoonWarningArea{},
+			ValidTime:                 record.InitTime.Add(time.Duration(tau) * time.Hour),
+			FixKind:                   model.FixKindAnalysis,
+			LeadHours:                 tau,
+		}
+		if tau > 0 {
+			typhoon.TargetTimestampType = fmt.Sprintf("予報 %d時間後", tau)
+			typhoon.FixKind = model.FixKindForecast
+			if radius := atcfConeRadius(coneRadii, tau); radius > 0 {
+				typhoon.WarningAreas = append(typhoon.WarningAreas, model.TyphoonWarningArea{
+					WarningAreaType:  "予報円",
+					CircleLongRadius: radius, CircleShortRadius: radius,
+				})
+			}
+		}
+		for _, record := range byTau[tau] {
+			if warningArea, ok := makeATCFWarningArea(record); ok {
+				typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+			}
+		}
+		typhoons = append(typhoons, typhoon)
+	}
+
+	return typhoons
+}
+
+func makeATCFWarningArea(record model.ATCFRecord) (model.TyphoonWarningArea, bool) {
+	radii := record.Radii
+	if record.WindCode == "AAA" {
+		radii = [4]int{radii[0], radii[0], radii[0], radii[0]}
+	}
+	return makeKnotRadiiWarningArea(record.RadiusWind, radii)
+}
+
+// 34・50・64ktの象限ごとの半径(nm)を、強風域・暴風域・64kt域にする
+// 象限ごとの半径(km)を入れ、象限ごとの半径を使わない処理のために、一番広い象限の向きの長い方の半径と、その反対の象限の短い方の半径も入れる
+func makeKnotRadiiWarningArea(radiusWind int, radii [4]int) (model.TyphoonWarningArea, bool) {
+	var warningAreaType string
+	var windSpeed int
+	switch radiusWind {
+	case 34:
+		warningAreaType, windSpeed = "強風域", 15
+	case 50:
+		warningAreaType, windSpeed = "暴風域", 25
+	case 64:
+		warningAreaType, windSpeed = "64kt域", 33
+	default:
+		return model.TyphoonWarningArea{}, false
+	}
+
+	longest := 0
+	for i := range radii {
+		if radii[i] > radii[longest] {
+			longest = i
+		}
+	}
+	if radii[longest] == 0 {
+		return model.TyphoonWarningArea{}, false
+	}
+	opposite := (longest + 2) % 4
+	quadrantRadii := make([]int, 4)
+	for i, radius := range radii {
+		quadrantRadii[i] = int(math.Round(float64(radius) * nauticalMileToKm))
+	}
+
+	return model.TyphoonWarningArea{
+		WarningAreaType:      warningAreaType,
+		WindSpeed:            windSpeed,
+		CircleLongDirection:  quadrantDirections[longest],
+		CircleLongRadius:     quadrantRadii[longest],
+		CircleShortDirection: quadrantDirections[opposite],
+		CircleShortRadius:    quadrantRadii[opposite],
+		QuadrantRadii:        quadrantRadii,
+	}, true
+}
+
+// 何時間後ごとの予報円の半径の間を線形に補間する (0時間後は半径0とする)
+// 一番長い予報時間より後は0を返す
+func atcfConeRadius(coneRadii map[int]int, tau int) int {
+	if radius, ok := coneRadii[tau]; ok {
+		return radius
+	}
+	fromTau, fromRadius := 0, 0
+	toTau, toRadius := -1, 0
+	for t, radius := range coneRadii {
+		if t < tau && t > fromTau {
+			fromTau, fromRadius = t, radius
+		}
+		if t > tau && (toTau < 0 || t < toTau) {
+			toTau, toRadius = t, radius
+		}
+	}
+	if toTau < 0 {
+		return 0
+	}
+	return fromRadius + int(math.Round(float64(toRadius-fromRadius)*float64(tau-fromTau)/float64(toTau-fromTau)))
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// a-deck(JTWC)とb-deck(BEST)を合わせたもの
+// 0時間後は34・50・64ktの象限ごとの行、24時間後は全周(AAA)の34ktの行
+const testATCFDeck = `WP, 10, 2024081900, 03, JTWC,   0, 250N, 1280E,  70,  975, TY,  34, NEQ,   90,   80,   60,   70, 1004,  240,  20,  85,  15,   W,   0,    ,   0,   0, JONGDARI
+WP, 10, 2024081900, 03, JTWC,   0, 250N, 1280E,  70,  975, TY,  50, NEQ,   40,   35,   30,   30, 1004,  240,  20,  85,  15,   W,   0,    ,   0,   0, JONGDARI
+WP, 10, 2024081900, 03, JTWC,   0, 250N, 1280E,  70,  975, TY,  64, NEQ,   20,   15,   15,   20, 1004,  240,  20,  85,  15,   W,   0,    ,   0,   0, JONGDARI
+WP, 10, 2024081900, 03, JTWC,  24, 305N, 1265E,  55,  985, TS,  34, AAA,   80,    0,    0,    0, 1004,  240,  20,  85,  15,   W,   0,    ,   0,   0, JONGDARI
+
+WP, 10, 2024081900,   , BEST,   0, 251N, 1279E,  70,  976, TY,  34, NEQ,   90,   80,   60,   70
+`
+
+func TestParseATCFDeck(t *testing.T) {
+	records, err := ParseATCFDeck(strings.NewReader(testATCFDeck))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("行 = %d, want 5", len(records))
+	}
+
+	first := records[0]
+	if first.Basin != "WP" || first.CycloneNumber != 10 || first.Tech != "JTWC" || first.Tau != 0 {
+		t.Errorf("1行目 = %+v", first)
+	}
+	if !first.InitTime.Equal(time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("初期時刻 = %s", first.InitTime)
+	}
+	if first.Point != (model.Point{Latitude: 25, Longitude: 128}) || first.MaxWind != 70 || first.Pressure != 975 {
+		t.Errorf("位置・強さ = %+v", first)
+	}
+	if first.RadiusWind != 34 || first.WindCode != "NEQ" || first.Radii != [4]int{90, 80, 60, 70} || first.StormName != "JONGDARI" {
+		t.Errorf("半径 = %+v", first)
+	}
+	if records[3].WindCode != "AAA" || records[3].Tau != 24 {
+		t.Errorf("4行目 = %+v", records[3])
+	}
+	// b-deckは名前などの列が無くても読める
+	best := records[4]
+	if best.Tech != "BEST" || best.StormName != "" || best.Radii != [4]int{90, 80, 60, 70} {
+		t.Errorf("b-deck = %+v", best)
+	}
+
+	if _, err := ParseATCFDeck(strings.NewReader("WP, 10, 2024081900, 03, JTWC, 0, 250N\n")); err == nil {
+		t.Error("列が足りない行でエラーにならない")
+	}
+	if _, err := ParseATCFDeck(strings.NewReader("WP, 10, 2024081900, 03, JTWC, 0, 250X, 1280E\n")); err == nil {
+		t.Error("無効な緯度でエラーにならない")
+	}
+}
+
+func TestParseATCFCoordinate(t *testing.T) {
+	tests := []struct {
+		in       string
+		positive string
+		negative string
+		want     float64
+		wantErr  bool
+	}{
+		{"250N", "N", "S", 25, false},
+		{"125S", "N", "S", -12.5, false},
+		{"1280E", "E", "W", 128, false},
+		{"1795W", "E", "W", 180.5, false}, // 日付変更線をまたいでもつながる
+		{"1550W", "E", "W", 205, false},
+		{"250E", "N", "S", 0, true},
+		{"N", "N", "S", 0, true},
+		{"xxN", "N", "S", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseATCFCoordinate(tt.in, tt.positive, tt.negative)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseATCFCoordinate(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseATCFCoordinate(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMakeATCFStormWindRadii(t *testing.T) {
+	records, err := ParseATCFDeck(strings.NewReader(testATCFDeck))
+	if err != nil {
+		t.Fatal(err)
+	}
+	storm, err := MakeATCFStorm(records, "jtwc", map[int]int{24: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storm.EventID != "WP102024" || storm.TyphoonName != "JONGDARI" || len(storm.Advisories) != 1 {
+		t.Fatalf("台風 = %+v", storm)
+	}
+	typhoons := storm.Advisories[0].Typhoons
+	if len(typhoons) != 2 || typhoons[0].FixKind != model.FixKindAnalysis || typhoons[1].FixKind != model.FixKindForecast {
+		t.Fatalf("実況・予報 = %+v", typhoons)
+	}
+
+	areas := map[string]model.TyphoonWarningArea{}
+	for _, warningArea := range typhoons[0].WarningAreas {
+		areas[warningArea.WarningAreaType] = warningArea
+	}
+	// NEQは象限ごとの半径(nm)をkmにする
+	tests := []struct {
+		warningAreaType string
+		windSpeed       int
+		quadrantRadii   []int
+		longDirection   string
+	}{
+		{"強風域", 15, []int{167, 148, 111, 130}, "北東"},
+		{"暴風域", 25, []int{74, 65, 56, 56}, "北東"},
+		{"64kt域", 33, []int{37, 28, 28, 37}, "北東"},
+	}
+	for _, tt := range tests {
+		area, ok := areas[tt.warningAreaType]
+		if !ok {
+			t.Errorf("%s が無い", tt.warningAreaType)
+			continue
+		}
+		if area.WindSpeed != tt.windSpeed || area.CircleLongDirection != tt.longDirection {
+			t.Errorf("%s = %+v", tt.warningAreaType, area)
+		}
+		for i, radius := range tt.quadrantRadii {
+			if area.QuadrantRadii[i] != radius {
+				t.Errorf("%s の象限の半径 = %v, want %v", tt.warningAreaType, area.QuadrantRadii, tt.quadrantRadii)
+				break
+			}
+		}
+	}
+
+	// AAAは全周同じ半径
+	forecast := typhoons[1]
+	for _, warningArea := range forecast.WarningAreas {
+		switch warningArea.WarningAreaType {
+		case "強風域":
+			for _, radius := range warningArea.QuadrantRadii {
+				if radius != 148 {
+					t.Errorf("AAAの象限の半径 = %v, want 全て148", warningArea.QuadrantRadii)
+					break
+				}
+			}
+		case "予報円":
+			if warningArea.CircleLongRadius != 90 {
+				t.Errorf("予報円の半径 = %d, want 90", warningArea.CircleLongRadius)
+			}
+		}
+	}
+}
+
+func TestATCFConeRadius(t *testing.T) {
+	coneRadii := map[int]int{24: 90, 48: 160, 72: 240}
+	tests := []struct {
+		tau  int
+		want int
+	}{
+		{0, 0},
+		{12, 45}, // 0時間後の0との間
+		{24, 90},
+		{36, 125},
+		{60, 200},
+		{72, 240},
+		{96, 0}, // 一番長い予報時間より後は予報円を入れない
+		{120, 0},
+	}
+	for _, tt := range tests {
+		if got := atcfConeRadius(coneRadii, tt.tau); got != tt.want {
+			t.Errorf("atcfConeRadius(%d) = %d, want %d", tt.tau, got, tt.want)
+		}
+	}
+	if got := atcfConeRadius(nil, 24); got != 0 {
+		t.Errorf("指定が無い場合 = %d, want 0", got)
+	}
+}
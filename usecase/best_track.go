@@ -0,0 +1,349 @@
+package usecase
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 気象庁のベストトラックの長い方の半径の向き (0は半径0、9は円)
+var bestTrackDirections = map[int]string{1: "北東", 2: "東", 3: "南東", 4: "南", 5: "南西", 6: "西", 7: "北西", 8: "北", 9: ""}
+
+// 向きの反対の向き
+var oppositeDirections = map[string]string{
+	"北東": "南西", "東": "西", "南東": "北西", "南": "北", "南西": "北東", "西": "東", "北西": "南東", "北": "南", "": "",
+}
+
+// ベストトラックの解析値を、1つの解析を1つの電文とした台風にする
+// 電文と同じく報数の順に並ぶので、実況経路・実況の通過範囲をそのまま作れる
+func MakeBestTrackStorm(eventID, typhoonNumber, typhoonName string, typhoons []model.Typhoon) *model.Storm {
+	sort.SliceStable(typhoons, func(i, j int) bool {
+		return typhoons[i].ValidTime.Before(typhoons[j].ValidTime)
+	})
+
+	storm := &model.Storm{
+		EventID:       eventID,
+		TyphoonNumber: typhoonNumber,
+		TyphoonName:   typhoonName,
+		Advisories:    []model.Advisory{},
+	}
+	for i, typhoon := range typhoons {
+		storm.Advisories = append(storm.Advisories, model.Advisory{
+			EventID:        eventID,
+			Serial:         i + 1,
+			InfoType:       "発表",
+			ReportDateTime: typhoon.ValidTime,
+			TargetDateTime: typhoon.ValidTime,
+			TyphoonNumber:  typhoonNumber,
+			TyphoonName:    typhoonName,
+			Typhoons:       []model.Typhoon{typhoon},
+		})
+	}
+	return storm
+}
+
+// ベストトラックの1つの解析
+func makeBestTrackTyphoon(validTime time.Time, point model.Point, pressure, maxWindKnots int, typhoonClass string) model.Typhoon {
+	return model.Typhoon{
+		TargetTimestamp:           validTime.Format(TargetTimestampLayout),
+		TargetTimestampType:       "実況",
+		Latitude:                  point.Latitude,
+		Longitude:                 point.Longitude,
+		CentralPressure:           pressure,
+		MaxWindSpeedNearTheCenter: int(math.Round(float64(maxWindKnots) * knotToMeterPerSecond)),
+		TyphoonClass:              typhoonClass,
+		WarningAreas:              []model.TyphoonWarningArea{},
+		ValidTime:                 validTime,
+		FixKind:                   model.FixKindAnalysis,
+	}
+}
+
+// 気象庁のベストトラックの長い方・短い方の半径(nm)を円にする
+// 50ktは暴風域(25m/s)、30ktは強風域(15m/s)とする
+func makeBestTrackWarningArea(warningAreaType string, windSpeed, direction, longRadius, shortRadius int) (model.TyphoonWarningArea, bool) {
+	longDirection, ok := bestTrackDirections[direction]
+	if !ok || longRadius == 0 {
+		return model.TyphoonWarningArea{}, false
+	}
+	if direction == 9 {
+		shortRadius = longRadius
+	}
+	return model.TyphoonWarningArea{
+		WarningAreaType:      warningAreaType,
+		WindSpeed:            windSpeed,
+		CircleLongDirection:  longDirection,
+		CircleLongRadius:     int(math.Round(float64(longRadius) * nauticalMileToKm)),
+		CircleShortDirection: oppositeDirections[longDirection],
+		CircleShortRadius:    int(math.Round(float64(shortRadius) * nauticalMileToKm)),
+	}, true
+}
+
+// 気象庁のベストトラック(RSMC Tokyo、bst_all.txt)を読み込む
+// 66666で始まるヘッダ行に続いて、その台風の解析の行が並ぶ
+func ReadRSMCBestTrack(path string) ([]*model.Storm, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	storms, err := ParseRSMCBestTrack(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return storms, nil
+}
+
+// 例:
+// 66666 2410  039 0010 2410 0 6 JONGDARI                  20240920
+// 24081900 002 3 250 1280  994     35 00000 0000 30150 0100
+func ParseRSMCBestTrack(r io.Reader) ([]*model.Storm, error) {
+	storms := []*model.Storm{}
+
+	var eventID, typhoonNumber, typhoonName string
+	var typhoons []model.Typhoon
+	flush := func() {
+		if len(typhoons) > 0 {
+			storms = append(storms, MakeBestTrackStorm(eventID, typhoonNumber, typhoonName, typhoons))
+		}
+		typhoons = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "66666" {
+			flush()
+			if len(fields) < 5 {
+				return nil, fmt.Errorf("%d行目: ヘッダの列が足りません", line)
+			}
+			// EventIDは電文と同じく、TCに年の下2桁と、その年の熱帯低気圧の通し番号を続けたもの
+			typhoonNumber = fields[1]
+			eventID = "TC" + typhoonNumber
+			if len(fields[3]) == 4 && len(typhoonNumber) == 4 {
+				eventID = "TC" + typhoonNumber[:2] + fields[3][2:]
+			}
+			typhoonName = ""
+			if len(fields) >= 9 {
+				typhoonName = fields[7]
+			}
+			continue
+		}
+
+		typhoon, err := parseRSMCBestTrackLine(fields)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %v", line, err)
+		}
+		typhoons = append(typhoons, typhoon)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return storms, nil
+}
+
+// 解析の行: 時刻(YYMMDDHH) 002 階級 緯度 経度 中心気圧 [最大風速(kt) [50ktの向き・長い方の半径 短い方の半径 30ktの向き・長い方の半径 短い方の半径]]
+func parseRSMCBestTrackLine(fields []string) (model.Typhoon, error) {
+	if len(fields) < 6 {
+		return model.Typhoon{}, fmt.Errorf("列が足りません")
+	}
+	validTime, err := time.Parse("06010215", fields[0])
+	if err != nil {
+		return model.Typhoon{}, fmt.Errorf("無効な時刻: %s", fields[0])
+	}
+	// 2桁の年は1951年から始まるので、未来になる年は1900年代にする
+	if validTime.Year() > time.Now().Year() {
+		validTime = validTime.AddDate(-100, 0, 0)
+	}
+	values := make([]int, len(fields))
+	for i := 2; i < len(fields); i++ {
+		if fields[i] == "#" {
+			// 上陸・通過の印
+			fields = fields[:i]
+			break
+		}
+		if values[i], err = strconv.Atoi(fields[i]); err != nil {
+			return model.Typhoon{}, fmt.Errorf("無効な値: %s", fields[i])
+		}
+	}
+
+	grade := values[2]
+	point := model.Point{Latitude: float64(values[3]) / 10, Longitude: float64(values[4]) / 10}
+	maxWind := 0
+	if len(fields) > 6 {
+		maxWind = values[6]
+	}
+	typhoon := makeBestTrackTyphoon(validTime, point, values[5], maxWind, rsmcGradeClass(grade))
+
+	if len(fields) >= 11 {
+		// 向きと長い方の半径は1桁+4桁で続けて書かれている
+		if warningArea, ok := makeBestTrackWarningArea("暴風域", 25, values[7]/10000, values[7]%10000, values[8]); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		}
+		if warningArea, ok := makeBestTrackWarningArea("強風域", 15, values[9]/10000, values[9]%10000, values[10]); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		}
+	}
+
+	return typhoon, nil
+}
+
+// 気象庁のベストトラックの階級
+func rsmcGradeClass(grade int) string {
+	switch grade {
+	case 2:
+		return "熱帯低気圧(TD)"
+	case 3:
+		return "台風(TS)"
+	case 4:
+		return "台風(STS)"
+	case 5:
+		return "台風(TY)"
+	case 6:
+		return "温帯低気圧(L)"
+	case 9:
+		return "台風(TS以上)"
+	default:
+		return ""
+	}
+}
+
+// IBTrACS(v04)のCSVを読み込み、SIDごとの台風にする
+// 位置・強さはWMOの値を使い、半径は気象庁(TOKYO_R50・R30)の値があればそれを、無ければ米国(USA_R34・R50・R64)の象限ごとの値を使う
+// 西経は日付変更線をまたいでもつながるように180度より大きい東経にする
+func ReadIBTrACSCSV(path string) ([]*model.Storm, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	storms, err := ParseIBTrACSCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return storms, nil
+}
+
+// 1行目が列名、2行目が単位の行で、3行目から解析の行が並ぶ
+func ParseIBTrACSCSV(r io.Reader) ([]*model.Storm, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("列名が読めません: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"SID", "ISO_TIME", "LAT", "LON"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s列がありません", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	// 値の無い列は空白になっている
+	intField := func(record []string, name string) int {
+		v, err := strconv.ParseFloat(field(record, name), 64)
+		if err != nil {
+			return 0
+		}
+		return int(math.Round(v))
+	}
+
+	order := []string{}
+	names := map[string]string{}
+	typhoons := map[string][]model.Typhoon{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %v", line, err)
+		}
+		// 2行目は単位の行なので、時刻が読めない行は飛ばす
+		validTime, err := time.Parse("2006-01-02 15:04:05", field(record, "ISO_TIME"))
+		if err != nil {
+			continue
+		}
+		latitude, err := strconv.ParseFloat(field(record, "LAT"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: 無効な緯度: %s", line, field(record, "LAT"))
+		}
+		longitude, err := strconv.ParseFloat(field(record, "LON"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: 無効な経度: %s", line, field(record, "LON"))
+		}
+		if longitude < 0 {
+			longitude += 360
+		}
+
+		sid := field(record, "SID")
+		if _, ok := typhoons[sid]; !ok {
+			order = append(order, sid)
+		}
+		if name := field(record, "NAME"); name != "" && name != "NOT_NAMED" && name != "UNNAMED" {
+			names[sid] = name
+		}
+
+		typhoon := makeBestTrackTyphoon(
+			validTime,
+			model.Point{Latitude: latitude, Longitude: longitude},
+			intField(record, "WMO_PRES"),
+			intField(record, "WMO_WIND"),
+			field(record, "NATURE"),
+		)
+		if warningArea, ok := makeBestTrackWarningArea("強風域", 15, intField(record, "TOKYO_R30_DIR"), intField(record, "TOKYO_R30_LONG"), intField(record, "TOKYO_R30_SHORT")); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		} else if warningArea, ok := makeKnotRadiiWarningArea(34, ibtracsQuadrantRadii(record, intField, "USA_R34")); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		}
+		if warningArea, ok := makeBestTrackWarningArea("暴風域", 25, intField(record, "TOKYO_R50_DIR"), intField(record, "TOKYO_R50_LONG"), intField(record, "TOKYO_R50_SHORT")); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		} else if warningArea, ok := makeKnotRadiiWarningArea(50, ibtracsQuadrantRadii(record, intField, "USA_R50")); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		}
+		if warningArea, ok := makeKnotRadiiWarningArea(64, ibtracsQuadrantRadii(record, intField, "USA_R64")); ok {
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		}
+		typhoons[sid] = append(typhoons[sid], typhoon)
+	}
+
+	storms := []*model.Storm{}
+	for _, sid := range order {
+		storms = append(storms, MakeBestTrackStorm(sid, "", names[sid], typhoons[sid]))
+	}
+	return storms, nil
+}
+
+// USA_R34_NE・_SE・_SW・_NWのような象限ごとの半径(nm)
+func ibtracsQuadrantRadii(record []string, intField func([]string, string) int, prefix string) [4]int {
+	return [4]int{
+		intField(record, prefix+"_NE"),
+		intField(record, prefix+"_SE"),
+		intField(record, prefix+"_SW"),
+		intField(record, prefix+"_NW"),
+	}
+}
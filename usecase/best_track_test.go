@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 2024年の台風と、2桁の年が1900年代になる1961年の台風
+const testRSMCBestTrack = `66666 2410  003 0010 2410 0 6 JONGDARI                  20240920
+24081900 002 3 250 1280  994     35 00000 0000 30150 0100
+24081906 002 5 262 1275  975     70 10060 0040 90200 0000
+24082000 002 5 320 1300  980     65 50080 0060 30200 0150 #
+66666 6105  002 0005 6105 0 6 NANCY                     19920101
+61091500 002 3 200 1400 1000     40
+61091506 002 3 205 1395  998     45 #
+`
+
+func TestParseRSMCBestTrack(t *testing.T) {
+	storms, err := ParseRSMCBestTrack(strings.NewReader(testRSMCBestTrack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storms) != 2 {
+		t.Fatalf("台風の数 = %d, want 2", len(storms))
+	}
+
+	// ヘッダからEventIDを作る
+	tests := []struct {
+		eventID       string
+		typhoonNumber string
+		typhoonName   string
+		advisories    int
+		firstTime     time.Time
+	}{
+		{"TC2410", "2410", "JONGDARI", 3, time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)},
+		{"TC6105", "6105", "NANCY", 2, time.Date(1961, 9, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for i, tt := range tests {
+		storm := storms[i]
+		if storm.EventID != tt.eventID || storm.TyphoonNumber != tt.typhoonNumber || storm.TyphoonName != tt.typhoonName {
+			t.Errorf("台風%d = %s %s %s, want %s %s %s", i, storm.EventID, storm.TyphoonNumber, storm.TyphoonName, tt.eventID, tt.typhoonNumber, tt.typhoonName)
+		}
+		if len(storm.Advisories) != tt.advisories {
+			t.Errorf("%s の解析の数 = %d, want %d", tt.eventID, len(storm.Advisories), tt.advisories)
+			continue
+		}
+		// 1951〜1968年は2桁の年が2000年代に読まれるので1900年代に戻す
+		if validTime := storm.Advisories[0].Typhoons[0].ValidTime; !validTime.Equal(tt.firstTime) {
+			t.Errorf("%s の最初の時刻 = %s, want %s", tt.eventID, validTime, tt.firstTime)
+		}
+		for j, advisory := range storm.Advisories {
+			if advisory.EventID != tt.eventID || advisory.Serial != j+1 {
+				t.Errorf("%s の%d番目の電文 = %s %d", tt.eventID, j, advisory.EventID, advisory.Serial)
+			}
+		}
+	}
+
+	jongdari := storms[0].Advisories
+	first := jongdari[0].Typhoons[0]
+	if first.Latitude != 25 || first.Longitude != 128 || first.CentralPressure != 994 || first.MaxWindSpeedNearTheCenter != 18 || first.TyphoonClass != "台風(TS)" {
+		t.Errorf("1つ目の解析 = %+v", first)
+	}
+	// 上陸・通過の印(#)があっても半径まで読める
+	last := jongdari[2].Typhoons[0]
+	if last.CentralPressure != 980 || last.MaxWindSpeedNearTheCenter != 33 {
+		t.Errorf("#のある行 = %+v", last)
+	}
+	if nancy := storms[1].Advisories[1].Typhoons[0]; nancy.MaxWindSpeedNearTheCenter != 23 || len(nancy.WarningAreas) != 0 {
+		t.Errorf("半径の無い#のある行 = %+v", nancy)
+	}
+
+	// 50ktは暴風域、30ktは強風域 (向きは1桁目、0は半径無し、9は円)
+	areaTests := []struct {
+		advisory int
+		want     []model.TyphoonWarningArea
+	}{
+		{0, []model.TyphoonWarningArea{
+			{WarningAreaType: "強風域", WindSpeed: 15, CircleLongDirection: "南東", CircleLongRadius: 278, CircleShortDirection: "北西", CircleShortRadius: 185},
+		}},
+		{1, []model.TyphoonWarningArea{
+			{WarningAreaType: "暴風域", WindSpeed: 25, CircleLongDirection: "北東", CircleLongRadius: 111, CircleShortDirection: "南西", CircleShortRadius: 74},
+			{WarningAreaType: "強風域", WindSpeed: 15, CircleLongDirection: "", CircleLongRadius: 370, CircleShortDirection: "", CircleShortRadius: 370},
+		}},
+		{2, []model.TyphoonWarningArea{
+			{WarningAreaType: "暴風域", WindSpeed: 25, CircleLongDirection: "南西", CircleLongRadius: 148, CircleShortDirection: "北東", CircleShortRadius: 111},
+			{WarningAreaType: "強風域", WindSpeed: 15, CircleLongDirection: "南東", CircleLongRadius: 370, CircleShortDirection: "北西", CircleShortRadius: 278},
+		}},
+	}
+	for _, tt := range areaTests {
+		got := jongdari[tt.advisory].Typhoons[0].WarningAreas
+		if len(got) != len(tt.want) {
+			t.Errorf("%d番目の解析の範囲 = %+v, want %+v", tt.advisory, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].WarningAreaType != tt.want[i].WarningAreaType ||
+				got[i].WindSpeed != tt.want[i].WindSpeed ||
+				got[i].CircleLongDirection != tt.want[i].CircleLongDirection ||
+				got[i].CircleLongRadius != tt.want[i].CircleLongRadius ||
+				got[i].CircleShortDirection != tt.want[i].CircleShortDirection ||
+				got[i].CircleShortRadius != tt.want[i].CircleShortRadius {
+				t.Errorf("%d番目の解析の%s = %+v, want %+v", tt.advisory, tt.want[i].WarningAreaType, got[i], tt.want[i])
+			}
+		}
+	}
+
+	invalid := []string{
+		"66666 2410\n",
+		"66666 2410  003 0010 2410 0 6 JONGDARI 20240920\n24081900 002 3 250\n",
+		"66666 2410  003 0010 2410 0 6 JONGDARI 20240920\n24081900 002 3 25x 1280  994\n",
+	}
+	for _, s := range invalid {
+		if _, err := ParseRSMCBestTrack(strings.NewReader(s)); err == nil {
+			t.Errorf("エラーにならない: %q", s)
+		}
+	}
+}
+
+func TestParseIBTrACSCSV(t *testing.T) {
+	csv := "\ufeffSID,SEASON,NAME,ISO_TIME,NATURE,LAT,LON,WMO_WIND,WMO_PRES,TOKYO_R50_DIR,TOKYO_R50_LONG,TOKYO_R50_SHORT,TOKYO_R30_DIR,TOKYO_R30_LONG,TOKYO_R30_SHORT,USA_R34_NE,USA_R34_SE,USA_R34_SW,USA_R34_NW\n" +
+		" ,Year, , , ,degrees_north,degrees_east,kts,mb, ,nmile,nmile, ,nmile,nmile,nmile,nmile,nmile,nmile\n" +
+		"2024232N24128,2024,JONGDARI,2024-08-19 06:00:00,TS,26.2,127.5,70,975,1,60,40,9,200,200, , , , \n" +
+		"2024232N24128,2024,JONGDARI,2024-08-19 00:00:00,TS,25.0,128.0,35,994, , , , , , ,90,80,60,70\n" +
+		"2024300N15185,2024,NOT_NAMED,2024-10-26 00:00:00,TS,15.0,-175.0, , , , , , , , , , , , \n"
+	storms, err := ParseIBTrACSCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storms) != 2 {
+		t.Fatalf("台風の数 = %d, want 2", len(storms))
+	}
+	jongdari := storms[0]
+	if jongdari.EventID != "2024232N24128" || jongdari.TyphoonName != "JONGDARI" || len(jongdari.Advisories) != 2 {
+		t.Fatalf("台風 = %+v", jongdari)
+	}
+	// 時刻順に並べ直し、気象庁の半径が無ければ米国の象限ごとの半径を使う
+	first := jongdari.Advisories[0].Typhoons[0]
+	if first.CentralPressure != 994 || len(first.WarningAreas) != 1 || first.WarningAreas[0].WarningAreaType != "強風域" || len(first.WarningAreas[0].QuadrantRadii) != 4 {
+		t.Errorf("米国の半径の解析 = %+v", first)
+	}
+	second := jongdari.Advisories[1].Typhoons[0]
+	if len(second.WarningAreas) != 2 || second.WarningAreas[0].CircleLongRadius != 370 || second.WarningAreas[1].CircleLongDirection != "北東" {
+		t.Errorf("気象庁の半径の解析 = %+v", second)
+	}
+	// 西経は180度より大きい東経にし、NOT_NAMEDは名前にしない
+	unnamed := storms[1]
+	if unnamed.TyphoonName != "" || unnamed.Advisories[0].Typhoons[0].Longitude != 185 {
+		t.Errorf("名前の無い台風 = %+v", unnamed)
+	}
+
+	if _, err := ParseIBTrACSCSV(strings.NewReader("SID,ISO_TIME,LAT\n")); err == nil {
+		t.Error("LON列が無いのにエラーにならない")
+	}
+}
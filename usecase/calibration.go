@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"typhoon-polygon/model"
+)
+
+// 信頼区間の95%に対応する標準正規分布の値
+const calibrationZ = 1.959964
+
+// 予報円の半径から、中心が確率probabilityで入る円の半径(km)を求める
+// 予報円を70%の円とした2次元正規分布で、半径Rの円に入る確率は 1-exp(-R^2/2σ^2)
+func ForecastCircleRadiusAt(radius float64, probability float64) float64 {
+	return ForecastCircleSigma(radius) * math.Sqrt(-2*math.Log(1-probability))
+}
+
+// 予報円の半径を確率ごとに変えて、実況の中心が入った割合をgroupと何時間後ごとに集計する
+// 入ったかどうかは予報位置と実況の中心の距離(HaversineDistance)で判定する
+// 予報円の無い予報は含めない
+func CalcCalibration(verifications []model.ForecastVerification, probabilities []float64, group func(model.ForecastVerification) string) []model.CalibrationBin {
+	type key struct {
+		group       string
+		leadHours   int
+		probability float64
+	}
+	bins := map[key]*model.CalibrationBin{}
+	keys := []key{}
+	for _, v := range verifications {
+		if v.CircleRadius <= 0 {
+			continue
+		}
+		distance := HaversineDistance(v.ForecastPoint.Latitude, v.ForecastPoint.Longitude, v.ObservedPoint.Latitude, v.ObservedPoint.Longitude)
+		for _, probability := range probabilities {
+			k := key{group: group(v), leadHours: v.LeadHours, probability: probability}
+			bin, ok := bins[k]
+			if !ok {
+				bin = &model.CalibrationBin{Group: k.group, LeadHours: k.leadHours, Probability: probability}
+				bins[k] = bin
+				keys = append(keys, k)
+			}
+			bin.Count++
+			if distance <= ForecastCircleRadiusAt(v.CircleRadius, probability) {
+				bin.HitCount++
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		if keys[i].leadHours != keys[j].leadHours {
+			return keys[i].leadHours < keys[j].leadHours
+		}
+		return keys[i].probability < keys[j].probability
+	})
+
+	calibration := make([]model.CalibrationBin, 0, len(keys))
+	for _, k := range keys {
+		bin := bins[k]
+		bin.HitRate = float64(bin.HitCount) / float64(bin.Count)
+		bin.LowerBound, bin.UpperBound = wilsonInterval(bin.HitCount, bin.Count)
+		calibration = append(calibration, *bin)
+	}
+	return calibration
+}
+
+// 割合の95%信頼区間(Wilsonのスコア区間)
+// 件数が少ないときも0〜1の範囲に収まる
+func wilsonInterval(hits int, count int) (float64, float64) {
+	if count == 0 {
+		return 0, 1
+	}
+	n := float64(count)
+	p := float64(hits) / n
+	z2 := calibrationZ * calibrationZ
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := calibrationZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+func SaveCalibrationCSV(path string, calibration []model.CalibrationBin) error {
+	rows := [][]string{{"group", "lead_hours", "probability", "count", "hit_count", "hit_rate", "lower_bound", "upper_bound"}}
+	for _, bin := range calibration {
+		rows = append(rows, []string{
+			bin.Group,
+			strconv.Itoa(bin.LeadHours),
+			strconv.FormatFloat(bin.Probability, 'f', 2, 64),
+			strconv.Itoa(bin.Count),
+			strconv.Itoa(bin.HitCount),
+			strconv.FormatFloat(bin.HitRate, 'f', 3, 64),
+			strconv.FormatFloat(bin.LowerBound, 'f', 3, 64),
+			strconv.FormatFloat(bin.UpperBound, 'f', 3, 64),
+		})
+	}
+	return saveCSV(path, rows)
+}
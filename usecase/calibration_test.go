@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+func TestForecastCircleRadiusAt(t *testing.T) {
+	for _, radius := range []float64{50, 150, 400} {
+		// 予報円は70%の円なので、70%の半径は予報円の半径と同じ
+		if got := ForecastCircleRadiusAt(radius, ForecastCircleProbability); math.Abs(got-radius) > 1e-9 {
+			t.Errorf("ForecastCircleRadiusAt(%v, 0.7) = %v, want %v", radius, got, radius)
+		}
+		if got := ForecastCircleRadiusAt(radius, 0); got != 0 {
+			t.Errorf("ForecastCircleRadiusAt(%v, 0) = %v, want 0", radius, got)
+		}
+
+		// 確率が大きいほど半径も大きい
+		previous := 0.0
+		for _, probability := range []float64{0.1, 0.3, 0.5, 0.7, 0.8, 0.9, 0.95, 0.99} {
+			got := ForecastCircleRadiusAt(radius, probability)
+			if got <= previous {
+				t.Errorf("ForecastCircleRadiusAt(%v, %v) = %v, 1つ前の確率の半径 %v より大きくない", radius, probability, got, previous)
+			}
+			previous = got
+		}
+	}
+
+	// 50%の半径は σ√(2ln2)
+	want := ForecastCircleSigma(100) * math.Sqrt(2*math.Ln2)
+	if got := ForecastCircleRadiusAt(100, 0.5); math.Abs(got-want) > 1e-9 {
+		t.Errorf("ForecastCircleRadiusAt(100, 0.5) = %v, want %v", got, want)
+	}
+}
+
+func TestWilsonInterval(t *testing.T) {
+	cases := []struct {
+		hits, count  int
+		lower, upper float64
+	}{
+		{7, 10, 0.396778, 0.892209},
+		{50, 100, 0.403832, 0.596168},
+		{0, 10, 0, 0.277533},
+		{10, 10, 0.722467, 1},
+		{0, 0, 0, 1},
+	}
+	for _, c := range cases {
+		lower, upper := wilsonInterval(c.hits, c.count)
+		if math.Abs(lower-c.lower) > 1e-6 || math.Abs(upper-c.upper) > 1e-6 {
+			t.Errorf("wilsonInterval(%d, %d) = (%.6f, %.6f), want (%.6f, %.6f)", c.hits, c.count, lower, upper, c.lower, c.upper)
+		}
+	}
+}
+
+// 予報位置(北緯30度・東経130度)から真北にdistance(km)離れた所が実況だった予報
+func makeTestCalibrationVerification(eventID string, leadHours int, radius float64, distance float64) model.ForecastVerification {
+	return model.ForecastVerification{
+		EventID:       eventID,
+		LeadHours:     leadHours,
+		ForecastPoint: model.Point{Latitude: 30, Longitude: 130},
+		ObservedPoint: model.Point{Latitude: 30 + radToDeg(distance/EarthRadius), Longitude: 130},
+		CircleRadius:  radius,
+	}
+}
+
+// groupと何時間後と確率ごとに分け、その順に並べること
+func TestCalcCalibration(t *testing.T) {
+	// 半径100kmの予報円の50%の半径は約76km、90%の半径は約138km
+	verifications := []model.ForecastVerification{
+		makeTestCalibrationVerification("TC2411", 24, 100, 50),
+		makeTestCalibrationVerification("TC2410", 48, 100, 90),
+		makeTestCalibrationVerification("TC2410", 24, 100, 120),
+		makeTestCalibrationVerification("TC2410", 24, 100, 50),
+		// 予報円の無い予報は含めない
+		makeTestCalibrationVerification("TC2410", 24, 0, 10),
+	}
+	byEventID := func(v model.ForecastVerification) string { return v.EventID }
+	calibration := CalcCalibration(verifications, []float64{0.9, 0.5}, byEventID)
+
+	want := []model.CalibrationBin{
+		{Group: "TC2410", LeadHours: 24, Probability: 0.5, Count: 2, HitCount: 1},
+		{Group: "TC2410", LeadHours: 24, Probability: 0.9, Count: 2, HitCount: 2},
+		{Group: "TC2410", LeadHours: 48, Probability: 0.5, Count: 1, HitCount: 0},
+		{Group: "TC2410", LeadHours: 48, Probability: 0.9, Count: 1, HitCount: 1},
+		{Group: "TC2411", LeadHours: 24, Probability: 0.5, Count: 1, HitCount: 1},
+		{Group: "TC2411", LeadHours: 24, Probability: 0.9, Count: 1, HitCount: 1},
+	}
+	if len(calibration) != len(want) {
+		t.Fatalf("集計の数 = %d, want %d: %+v", len(calibration), len(want), calibration)
+	}
+	for i, w := range want {
+		got := calibration[i]
+		if got.Group != w.Group || got.LeadHours != w.LeadHours || got.Probability != w.Probability ||
+			got.Count != w.Count || got.HitCount != w.HitCount {
+			t.Errorf("%d番目 = %s/%d時間後/%v %d/%d, want %s/%d時間後/%v %d/%d", i,
+				got.Group, got.LeadHours, got.Probability, got.HitCount, got.Count,
+				w.Group, w.LeadHours, w.Probability, w.HitCount, w.Count)
+		}
+		if wantRate := float64(w.HitCount) / float64(w.Count); got.HitRate != wantRate {
+			t.Errorf("%d番目の割合 = %v, want %v", i, got.HitRate, wantRate)
+		}
+		lower, upper := wilsonInterval(w.HitCount, w.Count)
+		if got.LowerBound != lower || got.UpperBound != upper {
+			t.Errorf("%d番目の信頼区間 = (%v, %v), want (%v, %v)", i, got.LowerBound, got.UpperBound, lower, upper)
+		}
+	}
+
+	// 全部を1つのgroupにまとめる
+	all := CalcCalibration(verifications, []float64{0.5}, func(model.ForecastVerification) string { return "all" })
+	if len(all) != 2 || all[0].LeadHours != 24 || all[0].Count != 3 || all[0].HitCount != 2 ||
+		all[1].LeadHours != 48 || all[1].Count != 1 {
+		t.Errorf("まとめた集計 = %+v, want 24時間後 2/3件, 48時間後 0/1件", all)
+	}
+
+	if got := CalcCalibration(nil, []float64{0.5}, byEventID); len(got) != 0 {
+		t.Errorf("予報が無いときの集計 = %+v, want 空", got)
+	}
+}
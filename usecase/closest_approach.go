@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"math"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 拠点に台風の中心が最も近づく時刻・距離と、予報円の範囲の縁までの最短距離を求める
+// 予報時刻の間は中心を大円に沿って動かし、予報円の半径は線形に変えて、その間で最も近い時刻を探す
+// 予報円が無い場合はfalseを返す
+func CalcClosestApproach(typhoons []model.Typhoon, point model.Point, options model.ProductOptions) (model.ClosestApproach, bool) {
+	forecastCircleTimeSeries := ForecastCirclesToStormAreas(MakeForecastCircleTimeSeries(typhoons, options))
+	if len(forecastCircleTimeSeries) == 0 {
+		return model.ClosestApproach{}, false
+	}
+
+	closest, distance := minimizeAlongStormAreas(forecastCircleTimeSeries, func(area model.StormArea) float64 {
+		return HaversineDistance(area.CenterPoint.Latitude, area.CenterPoint.Longitude, point.Latitude, point.Longitude)
+	})
+	// 隣り合う時刻の円をつないだ範囲は、その間を補間した円を全て合わせたものになる
+	_, coneDistance := minimizeAlongStormAreas(forecastCircleTimeSeries, func(area model.StormArea) float64 {
+		circleCenterPoint, circleRadius := stormAreaCircle(area)
+		return HaversineDistance(circleCenterPoint.Latitude, circleCenterPoint.Longitude, point.Latitude, point.Longitude) - circleRadius
+	})
+
+	return model.ClosestApproach{
+		Time:         closest.ValidTime.Round(time.Minute),
+		LeadHours:    closest.LeadHours,
+		CenterPoint:  closest.CenterPoint,
+		Distance:     distance,
+		InsideCone:   coneDistance <= 0,
+		ConeDistance: math.Max(coneDistance, 0),
+	}, true
+}
+
+// 円の時系列に沿ってfが最小になる円を探す
+// 隣り合う時刻の間は黄金分割探索で探すので、fはその間で谷が1つだけのものとする
+func minimizeAlongStormAreas(stormAreaTimeSeries []model.StormArea, f func(model.StormArea) float64) (model.StormArea, float64) {
+	best := stormAreaTimeSeries[0]
+	bestValue := f(best)
+
+	for i := range stormAreaTimeSeries[:len(stormAreaTimeSeries)-1] {
+		a, b := stormAreaTimeSeries[i], stormAreaTimeSeries[i+1]
+		duration := b.ValidTime.Sub(a.ValidTime)
+		if duration <= 0 {
+			continue
+		}
+		at := func(ratio float64) model.StormArea {
+			return InterpolateStormArea(a, b, a.ValidTime.Add(time.Duration(ratio*float64(duration))))
+		}
+
+		// 1分の精度まで縮める
+		lo, hi := 0.0, 1.0
+		invPhi := (math.Sqrt(5) - 1) / 2
+		x1, x2 := hi-invPhi*(hi-lo), lo+invPhi*(hi-lo)
+		f1, f2 := f(at(x1)), f(at(x2))
+		for (hi-lo)*float64(duration) > float64(time.Minute) {
+			if f1 < f2 {
+				hi, x2, f2 = x2, x1, f1
+				x1 = hi - invPhi*(hi-lo)
+				f1 = f(at(x1))
+			} else {
+				lo, x1, f1 = x1, x2, f2
+				x2 = lo + invPhi*(hi-lo)
+				f2 = f(at(x2))
+			}
+		}
+
+		// 端で最小になる場合もあるので、端の円とも比べる
+		for _, area := range []model.StormArea{at((lo + hi) / 2), b} {
+			if value := f(area); value < bestValue {
+				best, bestValue = area, value
+			}
+		}
+	}
+
+	return best, bestValue
+}
+
+// CalcTyphoonPointsと同じく、台風の中心から広い方へずらした円の中心と半径
+func stormAreaCircle(area model.StormArea) (model.Point, float64) {
+	circleRadius := (area.CircleLongRadius + area.CircleShortRadius) / 2.
+	circleCenterPoint := CalcCirclePoint(area.CenterPoint.Latitude, area.CenterPoint.Longitude, area.CircleLongRadius-circleRadius, area.CircleLongDirection)
+	return circleCenterPoint, circleRadius
+}
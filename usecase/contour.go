@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"typhoon-polygon/model"
+)
+
+// 格子の値がlevel以上の範囲を、marching squaresでポリゴンにする
+// 格子の値は格子の中心の値とみなし、隣り合う中心の間を線形に補間して境界を引く
+// 返り値はポリゴンごとに外周・穴の順に並んだリング
+// 格子の外側と値の無い格子(NaN)はlevel未満とみなすので、範囲はそこで閉じる
+func ContourGrid(grid model.Grid, level float64) [][][]model.Point {
+	rings := traceContourRings(grid, level)
+
+	// 反時計回りは外周、時計回りは穴
+	exteriors := [][]model.Point{}
+	holes := [][]model.Point{}
+	for _, ring := range rings {
+		if signedRingArea(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	// 面積の小さい外周から順に見て、穴を含む一番小さい外周に入れる
+	sort.Slice(exteriors, func(i, j int) bool {
+		return signedRingArea(exteriors[i]) < signedRingArea(exteriors[j])
+	})
+	polygons := make([][][]model.Point, len(exteriors))
+	for i, exterior := range exteriors {
+		polygons[i] = [][]model.Point{exterior}
+	}
+	for _, hole := range holes {
+		for i, exterior := range exteriors {
+			if pointInRing(hole[0], exterior) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+
+	return polygons
+}
+
+// 境界の線分の端点 (格子の中心を結ぶ辺のどこを通るか)
+type contourEdge struct {
+	row, col   int
+	horizontal bool // (row,col)-(row,col+1)の辺ならtrue、(row,col)-(row+1,col)の辺ならfalse
+}
+
+type contourSegment struct {
+	from, to contourEdge
+}
+
+// 境界の線分を求めてつなぎ、閉じたリングにする
+// 線分はlevel以上の側が左になる向きにそろえるので、外周は反時計回り・穴は時計回りになる
+func traceContourRings(grid model.Grid, level float64) [][]model.Point {
+	// 格子の外側に1つずつ余白を付け、余白と値の無い格子(NaN)はlevel未満とする
+	value := func(row, col int) float64 {
+		if row < 0 || row >= grid.Rows || col < 0 || col >= grid.Cols || math.IsNaN(grid.Values[row][col]) {
+			return math.Inf(-1)
+		}
+		return grid.Values[row][col]
+	}
+	inside := func(row, col int) bool {
+		return value(row, col) >= level
+	}
+
+	// 辺の上で値がlevelになる点
+	edgePoint := func(edge contourEdge) model.Point {
+		row1, col1 := edge.row, edge.col
+		row2, col2 := edge.row+1, edge.col
+		if edge.horizontal {
+			row2, col2 = edge.row, edge.col+1
+		}
+		v1, v2 := value(row1, col1), value(row2, col2)
+		t := 0.5 // 余白・値の無い格子との間は格子の端で閉じる
+		if !math.IsInf(v1, -1) && !math.IsInf(v2, -1) {
+			t = (level - v1) / (v2 - v1)
+		}
+		return model.Point{
+			Latitude:  grid.MinLatitude + (float64(row1)+0.5+t*float64(row2-row1))*grid.CellSize,
+			Longitude: grid.MinLongitude + (float64(col1)+0.5+t*float64(col2-col1))*grid.CellSize,
+		}
+	}
+
+	segments := map[contourEdge]contourSegment{}
+	for row := -1; row < grid.Rows; row++ {
+		for col := -1; col < grid.Cols; col++ {
+			// 四角形の角: 左下(row,col)・右下(row,col+1)・右上(row+1,col+1)・左上(row+1,col)
+			bottom := contourEdge{row: row, col: col, horizontal: true}
+			right := contourEdge{row: row, col: col + 1, horizontal: false}
+			top := contourEdge{row: row + 1, col: col, horizontal: true}
+			left := contourEdge{row: row, col: col, horizontal: false}
+
+			index := 0
+			if inside(row, col) {
+				index |= 1
+			}
+			if inside(row, col+1) {
+				index |= 2
+			}
+			if inside(row+1, col+1) {
+				index |= 4
+			}
+			if inside(row+1, col) {
+				index |= 8
+			}
+
+			// level以上の角が左になる向きで線分を入れる
+			add := func(from, to contourEdge) {
+				segments[from] = contourSegment{from: from, to: to}
+			}
+			switch index {
+			case 1:
+				add(bottom, left)
+			case 2:
+				add(right, bottom)
+			case 3:
+				add(right, left)
+			case 4:
+				add(top, right)
+			case 6:
+				add(top, bottom)
+			case 7:
+				add(top, left)
+			case 8:
+				add(left, top)
+			case 9:
+				add(bottom, top)
+			case 11:
+				add(right, top)
+			case 12:
+				add(left, right)
+			case 13:
+				add(bottom, right)
+			case 14:
+				add(left, bottom)
+			case 5, 10:
+				// 鞍点は四角形の中心の値(4つの角の平均)で、つながっているかを決める
+				center := (value(row, col) + value(row, col+1) + value(row+1, col+1) + value(row+1, col)) / 4
+				connected := center >= level
+				if math.IsInf(center, -1) {
+					connected = false
+				}
+				switch {
+				case index == 5 && connected:
+					add(bottom, right)
+					add(top, left)
+				case index == 5:
+					add(bottom, left)
+					add(top, right)
+				case index == 10 && connected:
+					add(left, bottom)
+					add(right, top)
+				default:
+					add(right, bottom)
+					add(left, top)
+				}
+			}
+		}
+	}
+
+	// 線分を端点でつないでリングにする
+	rings := [][]model.Point{}
+	for len(segments) > 0 {
+		// mapの順序で結果が変わらないように、一番小さい辺から始める
+		start := minContourEdge(segments)
+
+		ring := []model.Point{}
+		edge := start
+		for {
+			segment, ok := segments[edge]
+			if !ok {
+				break
+			}
+			delete(segments, edge)
+			ring = append(ring, edgePoint(segment.from))
+			edge = segment.to
+			if edge == start {
+				break
+			}
+		}
+		if len(ring) >= 3 {
+			rings = append(rings, append(ring, ring[0]))
+		}
+	}
+
+	return rings
+}
+
+func lessContourEdge(a, b contourEdge) bool {
+	if a.row != b.row {
+		return a.row < b.row
+	}
+	if a.col != b.col {
+		return a.col < b.col
+	}
+	return !a.horizontal && b.horizontal
+}
+
+func minContourEdge(segments map[contourEdge]contourSegment) contourEdge {
+	first := true
+	var minEdge contourEdge
+	for edge := range segments {
+		if first || lessContourEdge(edge, minEdge) {
+			minEdge = edge
+			first = false
+		}
+	}
+	return minEdge
+}
+
+// 経度・緯度の平面でのリングの面積 (反時計回りなら正)
+func signedRingArea(ring []model.Point) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i].Longitude*ring[i+1].Latitude - ring[i+1].Longitude*ring[i].Latitude
+	}
+	return area / 2
+}
+
+// 点がリングの内側にあるか (経度・緯度の平面で判定する)
+func pointInRing(point model.Point, ring []model.Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > point.Latitude) != (b.Latitude > point.Latitude) &&
+			point.Longitude < (b.Longitude-a.Longitude)*(point.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+// 格子の中心の座標から値を決めて格子を作る
+func makeAnalyticGrid(rows, cols int, f func(lat, lon float64) float64) model.Grid {
+	grid := model.Grid{MinLatitude: 0, MinLongitude: 0, CellSize: 1, Rows: rows, Cols: cols, Values: make([][]float64, rows)}
+	for row := 0; row < rows; row++ {
+		grid.Values[row] = make([]float64, cols)
+		for col := 0; col < cols; col++ {
+			grid.Values[row][col] = f(float64(row)+0.5, float64(col)+0.5)
+		}
+	}
+	return grid
+}
+
+// (10.5, 10.5)を頂点とする円錐
+func coneField(lat, lon float64) float64 {
+	return 10 - math.Hypot(lat-10.5, lon-10.5)
+}
+
+// (10.5, 10.5)から半径6の円に沿って高くなる尾根 (内側は穴になる)
+func ridgeField(lat, lon float64) float64 {
+	r := math.Hypot(lat-10.5, lon-10.5)
+	return 9 - (r-6)*(r-6)
+}
+
+func TestContourGridAnalyticFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		grid      model.Grid
+		level     float64
+		polygons  int
+		holes     int     // 全てのポリゴンの穴の数
+		radius    float64 // 外周の中心(10.5,10.5)からの距離 (0なら確かめない)
+		tolerance float64
+	}{
+		{"円錐 level 2", makeAnalyticGrid(21, 21, coneField), 2, 1, 0, 8, 0.15},
+		{"円錐 level 5", makeAnalyticGrid(21, 21, coneField), 5, 1, 0, 5, 0.15},
+		{"円錐 level 8", makeAnalyticGrid(21, 21, coneField), 8, 1, 0, 2, 0.15},
+		{"円錐 頂点より上", makeAnalyticGrid(21, 21, coneField), 11, 0, 0, 0, 0},
+		{"尾根 level 5", makeAnalyticGrid(21, 21, ridgeField), 5, 1, 1, 8, 0.15},
+		{"尾根 level 0", makeAnalyticGrid(21, 21, ridgeField), 0, 1, 1, 9, 0.15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polygons := ContourGrid(tt.grid, tt.level)
+			if len(polygons) != tt.polygons {
+				t.Fatalf("ポリゴン = %d個, want %d個", len(polygons), tt.polygons)
+			}
+			holes := 0
+			for _, rings := range polygons {
+				if signedRingArea(rings[0]) <= 0 {
+					t.Errorf("外周が反時計回りでない")
+				}
+				for _, hole := range rings[1:] {
+					holes++
+					if signedRingArea(hole) >= 0 {
+						t.Errorf("穴が時計回りでない")
+					}
+					if !pointInRing(hole[0], rings[0]) {
+						t.Errorf("穴が外周の内側に無い")
+					}
+				}
+				if tt.radius > 0 {
+					for _, p := range rings[0] {
+						if d := math.Hypot(p.Latitude-10.5, p.Longitude-10.5); math.Abs(d-tt.radius) > tt.tolerance {
+							t.Fatalf("外周の点 %+v の距離 = %.3f, want %.1f", p, d, tt.radius)
+						}
+					}
+				}
+			}
+			if holes != tt.holes {
+				t.Errorf("穴 = %d個, want %d個", holes, tt.holes)
+			}
+		})
+	}
+}
+
+// levelが高いほど範囲は内側に入れ子になる
+func TestContourGridNesting(t *testing.T) {
+	grid := makeAnalyticGrid(21, 21, coneField)
+	levels := []float64{1, 3, 5, 7, 9}
+	for i := 1; i < len(levels); i++ {
+		outer := ContourGrid(grid, levels[i-1])[0][0]
+		inner := ContourGrid(grid, levels[i])[0][0]
+		for _, p := range inner {
+			if !pointInRing(p, outer) {
+				t.Fatalf("level %g の点 %+v が level %g の範囲の外にある", levels[i], p, levels[i-1])
+			}
+		}
+		if signedRingArea(inner) >= signedRingArea(outer) {
+			t.Errorf("level %g の面積が level %g より小さくない", levels[i], levels[i-1])
+		}
+	}
+}
+
+func TestContourGridSaddle(t *testing.T) {
+	// 左下と右上が高い鞍点 (四角形の中心は0.5)
+	grid := model.Grid{CellSize: 1, Rows: 2, Cols: 2, Values: [][]float64{{1, 0}, {0, 1}}}
+
+	tests := []struct {
+		level    float64
+		polygons int
+	}{
+		{0.4, 1}, // 中心がlevel以上なのでつながる
+		{0.6, 2}, // 中心がlevel未満なので分かれる
+	}
+	for _, tt := range tests {
+		polygons := ContourGrid(grid, tt.level)
+		if len(polygons) != tt.polygons {
+			t.Errorf("level %g: ポリゴン = %d個, want %d個", tt.level, len(polygons), tt.polygons)
+		}
+		for _, rings := range polygons {
+			if len(rings) != 1 || signedRingArea(rings[0]) <= 0 {
+				t.Errorf("level %g: 穴の無い反時計回りのポリゴンでない: %+v", tt.level, rings)
+			}
+		}
+	}
+}
+
+func TestContourGridEdge(t *testing.T) {
+	// 全ての格子がlevel以上なら、範囲は格子の端で閉じる
+	grid := model.Grid{MinLatitude: 20, MinLongitude: 130, CellSize: 0.5, Rows: 3, Cols: 4, Values: [][]float64{
+		{10, 10, 10, 10},
+		{10, 10, 10, 10},
+		{10, 10, 10, 10},
+	}}
+	polygons := ContourGrid(grid, 5)
+	if len(polygons) != 1 || len(polygons[0]) != 1 {
+		t.Fatalf("ポリゴン = %+v, want 穴の無い1個", polygons)
+	}
+	for _, p := range polygons[0][0] {
+		if p.Latitude < 20-1e-9 || p.Latitude > 21.5+1e-9 || p.Longitude < 130-1e-9 || p.Longitude > 132+1e-9 {
+			t.Errorf("点 %+v が格子の外にある", p)
+		}
+	}
+
+	// 値の無い格子もlevel未満として、そこで閉じる
+	grid.Values[1][1] = math.NaN()
+	polygons = ContourGrid(grid, 5)
+	if len(polygons) != 1 || len(polygons[0]) != 2 {
+		t.Fatalf("値の無い格子を囲む穴が無い: %+v", polygons)
+	}
+}
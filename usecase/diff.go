@@ -0,0 +1,26 @@
+package usecase
+
+import "typhoon-polygon/model"
+
+// 新しい電文の実況・予報の時刻ごとに、古い電文の経路からの位置と強さのずれを求める
+// 電文ごとに予報の時刻がずれるので、古い電文の経路をその時刻に補間して比べる
+// 古い電文の経路の時刻の範囲外の時刻は含めない
+func CalcTrackDisplacements(from, to []model.Typhoon) []model.TrackDisplacement {
+	displacements := []model.TrackDisplacement{}
+	for _, typhoon := range to {
+		fromTyphoon, ok := InterpolateTyphoonAt(from, typhoon.ValidTime)
+		if !ok {
+			continue
+		}
+		displacements = append(displacements, model.TrackDisplacement{
+			TargetTimestamp:       typhoon.TargetTimestamp,
+			TargetTimestampType:   typhoon.TargetTimestampType,
+			FromPoint:             model.Point{Latitude: fromTyphoon.Latitude, Longitude: fromTyphoon.Longitude},
+			ToPoint:               model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
+			Distance:              HaversineDistance(fromTyphoon.Latitude, fromTyphoon.Longitude, typhoon.Latitude, typhoon.Longitude),
+			CentralPressureChange: typhoon.CentralPressure - fromTyphoon.CentralPressure,
+			MaxWindSpeedChange:    typhoon.MaxWindSpeedNearTheCenter - fromTyphoon.MaxWindSpeedNearTheCenter,
+		})
+	}
+	return displacements
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+func TestCalcTrackDisplacementsInterpolatesShiftedValidTimes(t *testing.T) {
+	base := time.Date(2024, 8, 28, 0, 0, 0, 0, time.UTC)
+	from := []model.Typhoon{
+		{ValidTime: base, Latitude: 30, Longitude: 130, CentralPressure: 960, MaxWindSpeedNearTheCenter: 40},
+		{ValidTime: base.Add(12 * time.Hour), Latitude: 32, Longitude: 130, CentralPressure: 970, MaxWindSpeedNearTheCenter: 32},
+	}
+	// 3時間後の電文は時刻が3時間ずれる
+	to := []model.Typhoon{
+		{ValidTime: base.Add(3 * time.Hour), TargetTimestampType: "実況", Latitude: 30.5, Longitude: 130, CentralPressure: 960, MaxWindSpeedNearTheCenter: 40},
+		{ValidTime: base.Add(15 * time.Hour), TargetTimestampType: "予報 12時間後", Latitude: 33, Longitude: 130},
+	}
+
+	displacements := CalcTrackDisplacements(from, to)
+	if len(displacements) != 1 {
+		t.Fatalf("ずれ = %d件, want 1件 (15時間後は古い経路の範囲外)", len(displacements))
+	}
+	d := displacements[0]
+	if math.Abs(d.FromPoint.Latitude-30.5) > 0.01 || math.Abs(d.FromPoint.Longitude-130) > 0.01 {
+		t.Errorf("補間した位置 = %+v, want 30.5,130", d.FromPoint)
+	}
+	if d.Distance > 1 {
+		t.Errorf("距離 = %.2fkm, want 0km", d.Distance)
+	}
+	// 960→970の1/4は962.5で、四捨五入して963 (40→32の1/4は38)
+	if d.CentralPressureChange != -3 {
+		t.Errorf("中心気圧の変化 = %d, want -3", d.CentralPressureChange)
+	}
+	if d.MaxWindSpeedChange != 2 {
+		t.Errorf("最大風速の変化 = %d, want 2", d.MaxWindSpeedChange)
+	}
+}
+
+// 実際の電文では続く報数同士で予報の時刻がずれていても、ずれが求められること
+func TestCalcTrackDisplacementsConsecutiveAdvisories(t *testing.T) {
+	registry, err := LoadStormRegistry("../xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storm, err := registry.FindStorm("TC2415")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(storm.Advisories); i++ {
+		from, to := storm.Advisories[i-1], storm.Advisories[i]
+		if len(CalcTrackDisplacements(from.Typhoons, to.Typhoons)) == 0 {
+			t.Errorf("第%d報→第%d報のずれが無い", from.Serial, to.Serial)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"typhoon-polygon/model"
+)
+
+// 拠点のCSVを読み込む
+// 1行目は列名で、name・latitude(lat)・longitude(lon, lng)は必須、category・valueは省略できる
+func ReadSitesCSV(path string) ([]model.Site, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: 列名が読めません: %v", path, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Excelで保存したCSVは先頭にBOMが付く
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "lat":
+			name = "latitude"
+		case "lon", "lng":
+			name = "longitude"
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "latitude", "longitude"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s: %s列がありません", path, name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	sites := []model.Site{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		latitude, err := strconv.ParseFloat(field(record, "latitude"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: 無効な緯度: %s", path, line, field(record, "latitude"))
+		}
+		longitude, err := strconv.ParseFloat(field(record, "longitude"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: 無効な経度: %s", path, line, field(record, "longitude"))
+		}
+		value := 0.0
+		if v := field(record, "value"); v != "" {
+			if value, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("%s:%d: 無効なvalue: %s", path, line, v)
+			}
+		}
+		sites = append(sites, model.Site{
+			Name:     field(record, "name"),
+			Category: field(record, "category"),
+			Point:    model.Point{Latitude: latitude, Longitude: longitude},
+			Value:    value,
+		})
+	}
+
+	return sites, nil
+}
+
+// 点が穴のあるポリゴンの内側にあるか (外周の内側で、どの穴の内側でもない)
+func PointInPolygon(point model.Point, rings [][]model.Point) bool {
+	if len(rings) == 0 || !pointInRing(point, rings[0]) {
+		return false
+	}
+	for _, hole := range rings[1:] {
+		if pointInRing(point, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// 穴のあるポリゴンの面積(km2)
+func PolygonWithHolesArea(rings [][]model.Point) float64 {
+	if len(rings) == 0 {
+		return 0
+	}
+	area := PolygonArea(rings[0])
+	for _, hole := range rings[1:] {
+		area -= PolygonArea(hole)
+	}
+	return area
+}
+
+// 中心がポリゴンの内側にある格子の値の合計 (人口など)
+// 値の無い格子(NaN)は数えない
+func SumGridInPolygon(grid model.Grid, rings [][]model.Point) float64 {
+	if len(rings) == 0 || len(rings[0]) == 0 {
+		return 0
+	}
+
+	// 外周を囲む範囲の格子だけを調べる
+	bounds := MakeMapBounds(rings[0], 0)
+	minRow := max(0, int(math.Floor((bounds.MinLatitude-grid.MinLatitude)/grid.CellSize)))
+	maxRow := min(grid.Rows-1, int(math.Floor((bounds.MaxLatitude-grid.MinLatitude)/grid.CellSize)))
+	minCol := max(0, int(math.Floor((bounds.MinLongitude-grid.MinLongitude)/grid.CellSize)))
+	maxCol := min(grid.Cols-1, int(math.Floor((bounds.MaxLongitude-grid.MinLongitude)/grid.CellSize)))
+
+	sum := 0.0
+	for row := minRow; row <= maxRow; row++ {
+		for col := minCol; col <= maxCol; col++ {
+			value := grid.Values[row][col]
+			if math.IsNaN(value) {
+				continue
+			}
+			if PointInPolygon(GridCellCenter(grid, row, col), rings) {
+				sum += value
+			}
+		}
+	}
+	return sum
+}
+
+func SaveExposureReports(path string, reports []model.ExposureReport) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+// 緯度経度で囲んだ四角形のリング (閉じている)
+func makeTestRectangle(minLatitude, maxLatitude, minLongitude, maxLongitude float64) []model.Point {
+	return []model.Point{
+		{Latitude: minLatitude, Longitude: minLongitude},
+		{Latitude: minLatitude, Longitude: maxLongitude},
+		{Latitude: maxLatitude, Longitude: maxLongitude},
+		{Latitude: maxLatitude, Longitude: minLongitude},
+		{Latitude: minLatitude, Longitude: minLongitude},
+	}
+}
+
+// 北緯20度・東経120度から0.5度ごとに40x40の、値がすべてvalueの格子
+func makeTestUniformGrid(value float64) model.Grid {
+	grid := NewGrid(model.MapBounds{MinLatitude: 20, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 140}, 0.5)
+	for row := range grid.Values {
+		for col := range grid.Values[row] {
+			grid.Values[row][col] = value
+		}
+	}
+	return grid
+}
+
+func TestPointInPolygon(t *testing.T) {
+	rings := [][]model.Point{
+		makeTestRectangle(20, 30, 130, 140),
+		makeTestRectangle(24, 26, 134, 136),
+	}
+	cases := []struct {
+		name  string
+		point model.Point
+		want  bool
+	}{
+		{"外周の内側", model.Point{Latitude: 22, Longitude: 132}, true},
+		{"穴の内側", model.Point{Latitude: 25, Longitude: 135}, false},
+		{"外周の外側", model.Point{Latitude: 31, Longitude: 135}, false},
+		{"穴の東の外周の内側", model.Point{Latitude: 25, Longitude: 138}, true},
+		// 辺の上の点は南・西の辺なら内側、北・東の辺なら外側 (隣り合うポリゴンで二重に数えない)
+		{"南の辺の上", model.Point{Latitude: 20, Longitude: 132}, true},
+		{"西の辺の上", model.Point{Latitude: 22, Longitude: 130}, true},
+		{"北の辺の上", model.Point{Latitude: 30, Longitude: 132}, false},
+		{"東の辺の上", model.Point{Latitude: 22, Longitude: 140}, false},
+	}
+	for _, c := range cases {
+		if got := PointInPolygon(c.point, rings); got != c.want {
+			t.Errorf("%s: PointInPolygon(%v) = %v, want %v", c.name, c.point, got, c.want)
+		}
+	}
+	if PointInPolygon(model.Point{Latitude: 22, Longitude: 132}, nil) {
+		t.Errorf("リングが無いのに内側になった")
+	}
+}
+
+// 緯度経度で囲んだ四角形の面積は R^2 * Δλ * (sinφ2 - sinφ1)
+func TestPolygonWithHolesArea(t *testing.T) {
+	rectangleArea := func(minLatitude, maxLatitude, minLongitude, maxLongitude float64) float64 {
+		return EarthRadius * EarthRadius * degToRad(maxLongitude-minLongitude) *
+			(math.Sin(degToRad(maxLatitude)) - math.Sin(degToRad(minLatitude)))
+	}
+
+	outer := makeTestRectangle(20, 30, 130, 140)
+	hole := makeTestRectangle(24, 26, 134, 136)
+	cases := []struct {
+		name  string
+		rings [][]model.Point
+		want  float64
+	}{
+		{"穴なし", [][]model.Point{outer}, rectangleArea(20, 30, 130, 140)},
+		{"穴あり", [][]model.Point{outer, hole}, rectangleArea(20, 30, 130, 140) - rectangleArea(24, 26, 134, 136)},
+		{"赤道の1度四方", [][]model.Point{makeTestRectangle(0, 1, 0, 1)}, 12391.4},
+		{"リングなし", nil, 0},
+	}
+	for _, c := range cases {
+		got := PolygonWithHolesArea(c.rings)
+		if math.Abs(got-c.want) > 0.1 {
+			t.Errorf("%s: PolygonWithHolesArea = %.1fkm2, want %.1fkm2", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSumGridInPolygon(t *testing.T) {
+	grid := makeTestUniformGrid(2)
+	// 25-30度・125-130度は0.5度の格子10x10個ちょうど
+	outer := makeTestRectangle(25, 30, 125, 130)
+	hole := makeTestRectangle(26, 27, 126, 127)
+
+	cases := []struct {
+		name  string
+		rings [][]model.Point
+		want  float64
+	}{
+		{"格子の境界に沿った四角形", [][]model.Point{outer}, 100 * 2},
+		{"穴の格子は数えない", [][]model.Point{outer, hole}, 96 * 2},
+		{"格子の範囲からはみ出す部分は数えない", [][]model.Point{makeTestRectangle(35, 45, 135, 145)}, 100 * 2},
+		{"格子の範囲の外", [][]model.Point{makeTestRectangle(50, 55, 150, 155)}, 0},
+		{"格子の中心を含まない小さなポリゴン", [][]model.Point{makeTestRectangle(25.1, 25.2, 125.1, 125.2)}, 0},
+		{"リングなし", nil, 0},
+	}
+	for _, c := range cases {
+		if got := SumGridInPolygon(grid, c.rings); got != c.want {
+			t.Errorf("%s: SumGridInPolygon = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// 格子の中心を通る線で2つに分けても、中心が線の上にある格子は片方だけで数える
+	west := makeTestRectangle(25, 30, 125, 127.75)
+	east := makeTestRectangle(25, 30, 127.75, 130)
+	sum := SumGridInPolygon(grid, [][]model.Point{west}) + SumGridInPolygon(grid, [][]model.Point{east})
+	if sum != 100*2 {
+		t.Errorf("格子の中心で分けた2つの合計 = %v, want %v", sum, 100*2)
+	}
+	// 値の無い格子(NaN)は数えない
+	grid.Values[10][18] = math.NaN() // 北緯25.25度・東経129.25度
+	if got := SumGridInPolygon(grid, [][]model.Point{outer}); got != 99*2 {
+		t.Errorf("値の無い格子があるとき = %v, want %v", got, 99*2)
+	}
+}
+
+// 列名の別名・BOM・省略できる列を読めること
+func TestReadSitesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sites.csv")
+	csv := "\ufeffName,Lat,Lng,Category,Value\n" +
+		"東京倉庫,35.6,139.8,warehouse,1200.5\n" +
+		"\"那覇, 第2工場\",26.2,127.7,factory,\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sites, err := ReadSitesCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Site{
+		{Name: "東京倉庫", Category: "warehouse", Point: model.Point{Latitude: 35.6, Longitude: 139.8}, Value: 1200.5},
+		{Name: "那覇, 第2工場", Category: "factory", Point: model.Point{Latitude: 26.2, Longitude: 127.7}, Value: 0},
+	}
+	if len(sites) != len(want) {
+		t.Fatalf("拠点の数 = %d, want %d", len(sites), len(want))
+	}
+	for i := range want {
+		if sites[i] != want[i] {
+			t.Errorf("%d番目の拠点 = %+v, want %+v", i, sites[i], want[i])
+		}
+	}
+
+	// 必須の列が無い・値が読めない場合はエラー
+	for name, body := range map[string]string{
+		"経度の列が無い":    "name,latitude\nA,35\n",
+		"緯度が読めない":    "name,latitude,longitude\nA,north,139\n",
+		"valueが読めない": "name,latitude,longitude,value\nA,35,139,many\n",
+	} {
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadSitesCSV(path); err == nil {
+			t.Errorf("%s: エラーにならない", name)
+		}
+	}
+}
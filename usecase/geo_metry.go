@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"math"
@@ -183,6 +184,22 @@ func MultiPolygonToWKT(multiPolygon [][]model.Point) string {
 	return wkt
 }
 
+// 外周・穴の順に並んだリングのポリゴンのリストからWKT形式のMULTIPOLYGONを作成する
+func PolygonsWithHolesToWKT(polygons [][][]model.Point) string {
+	var polygonWKTs []string
+	for _, rings := range polygons {
+		var ringWKTs []string
+		for _, ring := range rings {
+			// PointsToPolygonWKTは"((...))"を返すので、外側の括弧を外してリングにする
+			ringWKTs = append(ringWKTs, strings.TrimSuffix(strings.TrimPrefix(PointsToPolygonWKT(ring), "("), ")"))
+		}
+		polygonWKTs = append(polygonWKTs, fmt.Sprintf("(%s)", strings.Join(ringWKTs, ", ")))
+	}
+
+	wkt := fmt.Sprintf("MULTIPOLYGON(%s)", strings.Join(polygonWKTs, ", "))
+	return wkt
+}
+
 func WktToPolygonPoints(wkt string) ([]model.Point, error) {
 	// WKTからPOLYGONの座標部分を抽出
 	wkt = strings.TrimPrefix(wkt, "POLYGON ((")
@@ -281,6 +298,15 @@ func SaveGeoJSONToFile(filename string, data []byte) error {
 	return os.WriteFile(filename, data, 0644)
 }
 
+func SaveFeatureCollectionToFile(filename string, featureCollection *geojson.FeatureCollection) error {
+	// GeoJSONとしてエンコード
+	geoJSON, err := json.MarshalIndent(featureCollection, "", "  ")
+	if err != nil {
+		return fmt.Errorf("GeoJSONのエンコードに失敗: %v", err)
+	}
+	return SaveGeoJSONToFile(filename, geoJSON)
+}
+
 func MakeGeojsonPolygon(points []model.Point) *geojson.Feature {
 	geoJsonPoints := make([][]float64, 0, len(points)+1)
 	for _, coordinate := range points {
@@ -299,6 +325,23 @@ func MakeGeojsonPolygon(points []model.Point) *geojson.Feature {
 	return polygon
 }
 
+// 外周・穴の順に並んだリングからポリゴンを作る
+func MakeGeojsonPolygonWithHoles(rings [][]model.Point) *geojson.Feature {
+	coordinates := make([][][]float64, 0, len(rings))
+	for _, ring := range rings {
+		geojsonPoints := make([][]float64, 0, len(ring)+1)
+		for _, coordinate := range ring {
+			geojsonPoints = append(geojsonPoints, []float64{coordinate.Longitude, coordinate.Latitude})
+		}
+		// 最初の点と最後の点が一致していなければ閉じる
+		if first, last := ring[0], ring[len(ring)-1]; first != last {
+			geojsonPoints = append(geojsonPoints, []float64{first.Longitude, first.Latitude})
+		}
+		coordinates = append(coordinates, geojsonPoints)
+	}
+	return geojson.NewPolygonFeature(coordinates)
+}
+
 func MakeGeojsonLineString(points []model.Point) *geojson.Feature {
 	geojsonPoints := make([][]float64, 0, len(points)+1)
 	for _, coordinate := range points {
@@ -336,3 +379,21 @@ func DirectionToDegrees(direction string) float64 {
 		return 0 // 不明な方角の場合のデフォルト値
 	}
 }
+
+func MakeGeojsonPoint(point model.Point) *geojson.Feature {
+	return geojson.NewPointFeature([]float64{point.Longitude, point.Latitude})
+}
+
+// 球面上のポリゴンの面積(km^2)を計算する関数
+func PolygonArea(points []model.Point) float64 {
+	if len(points) < 3 {
+		return 0
+	}
+	area := 0.0
+	for i := range points {
+		p1 := points[i]
+		p2 := points[(i+1)%len(points)]
+		area += degToRad(p2.Longitude-p1.Longitude) * (2 + math.Sin(degToRad(p1.Latitude)) + math.Sin(degToRad(p2.Latitude)))
+	}
+	return math.Abs(area * EarthRadius * EarthRadius / 2)
+}
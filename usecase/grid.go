@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"typhoon-polygon/model"
+)
+
+// 範囲を覆う格子を作る (端は格子の大きさの倍数にそろえる)
+func NewGrid(bounds model.MapBounds, cellSize float64) model.Grid {
+	minLatitude := math.Floor(bounds.MinLatitude/cellSize) * cellSize
+	minLongitude := math.Floor(bounds.MinLongitude/cellSize) * cellSize
+	rows := int(math.Ceil((bounds.MaxLatitude - minLatitude) / cellSize))
+	cols := int(math.Ceil((bounds.MaxLongitude - minLongitude) / cellSize))
+
+	values := make([][]float64, rows)
+	for row := range values {
+		values[row] = make([]float64, cols)
+	}
+
+	return model.Grid{
+		MinLatitude:  minLatitude,
+		MinLongitude: minLongitude,
+		CellSize:     cellSize,
+		Rows:         rows,
+		Cols:         cols,
+		Values:       values,
+	}
+}
+
+// 格子の中心の緯度経度
+func GridCellCenter(grid model.Grid, row, col int) model.Point {
+	return model.Point{
+		Latitude:  grid.MinLatitude + (float64(row)+0.5)*grid.CellSize,
+		Longitude: grid.MinLongitude + (float64(col)+0.5)*grid.CellSize,
+	}
+}
+
+// 緯度経度を含む格子 (範囲外の場合はfalse)
+func GridCellAt(grid model.Grid, point model.Point) (int, int, bool) {
+	row := int(math.Floor((point.Latitude - grid.MinLatitude) / grid.CellSize))
+	col := int(math.Floor((point.Longitude - grid.MinLongitude) / grid.CellSize))
+	if row < 0 || row >= grid.Rows || col < 0 || col >= grid.Cols {
+		return 0, 0, false
+	}
+	return row, col, true
+}
+
+// ESRIのASCII Grid形式で書き出す (GISでそのまま開ける)
+// 北の行から順に書く
+func SaveGridASCII(path string, grid model.Grid) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "ncols %d\n", grid.Cols)
+	fmt.Fprintf(w, "nrows %d\n", grid.Rows)
+	fmt.Fprintf(w, "xllcorner %s\n", formatGridValue(grid.MinLongitude))
+	fmt.Fprintf(w, "yllcorner %s\n", formatGridValue(grid.MinLatitude))
+	fmt.Fprintf(w, "cellsize %s\n", formatGridValue(grid.CellSize))
+	fmt.Fprintf(w, "NODATA_value -9999\n")
+	for row := grid.Rows - 1; row >= 0; row-- {
+		for col, value := range grid.Values[row] {
+			if col > 0 {
+				w.WriteString(" ")
+			}
+			w.WriteString(formatGridValue(value))
+		}
+		w.WriteString("\n")
+	}
+
+	return w.Flush()
+}
+
+// ESRIのASCII Grid形式を読み込む
+// NODATA_valueの格子はNaNにする
+func ReadGridASCII(path string) (model.Grid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return model.Grid{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	scanner.Split(bufio.ScanWords)
+	nextWord := func() (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("%s: 途中でファイルが終わっています", path)
+		}
+		return scanner.Text(), nil
+	}
+
+	// ヘッダー (xllcenter・yllcenterの場合は格子の中心の座標)
+	header := map[string]float64{}
+	var firstValue string
+	for {
+		key, err := nextWord()
+		if err != nil {
+			return model.Grid{}, err
+		}
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			firstValue = key
+			break
+		}
+		value, err := nextWord()
+		if err != nil {
+			return model.Grid{}, err
+		}
+		if header[strings.ToLower(key)], err = strconv.ParseFloat(value, 64); err != nil {
+			return model.Grid{}, fmt.Errorf("%s: 無効なヘッダー: %s %s", path, key, value)
+		}
+	}
+
+	grid := model.Grid{
+		CellSize: header["cellsize"],
+		Rows:     int(header["nrows"]),
+		Cols:     int(header["ncols"]),
+	}
+	if grid.Rows <= 0 || grid.Cols <= 0 || grid.CellSize <= 0 {
+		return model.Grid{}, fmt.Errorf("%s: ncols・nrows・cellsizeがありません", path)
+	}
+	grid.MinLongitude = header["xllcorner"]
+	grid.MinLatitude = header["yllcorner"]
+	if v, ok := header["xllcenter"]; ok {
+		grid.MinLongitude = v - grid.CellSize/2
+	}
+	if v, ok := header["yllcenter"]; ok {
+		grid.MinLatitude = v - grid.CellSize/2
+	}
+	noData, hasNoData := header["nodata_value"]
+
+	// 北の行から順に並んでいる
+	grid.Values = make([][]float64, grid.Rows)
+	for row := grid.Rows - 1; row >= 0; row-- {
+		grid.Values[row] = make([]float64, grid.Cols)
+		for col := 0; col < grid.Cols; col++ {
+			word := firstValue
+			if word == "" {
+				if word, err = nextWord(); err != nil {
+					return model.Grid{}, err
+				}
+			}
+			firstValue = ""
+			value, err := strconv.ParseFloat(word, 64)
+			if err != nil {
+				return model.Grid{}, fmt.Errorf("%s: 無効な値: %s", path, word)
+			}
+			if hasNoData && value == noData {
+				value = math.NaN()
+			}
+			grid.Values[row][col] = value
+		}
+	}
+
+	return grid, nil
+}
+
+// 格子の中心の緯度経度と値をCSVで書き出す
+func SaveGridCSV(path string, grid model.Grid, valueName string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "latitude,longitude,%s\n", valueName)
+	for row := 0; row < grid.Rows; row++ {
+		for col, value := range grid.Values[row] {
+			center := GridCellCenter(grid, row, col)
+			fmt.Fprintf(w, "%s,%s,%s\n", formatGridValue(center.Latitude), formatGridValue(center.Longitude), formatGridValue(value))
+		}
+	}
+
+	return w.Flush()
+}
+
+// 格子の大きさの足し算で出る誤差(0.30000000000000004など)を丸めて書く
+func formatGridValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64)
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+)
+
+// 気象庁防災情報XMLのAtomフィード (extra.xml, eqvol.xmlなど)
+type AtomFeed struct {
+	Updated string      `xml:"updated"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	Title   string `xml:"title"`
+	ID      string `xml:"id"`
+	Updated string `xml:"updated"`
+	Links   []struct {
+		Href string `xml:"href,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+}
+
+// 台風解析・予報情報(VPTW60〜62)のファイル名
+var typhoonAdvisoryFilePattern = regexp.MustCompile(`_VPTW6[0-2]_[0-9]+\.xml\z`)
+
+func ParseAtomFeed(data []byte) (AtomFeed, error) {
+	var feed AtomFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return AtomFeed{}, err
+	}
+	return feed, nil
+}
+
+// エントリが指すXMLのURL
+// linkが無い場合はidがそのままURLになっている
+func (e AtomEntry) XMLURL() string {
+	for _, link := range e.Links {
+		if link.Href != "" {
+			return link.Href
+		}
+	}
+	return e.ID
+}
+
+func IsTyphoonAdvisoryURL(url string) bool {
+	return typhoonAdvisoryFilePattern.MatchString(path.Base(url))
+}
+
+// フィードのうち台風解析・予報情報のエントリだけを返す
+func FilterTyphoonAdvisoryEntries(feed AtomFeed) []AtomEntry {
+	entries := []AtomEntry{}
+	for _, entry := range feed.Entries {
+		if IsTyphoonAdvisoryURL(entry.XMLURL()) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func FetchURL(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 気象庁防災情報XML(台風解析・予報情報)のうち、変換に必要な要素だけを定義
+// NOTE: encoding/xmlは名前空間を指定しなければローカル名で一致するので、jmx_eb:などの接頭辞は書かない
+type jmaReport struct {
+	Head struct {
+		ReportDateTime string `xml:"ReportDateTime"`
+		TargetDateTime string `xml:"TargetDateTime"`
+		EventID        string `xml:"EventID"`
+		InfoType       string `xml:"InfoType"`
+		Serial         string `xml:"Serial"`
+	} `xml:"Head"`
+	MeteorologicalInfos []jmaMeteorologicalInfo `xml:"Body>MeteorologicalInfos>MeteorologicalInfo"`
+}
+
+type jmaMeteorologicalInfo struct {
+	DateTime struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"DateTime"`
+	Kinds []jmaKind `xml:"Item>Kind"`
+}
+
+type jmaKind struct {
+	TyphoonNamePart *struct {
+		Name     string `xml:"Name"`
+		NameKana string `xml:"NameKana"`
+		Number   string `xml:"Number"`
+	} `xml:"Property>TyphoonNamePart"`
+	ClassPart *struct {
+		TyphoonClass   string `xml:"TyphoonClass"`
+		AreaClass      string `xml:"AreaClass"`
+		IntensityClass string `xml:"IntensityClass"`
+	} `xml:"Property>ClassPart"`
+	CenterPart *struct {
+		Coordinates       []jmaTypedValue `xml:"Coordinate"`
+		ProbabilityCircle *jmaCircle      `xml:"ProbabilityCircle"`
+		Location          string          `xml:"Location"`
+		Direction         string          `xml:"Direction"`
+		Speeds            []jmaTypedValue `xml:"Speed"`
+		Pressure          string          `xml:"Pressure"`
+	} `xml:"Property>CenterPart"`
+	WindPart *struct {
+		WindSpeeds []jmaTypedValue `xml:"WindSpeed"`
+	} `xml:"Property>WindPart"`
+	WarningAreaParts []jmaCircle `xml:"Property>WarningAreaPart"`
+}
+
+// WarningAreaPartとProbabilityCircleは同じ形で扱える
+type jmaCircle struct {
+	Type       string          `xml:"type,attr"`
+	BasePoints []jmaTypedValue `xml:"BasePoint"`
+	WindSpeeds []jmaTypedValue `xml:"WindSpeed"`
+	Axes       []struct {
+		Direction string          `xml:"Direction"`
+		Radiuses  []jmaTypedValue `xml:"Radius"`
+	} `xml:"Axes>Axis"`
+	CircleAxes []struct {
+		Direction string          `xml:"Direction"`
+		Radiuses  []jmaTypedValue `xml:"Radius"`
+	} `xml:"Circle>Axes>Axis"`
+}
+
+type jmaTypedValue struct {
+	Type  string `xml:"type,attr"`
+	Unit  string `xml:"unit,attr"`
+	Value string `xml:",chardata"`
+}
+
+var jmaLatLonPattern = regexp.MustCompile(`\A([+-][0-9.]+)([+-][0-9.]+)/\z`)
+
+// 台風解析・予報情報のXMLファイルを読み込む
+func ReadTyphoonXMLFile(path string) (model.Advisory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return model.Advisory{}, err
+	}
+	advisory, err := ParseTyphoonXML(data)
+	if err != nil {
+		return model.Advisory{}, fmt.Errorf("%s: %v", path, err)
+	}
+	advisory.SourcePath = path
+	return advisory, nil
+}
+
+// 台風解析・予報情報のXMLをパースする
+// Typhoonsの中身はparse_xmls.pyが書き出すjsonと同じ形にする
+func ParseTyphoonXML(data []byte) (model.Advisory, error) {
+	var report jmaReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return model.Advisory{}, err
+	}
+
+	advisory := model.Advisory{
+		EventID:  strings.TrimSpace(report.Head.EventID),
+		InfoType: strings.TrimSpace(report.Head.InfoType),
+	}
+	if serial := strings.TrimSpace(report.Head.Serial); serial != "" {
+		v, err := strconv.Atoi(serial)
+		if err != nil {
+			return model.Advisory{}, fmt.Errorf("無効なSerial: %v", err)
+		}
+		advisory.Serial = v
+	}
+	if v := strings.TrimSpace(report.Head.ReportDateTime); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return model.Advisory{}, fmt.Errorf("無効なReportDateTime: %v", err)
+		}
+		advisory.ReportDateTime = t
+	}
+	if v := strings.TrimSpace(report.Head.TargetDateTime); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return model.Advisory{}, fmt.Errorf("無効なTargetDateTime: %v", err)
+		}
+		advisory.TargetDateTime = t
+	}
+
+	for _, info := range report.MeteorologicalInfos {
+		typhoon, err := parseJmaMeteorologicalInfo(info)
+		if err != nil {
+			return model.Advisory{}, err
+		}
+		for _, kind := range info.Kinds {
+			if kind.TyphoonNamePart != nil && advisory.TyphoonNumber == "" {
+				advisory.TyphoonName = strings.TrimSpace(kind.TyphoonNamePart.Name)
+				advisory.TyphoonNameKana = strings.TrimSpace(kind.TyphoonNamePart.NameKana)
+				advisory.TyphoonNumber = strings.TrimSpace(kind.TyphoonNamePart.Number)
+			}
+		}
+		advisory.Typhoons = append(advisory.Typhoons, typhoon)
+	}
+	if err := NormalizeTyphoons(advisory.Typhoons); err != nil {
+		return model.Advisory{}, err
+	}
+
+	return advisory, nil
+}
+
+func parseJmaMeteorologicalInfo(info jmaMeteorologicalInfo) (model.Typhoon, error) {
+	targetTimestamp, err := ConvertJmaDateTime(strings.TrimSpace(info.DateTime.Value))
+	if err != nil {
+		return model.Typhoon{}, err
+	}
+	typhoon := model.Typhoon{
+		TargetTimestamp:     targetTimestamp,
+		TargetTimestampType: info.DateTime.Type,
+		WarningAreas:        []model.TyphoonWarningArea{},
+	}
+
+	for _, kind := range info.Kinds {
+		if kind.ClassPart != nil {
+			typhoon.TyphoonClass = strings.TrimSpace(kind.ClassPart.TyphoonClass)
+			typhoon.AreaClass = strings.TrimSpace(kind.ClassPart.AreaClass)
+			typhoon.IntensityClass = strings.TrimSpace(kind.ClassPart.IntensityClass)
+		}
+		if kind.CenterPart != nil {
+			center := kind.CenterPart
+			latlonText := findTypedValue(center.Coordinates, "中心位置（度）", "")
+			if latlonText == "" && center.ProbabilityCircle != nil {
+				latlonText = findTypedValue(center.ProbabilityCircle.BasePoints, "中心位置（度）", "")
+			}
+			match := jmaLatLonPattern.FindStringSubmatch(latlonText)
+			if match == nil {
+				return model.Typhoon{}, fmt.Errorf("緯度経度が取得できません: %s", latlonText)
+			}
+			// 正規表現で数値であることは保証されている
+			typhoon.Latitude, _ = strconv.ParseFloat(match[1], 64)
+			typhoon.Longitude, _ = strconv.ParseFloat(match[2], 64)
+			typhoon.Location = strings.TrimSpace(center.Location)
+			typhoon.Direction = strings.TrimSpace(center.Direction)
+			if typhoon.Velocity, err = atoiOrZero(findTypedValue(center.Speeds, "", "km/h")); err != nil {
+				return model.Typhoon{}, err
+			}
+			if typhoon.CentralPressure, err = atoiOrZero(center.Pressure); err != nil {
+				return model.Typhoon{}, err
+			}
+			if center.ProbabilityCircle != nil {
+				warningArea, err := parseJmaCircle(*center.ProbabilityCircle)
+				if err != nil {
+					return model.Typhoon{}, err
+				}
+				typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+			}
+		}
+		if kind.WindPart != nil {
+			if typhoon.MaxWindSpeedNearTheCenter, err = atoiOrZero(findTypedValue(kind.WindPart.WindSpeeds, "最大風速", "m/s")); err != nil {
+				return model.Typhoon{}, err
+			}
+			if typhoon.InstantaneousMaxWindSpeed, err = atoiOrZero(findTypedValue(kind.WindPart.WindSpeeds, "最大瞬間風速", "m/s")); err != nil {
+				return model.Typhoon{}, err
+			}
+		}
+		for _, part := range kind.WarningAreaParts {
+			warningArea, err := parseJmaCircle(part)
+			if err != nil {
+				return model.Typhoon{}, err
+			}
+			typhoon.WarningAreas = append(typhoon.WarningAreas, warningArea)
+		}
+	}
+
+	return typhoon, nil
+}
+
+func parseJmaCircle(circle jmaCircle) (model.TyphoonWarningArea, error) {
+	axes := circle.Axes
+	if len(axes) == 0 {
+		axes = circle.CircleAxes
+	}
+	if len(axes) == 0 || len(axes) > 2 {
+		return model.TyphoonWarningArea{}, fmt.Errorf("jmx_eb:Axis elems are not found. (type: %s)", circle.Type)
+	}
+
+	warningArea := model.TyphoonWarningArea{WarningAreaType: circle.Type}
+	var err error
+	if circle.Type != "予報円" {
+		if warningArea.WindSpeed, err = atoiOrZero(findTypedValue(circle.WindSpeeds, "", "m/s")); err != nil {
+			return model.TyphoonWarningArea{}, err
+		}
+	}
+
+	warningArea.CircleLongDirection = strings.TrimSpace(axes[0].Direction)
+	if warningArea.CircleLongRadius, err = atoiOrZero(findTypedValue(axes[0].Radiuses, "", "km")); err != nil {
+		return model.TyphoonWarningArea{}, err
+	}
+	if len(axes) == 1 {
+		// ひとつしかないときは円の方向に偏りがない
+		if warningArea.CircleLongDirection != "" {
+			return model.TyphoonWarningArea{}, fmt.Errorf("circle_short_direction must be empty, but is actually: %s", warningArea.CircleLongDirection)
+		}
+		warningArea.CircleShortDirection = warningArea.CircleLongDirection
+		warningArea.CircleShortRadius = warningArea.CircleLongRadius
+	} else {
+		warningArea.CircleShortDirection = strings.TrimSpace(axes[1].Direction)
+		if warningArea.CircleShortRadius, err = atoiOrZero(findTypedValue(axes[1].Radiuses, "", "km")); err != nil {
+			return model.TyphoonWarningArea{}, err
+		}
+	}
+
+	return warningArea, nil
+}
+
+// typeとunitが一致する最初の値を返す (空文字の条件は無視する)
+func findTypedValue(values []jmaTypedValue, valueType, unit string) string {
+	for _, v := range values {
+		if valueType != "" && v.Type != valueType {
+			continue
+		}
+		if unit != "" && v.Unit != unit {
+			continue
+		}
+		return strings.TrimSpace(v.Value)
+	}
+	return ""
+}
+
+func atoiOrZero(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// before(JST): 2022-11-11T14:32:00+09:00
+// after (UTC): 2022-11-11 05:32:00 UTC
+func ConvertJmaDateTime(input string) (string, error) {
+	t, err := time.Parse(time.RFC3339, input)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(TargetTimestampLayout), nil
+}
@@ -0,0 +1,220 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// TargetTimestampの形式 (parse_xmls.pyのconvert_time_type_aと同じ)
+const TargetTimestampLayout = "2006-01-02 15:04:05 UTC"
+
+var leadHoursPattern = regexp.MustCompile(`([0-9]+)時間後`)
+
+// 全角の数字・空白を半角にする
+// 例: "予報　１２０時間後" -> "予報 120時間後"
+func NormalizeFullWidthDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９':
+			return '0' + (r - '０')
+		case r == '　':
+			return ' '
+		default:
+			return r
+		}
+	}, s)
+}
+
+// TargetTimestampTypeから中心位置の種別と何時間後かを取り出す
+// 例: "実況" -> (analysis, 0), "推定　１時間後" -> (estimate, 1), "予報　１２０時間後" -> (forecast, 120)
+func ParseTargetTimestampType(targetTimestampType string) (model.FixKind, int, error) {
+	normalized := NormalizeFullWidthDigits(targetTimestampType)
+
+	var fixKind model.FixKind
+	switch {
+	case strings.HasPrefix(normalized, "実況"):
+		return model.FixKindAnalysis, 0, nil
+	case strings.HasPrefix(normalized, "推定"):
+		fixKind = model.FixKindEstimate
+	case strings.HasPrefix(normalized, "予報"):
+		fixKind = model.FixKindForecast
+	default:
+		return "", 0, fmt.Errorf("不明なDateTimeのtype: %s", targetTimestampType)
+	}
+
+	match := leadHoursPattern.FindStringSubmatch(normalized)
+	if match == nil {
+		return "", 0, fmt.Errorf("何時間後かが取得できません: %s", targetTimestampType)
+	}
+	leadHours, err := strconv.Atoi(match[1])
+	if err != nil {
+		return "", 0, err
+	}
+	return fixKind, leadHours, nil
+}
+
+// TargetTimestamp・TargetTimestampTypeをパースしてValidTime・FixKind・LeadHoursを埋める
+func NormalizeTyphoons(typhoons []model.Typhoon) error {
+	for i := range typhoons {
+		validTime, err := time.Parse(TargetTimestampLayout, typhoons[i].TargetTimestamp)
+		if err != nil {
+			return fmt.Errorf("無効なtarget_timestamp: %v", err)
+		}
+		fixKind, leadHours, err := ParseTargetTimestampType(typhoons[i].TargetTimestampType)
+		if err != nil {
+			return err
+		}
+		typhoons[i].ValidTime = validTime
+		typhoons[i].FixKind = fixKind
+		typhoons[i].LeadHours = leadHours
+	}
+	return nil
+}
+
+// 何時間後がオプションの範囲に入っているか
+func InLeadRange(leadHours int, options model.ProductOptions) bool {
+	if leadHours < options.MinLeadHours {
+		return false
+	}
+	return !options.HasMaxLeadHours || leadHours <= options.MaxLeadHours
+}
+
+// 何時間後がオプションの範囲に入っている予報円だけを残す
+func FilterForecastCirclesByLeadRange(forecastCircleTimeSeries []model.ForecastCircle, options model.ProductOptions) []model.ForecastCircle {
+	filtered := []model.ForecastCircle{}
+	for _, v := range forecastCircleTimeSeries {
+		if InLeadRange(v.LeadHours, options) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// "0-72"のような何時間後の範囲をパースする
+// "72"だけの場合は0-72とみなす
+func ParseLeadRange(s string) (int, int, error) {
+	from, to := "0", s
+	if i := strings.Index(s, "-"); i >= 0 {
+		from, to = s[:i], s[i+1:]
+	}
+	minLeadHours, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("無効な範囲: %s", s)
+	}
+	maxLeadHours, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("無効な範囲: %s", s)
+	}
+	if minLeadHours > maxLeadHours {
+		return 0, 0, fmt.Errorf("無効な範囲: %s", s)
+	}
+	return minLeadHours, maxLeadHours, nil
+}
+
+// 2つの時刻の暴風域(予報円)の間を線形に補間する
+// 中心は大円に沿って動かし、半径・向きは線形に変える
+func InterpolateStormArea(a, b model.StormArea, t time.Time) model.StormArea {
+	duration := b.ValidTime.Sub(a.ValidTime)
+	if duration <= 0 {
+		return a
+	}
+	ratio := float64(t.Sub(a.ValidTime)) / float64(duration)
+
+	distance := HaversineDistance(a.CenterPoint.Latitude, a.CenterPoint.Longitude, b.CenterPoint.Latitude, b.CenterPoint.Longitude)
+	theta := CalculateTheta(a.CenterPoint.Latitude, a.CenterPoint.Longitude, b.CenterPoint.Latitude, b.CenterPoint.Longitude)
+	centerPoint := CalcCirclePoint(a.CenterPoint.Latitude, a.CenterPoint.Longitude, distance*ratio, theta)
+
+	// 偏りの無い円の向きは意味が無いので、もう一方の向きに合わせる
+	if a.CircleLongRadius == a.CircleShortRadius {
+		a.CircleLongDirection, a.CircleShortDirection = b.CircleLongDirection, b.CircleShortDirection
+	}
+	if b.CircleLongRadius == b.CircleShortRadius {
+		b.CircleLongDirection, b.CircleShortDirection = a.CircleLongDirection, a.CircleShortDirection
+	}
+
+	// どちらかに象限ごとの半径があれば、もう一方も象限ごとの半径にして補間する
+	quadrantRadii := [4]float64{}
+	if HasQuadrantRadii(a) || HasQuadrantRadii(b) {
+		aRadii, bRadii := StormAreaQuadrantRadii(a), StormAreaQuadrantRadii(b)
+		for i := range quadrantRadii {
+			quadrantRadii[i] = aRadii[i] + (bRadii[i]-aRadii[i])*ratio
+		}
+	}
+
+	return model.StormArea{
+		CenterPoint:          centerPoint,
+		CircleLongDirection:  interpolateDegrees(a.CircleLongDirection, b.CircleLongDirection, ratio),
+		CircleLongRadius:     a.CircleLongRadius + (b.CircleLongRadius-a.CircleLongRadius)*ratio,
+		CircleShortDirection: interpolateDegrees(a.CircleShortDirection, b.CircleShortDirection, ratio),
+		CircleShortRadius:    a.CircleShortRadius + (b.CircleShortRadius-a.CircleShortRadius)*ratio,
+		QuadrantRadii:        quadrantRadii,
+		ValidTime:            t,
+		LeadHours:            a.LeadHours + int(math.Round(float64(b.LeadHours-a.LeadHours)*ratio)),
+	}
+}
+
+// 実況・予報の経路から時刻tの中心位置・中心気圧・最大風速を求める
+// 前後の実況・予報の間は中心を大円に沿って動かし、中心気圧・最大風速は線形に変える
+// tが経路の時刻の範囲外ならfalseを返す
+func InterpolateTyphoonAt(typhoons []model.Typhoon, t time.Time) (model.Typhoon, bool) {
+	sorted := append([]model.Typhoon{}, typhoons...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ValidTime.Before(sorted[j].ValidTime)
+	})
+
+	for i, b := range sorted {
+		if b.ValidTime.Equal(t) {
+			return b, true
+		}
+		if i == 0 || !b.ValidTime.After(t) || !sorted[i-1].ValidTime.Before(t) {
+			continue
+		}
+		a := sorted[i-1]
+		ratio := float64(t.Sub(a.ValidTime)) / float64(b.ValidTime.Sub(a.ValidTime))
+		distance := HaversineDistance(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+		theta := CalculateTheta(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+		center := CalcCirclePoint(a.Latitude, a.Longitude, distance*ratio, theta)
+
+		typhoon := a
+		typhoon.ValidTime = t
+		typhoon.TargetTimestamp = t.UTC().Format(TargetTimestampLayout)
+		typhoon.Latitude = center.Latitude
+		typhoon.Longitude = center.Longitude
+		typhoon.CentralPressure = a.CentralPressure + int(math.Round(float64(b.CentralPressure-a.CentralPressure)*ratio))
+		typhoon.MaxWindSpeedNearTheCenter = a.MaxWindSpeedNearTheCenter + int(math.Round(float64(b.MaxWindSpeedNearTheCenter-a.MaxWindSpeedNearTheCenter)*ratio))
+		typhoon.WarningAreas = nil
+		return typhoon, true
+	}
+	return model.Typhoon{}, false
+}
+
+// 時系列の間をstepごとに補間した円で埋める
+func InterpolateStormAreaTimeSeries(stormAreaTimeSeries []model.StormArea, step time.Duration) []model.StormArea {
+	if len(stormAreaTimeSeries) == 0 || step <= 0 {
+		return stormAreaTimeSeries
+	}
+
+	interpolated := []model.StormArea{}
+	for i := range stormAreaTimeSeries[:len(stormAreaTimeSeries)-1] {
+		a, b := stormAreaTimeSeries[i], stormAreaTimeSeries[i+1]
+		interpolated = append(interpolated, a)
+		for t := a.ValidTime.Add(step); t.Before(b.ValidTime); t = t.Add(step) {
+			interpolated = append(interpolated, InterpolateStormArea(a, b, t))
+		}
+	}
+	interpolated = append(interpolated, stormAreaTimeSeries[len(stormAreaTimeSeries)-1])
+
+	return interpolated
+}
+
+// 角度を近い向きに回して補間する
+func interpolateDegrees(a, b, ratio float64) float64 {
+	d := math.Mod(b-a+540, 360) - 180
+	return a + d*ratio
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"testing"
+	"typhoon-polygon/model"
+)
+
+func TestNormalizeFullWidthDigits(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"予報　１２０時間後", "予報 120時間後"},
+		{"推定　１時間後", "推定 1時間後"},
+		{"実況", "実況"},
+		{"予報 24時間後", "予報 24時間後"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeFullWidthDigits(tt.in); got != tt.want {
+			t.Errorf("NormalizeFullWidthDigits(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTargetTimestampType(t *testing.T) {
+	tests := []struct {
+		in        string
+		fixKind   model.FixKind
+		leadHours int
+		wantErr   bool
+	}{
+		{"実況", model.FixKindAnalysis, 0, false},
+		{"推定　１時間後", model.FixKindEstimate, 1, false},
+		{"予報　１２時間後", model.FixKindForecast, 12, false},
+		{"予報　１２０時間後", model.FixKindForecast, 120, false},
+		{"予報 45時間後", model.FixKindForecast, 45, false},
+		{"予報", "", 0, true},
+		{"解析", "", 0, true},
+	}
+	for _, tt := range tests {
+		fixKind, leadHours, err := ParseTargetTimestampType(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTargetTimestampType(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if fixKind != tt.fixKind || leadHours != tt.leadHours {
+			t.Errorf("ParseTargetTimestampType(%q) = (%s, %d), want (%s, %d)", tt.in, fixKind, leadHours, tt.fixKind, tt.leadHours)
+		}
+	}
+}
+
+// リポジトリの電文に含まれる種別が全てパースできること
+func TestParseTargetTimestampTypeRepositoryXML(t *testing.T) {
+	registry, err := LoadStormRegistry("../xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[model.FixKind]bool{}
+	for _, storm := range registry.Storms() {
+		for _, advisory := range storm.Advisories {
+			for _, typhoon := range advisory.Typhoons {
+				fixKind, _, err := ParseTargetTimestampType(typhoon.TargetTimestampType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				seen[fixKind] = true
+			}
+		}
+	}
+	for _, fixKind := range []model.FixKind{model.FixKindAnalysis, model.FixKindEstimate, model.FixKindForecast} {
+		if !seen[fixKind] {
+			t.Errorf("%s が電文に無い", fixKind)
+		}
+	}
+}
+
+func TestInLeadRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   model.ProductOptions
+		leadHours int
+		want      bool
+	}{
+		{"制限なし", model.ProductOptions{}, 120, true},
+		{"0-0は実況だけ", model.ProductOptions{MaxLeadHours: 0, HasMaxLeadHours: true}, 0, true},
+		{"0-0は予報を含まない", model.ProductOptions{MaxLeadHours: 0, HasMaxLeadHours: true}, 12, false},
+		{"0-48の48時間後", model.ProductOptions{MaxLeadHours: 48, HasMaxLeadHours: true}, 48, true},
+		{"0-48の72時間後", model.ProductOptions{MaxLeadHours: 48, HasMaxLeadHours: true}, 72, false},
+		{"24-の12時間後", model.ProductOptions{MinLeadHours: 24}, 12, false},
+	}
+	for _, tt := range tests {
+		if got := InLeadRange(tt.leadHours, tt.options); got != tt.want {
+			t.Errorf("%s: InLeadRange(%d) = %v, want %v", tt.name, tt.leadHours, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 地図画像を1コマずつ描いてアニメーションGIFに書き出す
+func SaveMapGIF(path string, scenes []model.MapScene, delay time.Duration) error {
+	centiseconds, err := animationDelay(delay)
+	if err != nil {
+		return err
+	}
+	animation := &gif.GIF{}
+	for _, scene := range scenes {
+		img, err := RenderMapImage(scene)
+		if err != nil {
+			return err
+		}
+		// GIFは256色までなので誤差拡散で減色する
+		paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, int(centiseconds))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gif.EncodeAll(f, animation)
+}
+
+// 地図画像を1コマずつ描いてAPNGに書き出す
+// 標準ライブラリにはAPNGが無いので、コマごとにPNGにしてからチャンクを組み替える
+func SaveMapAPNG(path string, scenes []model.MapScene, delay time.Duration) error {
+	if len(scenes) == 0 {
+		return fmt.Errorf("コマがありません")
+	}
+	centiseconds, err := animationDelay(delay)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+		return err
+	}
+
+	sequence := uint32(0)
+	var firstHeader []byte
+	for i, scene := range scenes {
+		img, err := RenderMapImage(scene)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		chunks, err := readPNGChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		header := chunks[0].data
+		if i == 0 {
+			// 全てのコマで同じIHDRを使うので、最初のコマのIHDRとacTLを書く
+			firstHeader = header
+			if err := writePNGChunk(f, "IHDR", header); err != nil {
+				return err
+			}
+			acTL := make([]byte, 8)
+			binary.BigEndian.PutUint32(acTL[0:], uint32(len(scenes)))
+			binary.BigEndian.PutUint32(acTL[4:], 0) // 無限にくり返す
+			if err := writePNGChunk(f, "acTL", acTL); err != nil {
+				return err
+			}
+		} else if !bytes.Equal(header, firstHeader) {
+			return fmt.Errorf("コマ%dの大きさ・色の形式が最初のコマと違います", i+1)
+		}
+
+		fcTL := make([]byte, 26)
+		binary.BigEndian.PutUint32(fcTL[0:], sequence)
+		binary.BigEndian.PutUint32(fcTL[4:], uint32(scene.Width))
+		binary.BigEndian.PutUint32(fcTL[8:], uint32(scene.Height))
+		binary.BigEndian.PutUint32(fcTL[12:], 0) // x offset
+		binary.BigEndian.PutUint32(fcTL[16:], 0) // y offset
+		// 表示時間はGIFと同じ1/100秒単位 (delay_num / delay_den)
+		binary.BigEndian.PutUint16(fcTL[20:], centiseconds)
+		binary.BigEndian.PutUint16(fcTL[22:], 100)
+		fcTL[24] = 0 // dispose_op: APNG_DISPOSE_OP_NONE
+		fcTL[25] = 0 // blend_op: APNG_BLEND_OP_SOURCE
+		sequence++
+		if err := writePNGChunk(f, "fcTL", fcTL); err != nil {
+			return err
+		}
+
+		for _, chunk := range chunks {
+			if chunk.chunkType != "IDAT" {
+				continue
+			}
+			// 最初のコマはIDATのまま、2コマ目以降は連番を付けたfdATにする
+			if i == 0 {
+				err = writePNGChunk(f, "IDAT", chunk.data)
+			} else {
+				fdAT := make([]byte, 4+len(chunk.data))
+				binary.BigEndian.PutUint32(fdAT, sequence)
+				copy(fdAT[4:], chunk.data)
+				sequence++
+				err = writePNGChunk(f, "fdAT", fdAT)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return writePNGChunk(f, "IEND", nil)
+}
+
+// 1コマの表示時間を1/100秒単位にする
+// GIFもAPNGも16bitで持つので、655.35秒より長い時間は書けない
+func animationDelay(delay time.Duration) (uint16, error) {
+	centiseconds := delay / (10 * time.Millisecond)
+	if delay < 0 || centiseconds > math.MaxUint16 {
+		return 0, fmt.Errorf("1コマの表示時間は0秒から655.35秒までです: %s", delay)
+	}
+	return uint16(centiseconds), nil
+}
+
+type pngChunk struct {
+	chunkType string
+	data      []byte
+}
+
+// PNGのチャンクを順に読み出す (先頭はIHDR)
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	data = data[8:]
+	chunks := []pngChunk{}
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		if int(length)+12 > len(data) {
+			return nil, fmt.Errorf("PNGのチャンクが壊れています")
+		}
+		chunks = append(chunks, pngChunk{
+			chunkType: string(data[4:8]),
+			data:      data[8 : 8+length],
+		})
+		data = data[12+length:]
+	}
+	if len(chunks) == 0 || chunks[0].chunkType != "IHDR" {
+		return nil, fmt.Errorf("PNGにIHDRがありません")
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+func makeTestAnimationScenes(count int) []model.MapScene {
+	scenes := []model.MapScene{}
+	for i := 0; i < count; i++ {
+		latitude := 25 + float64(i)
+		scenes = append(scenes, model.MapScene{
+			Width:      120,
+			Height:     100,
+			Projection: "equirectangular",
+			Bounds:     model.MapBounds{MinLatitude: 20, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 140},
+			Layers: []model.MapLayer{{
+				Name:  "forecast_cone",
+				Style: model.MapStyle{Fill: "#ff0000", FillOpacity: 0.3, Stroke: "#ff0000", StrokeWidth: 1},
+				Polygons: [][]model.Point{
+					{{Latitude: latitude, Longitude: 128}, {Latitude: latitude + 3, Longitude: 128}, {Latitude: latitude + 3, Longitude: 132}},
+				},
+			}},
+		})
+	}
+	return scenes
+}
+
+// APNGのチャンクを読み、CRCを確かめながら順に返す
+func readTestAPNGChunks(t *testing.T, data []byte) []pngChunk {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Fatalf("PNGのシグネチャがありません")
+	}
+	data = data[8:]
+	chunks := []pngChunk{}
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("チャンクの途中でファイルが終わっています")
+		}
+		length := binary.BigEndian.Uint32(data)
+		if int(length)+12 > len(data) {
+			t.Fatalf("チャンクの長さ %d がファイルの残りより長い", length)
+		}
+		chunkType := string(data[4:8])
+		want := binary.BigEndian.Uint32(data[8+length:])
+		if got := crc32.ChecksumIEEE(data[4 : 8+length]); got != want {
+			t.Errorf("%sのCRC = %08x, want %08x", chunkType, got, want)
+		}
+		chunks = append(chunks, pngChunk{chunkType: chunkType, data: data[8 : 8+length]})
+		data = data[12+length:]
+	}
+	return chunks
+}
+
+// APNGを読み直して、コマ数・連番・表示時間・CRCが正しく、普通のPNGとしても開けること
+func TestSaveMapAPNG(t *testing.T) {
+	scenes := makeTestAnimationScenes(3)
+	path := filepath.Join(t.TempDir(), "animation.png")
+	if err := SaveMapAPNG(path, scenes, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := readTestAPNGChunks(t, data)
+	if chunks[0].chunkType != "IHDR" {
+		t.Fatalf("先頭のチャンク = %s, want IHDR", chunks[0].chunkType)
+	}
+	if last := chunks[len(chunks)-1]; last.chunkType != "IEND" {
+		t.Errorf("最後のチャンク = %s, want IEND", last.chunkType)
+	}
+
+	frames := 0
+	sequence := uint32(0)
+	seenIDAT := false
+	for i, chunk := range chunks {
+		switch chunk.chunkType {
+		case "acTL":
+			if i != 1 {
+				t.Errorf("acTLの位置 = %d, want 1", i)
+			}
+			if got := binary.BigEndian.Uint32(chunk.data); got != uint32(len(scenes)) {
+				t.Errorf("acTLのコマ数 = %d, want %d", got, len(scenes))
+			}
+			if got := binary.BigEndian.Uint32(chunk.data[4:]); got != 0 {
+				t.Errorf("acTLのくり返し回数 = %d, want 0", got)
+			}
+		case "fcTL":
+			frames++
+			if got := binary.BigEndian.Uint32(chunk.data); got != sequence {
+				t.Errorf("コマ%dのfcTLの連番 = %d, want %d", frames, got, sequence)
+			}
+			sequence++
+			width := binary.BigEndian.Uint32(chunk.data[4:])
+			height := binary.BigEndian.Uint32(chunk.data[8:])
+			if width != 120 || height != 100 {
+				t.Errorf("コマ%dの大きさ = %dx%d, want 120x100", frames, width, height)
+			}
+			num := binary.BigEndian.Uint16(chunk.data[20:])
+			den := binary.BigEndian.Uint16(chunk.data[22:])
+			if num != 150 || den != 100 {
+				t.Errorf("コマ%dの表示時間 = %d/%d, want 150/100", frames, num, den)
+			}
+		case "IDAT":
+			seenIDAT = true
+			if frames != 1 {
+				t.Errorf("IDATがコマ%dにあります", frames)
+			}
+		case "fdAT":
+			if frames < 2 {
+				t.Errorf("fdATが最初のコマにあります")
+			}
+			if got := binary.BigEndian.Uint32(chunk.data); got != sequence {
+				t.Errorf("コマ%dのfdATの連番 = %d, want %d", frames, got, sequence)
+			}
+			sequence++
+		}
+	}
+	if frames != len(scenes) {
+		t.Errorf("fcTLの数 = %d, want %d", frames, len(scenes))
+	}
+	if !seenIDAT {
+		t.Errorf("IDATがありません")
+	}
+
+	// APNGに対応していないデコーダーでは最初のコマが見える
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 120 || img.Bounds().Dy() != 100 {
+		t.Errorf("最初のコマの大きさ = %v, want 120x100", img.Bounds())
+	}
+}
+
+// GIFを読み直して、コマ数と表示時間が正しいこと
+func TestSaveMapGIF(t *testing.T) {
+	scenes := makeTestAnimationScenes(3)
+	path := filepath.Join(t.TempDir(), "animation.gif")
+	if err := SaveMapGIF(path, scenes, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	animation, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != len(scenes) {
+		t.Errorf("コマ数 = %d, want %d", len(animation.Image), len(scenes))
+	}
+	for i, delay := range animation.Delay {
+		if delay != 150 {
+			t.Errorf("コマ%dの表示時間 = %d, want 150", i+1, delay)
+		}
+	}
+}
+
+// 16bitに収まらない表示時間は書き出さずにエラーにすること
+func TestAnimationDelay(t *testing.T) {
+	cases := []struct {
+		delay   time.Duration
+		want    uint16
+		wantErr bool
+	}{
+		{delay: 500 * time.Millisecond, want: 50},
+		{delay: 0, want: 0},
+		{delay: 65 * time.Second, want: 6500},
+		{delay: 655350 * time.Millisecond, want: 65535},
+		{delay: 70 * time.Second, want: 7000},
+		{delay: 655360 * time.Millisecond, wantErr: true},
+		{delay: 20 * time.Minute, wantErr: true},
+		{delay: -time.Second, wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := animationDelay(c.delay)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("animationDelay(%s) = %d, want error", c.delay, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("animationDelay(%s): %v", c.delay, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("animationDelay(%s) = %d, want %d", c.delay, got, c.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "animation.png")
+	if err := SaveMapAPNG(path, makeTestAnimationScenes(1), 20*time.Minute); err == nil {
+		t.Errorf("長すぎる表示時間でAPNGが書き出された")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("エラーのときにファイルが作られている")
+	}
+}
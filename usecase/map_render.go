@@ -0,0 +1,460 @@
+package usecase
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"typhoon-polygon/model"
+
+	geojson "github.com/paulmach/go.geojson"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// 地図画像の海の色
+const mapOceanColor = "#e6eef5"
+
+// 緯度経度を地図画像上のピクセル座標に変換する
+type MapProjector struct {
+	minY, maxY   float64
+	minX, maxX   float64
+	scaleX       float64
+	scaleY       float64
+	isMercator   bool
+	mercatorClip float64
+}
+
+func NewMapProjector(scene model.MapScene) MapProjector {
+	p := MapProjector{
+		isMercator:   scene.Projection == "mercator",
+		mercatorClip: 85,
+	}
+	p.minX = scene.Bounds.MinLongitude
+	p.maxX = scene.Bounds.MaxLongitude
+	p.minY = p.projectLatitude(scene.Bounds.MinLatitude)
+	p.maxY = p.projectLatitude(scene.Bounds.MaxLatitude)
+	p.scaleX = float64(scene.Width) / (p.maxX - p.minX)
+	p.scaleY = float64(scene.Height) / (p.maxY - p.minY)
+	return p
+}
+
+func (p MapProjector) projectLatitude(lat float64) float64 {
+	if !p.isMercator {
+		return lat
+	}
+	lat = math.Max(-p.mercatorClip, math.Min(p.mercatorClip, lat))
+	return radToDeg(math.Log(math.Tan(math.Pi/4 + degToRad(lat)/2)))
+}
+
+func (p MapProjector) Project(point model.Point) (float64, float64) {
+	x := (point.Longitude - p.minX) * p.scaleX
+	y := (p.maxY - p.projectLatitude(point.Latitude)) * p.scaleY
+	return x, y
+}
+
+// 全ての点が入る範囲に余白(度)を足した範囲
+func MakeMapBounds(points []model.Point, margin float64) model.MapBounds {
+	bounds := model.MapBounds{
+		MinLatitude:  math.Inf(1),
+		MaxLatitude:  math.Inf(-1),
+		MinLongitude: math.Inf(1),
+		MaxLongitude: math.Inf(-1),
+	}
+	for _, point := range points {
+		bounds.MinLatitude = math.Min(bounds.MinLatitude, point.Latitude)
+		bounds.MaxLatitude = math.Max(bounds.MaxLatitude, point.Latitude)
+		bounds.MinLongitude = math.Min(bounds.MinLongitude, point.Longitude)
+		bounds.MaxLongitude = math.Max(bounds.MaxLongitude, point.Longitude)
+	}
+	if len(points) == 0 {
+		return model.MapBounds{MinLatitude: 20, MaxLatitude: 50, MinLongitude: 120, MaxLongitude: 150}
+	}
+	bounds.MinLatitude = math.Max(-85, bounds.MinLatitude-margin)
+	bounds.MaxLatitude = math.Min(85, bounds.MaxLatitude+margin)
+	bounds.MinLongitude -= margin
+	bounds.MaxLongitude += margin
+	return bounds
+}
+
+// 2つの範囲が両方入る範囲
+func UnionMapBounds(a, b model.MapBounds) model.MapBounds {
+	return model.MapBounds{
+		MinLatitude:  math.Min(a.MinLatitude, b.MinLatitude),
+		MaxLatitude:  math.Max(a.MaxLatitude, b.MaxLatitude),
+		MinLongitude: math.Min(a.MinLongitude, b.MinLongitude),
+		MaxLongitude: math.Max(a.MaxLongitude, b.MaxLongitude),
+	}
+}
+
+// 2つの範囲が重なるか
+func IntersectMapBounds(a, b model.MapBounds) bool {
+	return a.MinLatitude <= b.MaxLatitude && b.MinLatitude <= a.MaxLatitude &&
+		a.MinLongitude <= b.MaxLongitude && b.MinLongitude <= a.MaxLongitude
+}
+
+// 縦横比が崩れないように、幅から高さを決める
+func MapHeightForWidth(bounds model.MapBounds, width int, projection string) int {
+	p := MapProjector{isMercator: projection == "mercator", mercatorClip: 85}
+	dy := p.projectLatitude(bounds.MaxLatitude) - p.projectLatitude(bounds.MinLatitude)
+	dx := bounds.MaxLongitude - bounds.MinLongitude
+	if dx <= 0 {
+		return width
+	}
+	return int(math.Round(float64(width) * dy / dx))
+}
+
+// Natural EarthなどのGeoJSONから海岸線を線として読み込む
+// ポリゴン(陸地)の場合は外周・内周をそのまま線にする
+func ReadCoastlines(path string) ([][]model.Point, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	featureCollection, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := [][]model.Point{}
+	appendLine := func(coordinates [][]float64) {
+		line := make([]model.Point, 0, len(coordinates))
+		for _, coordinate := range coordinates {
+			line = append(line, model.Point{Latitude: coordinate[1], Longitude: coordinate[0]})
+		}
+		lines = append(lines, line)
+	}
+	for _, feature := range featureCollection.Features {
+		geometry := feature.Geometry
+		if geometry == nil {
+			continue
+		}
+		switch {
+		case geometry.IsLineString():
+			appendLine(geometry.LineString)
+		case geometry.IsMultiLineString():
+			for _, lineString := range geometry.MultiLineString {
+				appendLine(lineString)
+			}
+		case geometry.IsPolygon():
+			for _, ring := range geometry.Polygon {
+				appendLine(ring)
+			}
+		case geometry.IsMultiPolygon():
+			for _, polygon := range geometry.MultiPolygon {
+				for _, ring := range polygon {
+					appendLine(ring)
+				}
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+// "#rrggbb"をcolor.RGBAにする
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("無効な色: %s", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("無効な色: %s", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// 地図画像をPNG用の画像として描く
+func RenderMapImage(scene model.MapScene) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, scene.Width, scene.Height))
+	projector := NewMapProjector(scene)
+
+	ocean, _ := parseHexColor(mapOceanColor)
+	for y := 0; y < scene.Height; y++ {
+		for x := 0; x < scene.Width; x++ {
+			img.SetRGBA(x, y, ocean)
+		}
+	}
+
+	for _, layer := range scene.Layers {
+		var fill, stroke color.RGBA
+		var err error
+		if layer.Style.Fill != "" {
+			if fill, err = parseHexColor(layer.Style.Fill); err != nil {
+				return nil, err
+			}
+		}
+		if layer.Style.Stroke != "" {
+			if stroke, err = parseHexColor(layer.Style.Stroke); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, polygon := range layer.Polygons {
+			// GEOSの差分などで空のポリゴンが来ることがある
+			if len(polygon) == 0 {
+				continue
+			}
+			pixels := projectPoints(projector, polygon)
+			if layer.Style.Fill != "" {
+				fillPolygon(img, pixels, fill, layer.Style.FillOpacity)
+			}
+			if layer.Style.Stroke != "" {
+				drawPolyline(img, append(pixels, pixels[0]), stroke, layer.Style.StrokeWidth)
+			}
+		}
+		for _, line := range layer.Lines {
+			if layer.Style.Stroke != "" {
+				drawPolyline(img, projectPoints(projector, line), stroke, layer.Style.StrokeWidth)
+			}
+		}
+		for _, point := range layer.Points {
+			x, y := projector.Project(point)
+			if layer.Style.Fill != "" {
+				fillCircle(img, x, y, layer.Style.PointRadius, fill, 1)
+			}
+		}
+	}
+
+	drawTitle(img, scene.Title)
+
+	return img, nil
+}
+
+func SaveMapPNG(path string, scene model.MapScene) error {
+	img, err := RenderMapImage(scene)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+func projectPoints(projector MapProjector, points []model.Point) [][2]float64 {
+	pixels := make([][2]float64, 0, len(points))
+	for _, point := range points {
+		x, y := projector.Project(point)
+		pixels = append(pixels, [2]float64{x, y})
+	}
+	return pixels
+}
+
+// 1ピクセルを不透明度alphaで塗り重ねる
+func blendPixel(img *image.RGBA, x, y int, c color.RGBA, alpha float64) {
+	if !(image.Point{X: x, Y: y}.In(img.Rect)) {
+		return
+	}
+	dst := img.RGBAAt(x, y)
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round(float64(s)*alpha + float64(d)*(1-alpha)))
+	}
+	img.SetRGBA(x, y, color.RGBA{R: mix(c.R, dst.R), G: mix(c.G, dst.G), B: mix(c.B, dst.B), A: 255})
+}
+
+// 走査線でポリゴンを塗る (偶奇規則)
+func fillPolygon(img *image.RGBA, pixels [][2]float64, c color.RGBA, alpha float64) {
+	if len(pixels) < 3 {
+		return
+	}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range pixels {
+		minY = math.Min(minY, p[1])
+		maxY = math.Max(maxY, p[1])
+	}
+	startY := int(math.Max(0, math.Floor(minY)))
+	endY := int(math.Min(float64(img.Rect.Max.Y-1), math.Ceil(maxY)))
+
+	for y := startY; y <= endY; y++ {
+		scanY := float64(y) + 0.5
+		xs := []float64{}
+		for i := range pixels {
+			p1 := pixels[i]
+			p2 := pixels[(i+1)%len(pixels)]
+			if (p1[1] <= scanY && p2[1] > scanY) || (p2[1] <= scanY && p1[1] > scanY) {
+				xs = append(xs, p1[0]+(scanY-p1[1])*(p2[0]-p1[0])/(p2[1]-p1[1]))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			for x := int(math.Round(xs[i])); x < int(math.Round(xs[i+1])); x++ {
+				blendPixel(img, x, y, c, alpha)
+			}
+		}
+	}
+}
+
+func fillCircle(img *image.RGBA, cx, cy, radius float64, c color.RGBA, alpha float64) {
+	for y := int(math.Floor(cy - radius)); y <= int(math.Ceil(cy+radius)); y++ {
+		for x := int(math.Floor(cx - radius)); x <= int(math.Ceil(cx+radius)); x++ {
+			dx := float64(x) + 0.5 - cx
+			dy := float64(y) + 0.5 - cy
+			if dx*dx+dy*dy <= radius*radius {
+				blendPixel(img, x, y, c, alpha)
+			}
+		}
+	}
+}
+
+// 線分に沿って円を並べて太さのある線を描く
+func drawPolyline(img *image.RGBA, pixels [][2]float64, c color.RGBA, width float64) {
+	radius := math.Max(0.5, width/2)
+	drawn := map[image.Point]bool{}
+	for i := 0; i+1 < len(pixels); i++ {
+		p1, p2 := pixels[i], pixels[i+1]
+		if segmentOutside(img.Rect, p1, p2, radius) {
+			continue
+		}
+		length := math.Hypot(p2[0]-p1[0], p2[1]-p1[1])
+		steps := int(math.Ceil(length / 0.5))
+		for s := 0; s <= steps; s++ {
+			t := 0.0
+			if steps > 0 {
+				t = float64(s) / float64(steps)
+			}
+			cx := p1[0] + (p2[0]-p1[0])*t
+			cy := p1[1] + (p2[1]-p1[1])*t
+			for y := int(math.Floor(cy - radius)); y <= int(math.Ceil(cy+radius)); y++ {
+				for x := int(math.Floor(cx - radius)); x <= int(math.Ceil(cx+radius)); x++ {
+					dx := float64(x) + 0.5 - cx
+					dy := float64(y) + 0.5 - cy
+					key := image.Point{X: x, Y: y}
+					if dx*dx+dy*dy <= radius*radius && !drawn[key] {
+						// 同じピクセルを重ね塗りしないように記録する
+						drawn[key] = true
+						blendPixel(img, x, y, c, 1)
+					}
+				}
+			}
+		}
+	}
+}
+
+// 線分が画像の外側にあるか (海岸線など描く範囲外の線を飛ばすため)
+func segmentOutside(rect image.Rectangle, p1, p2 [2]float64, radius float64) bool {
+	minX, maxX := math.Min(p1[0], p2[0])-radius, math.Max(p1[0], p2[0])+radius
+	minY, maxY := math.Min(p1[1], p2[1])-radius, math.Max(p1[1], p2[1])+radius
+	return maxX < float64(rect.Min.X) || minX > float64(rect.Max.X) || maxY < float64(rect.Min.Y) || minY > float64(rect.Max.Y)
+}
+
+func lineOutside(rect image.Rectangle, pixels [][2]float64) bool {
+	for i := 0; i+1 < len(pixels); i++ {
+		if !segmentOutside(rect, pixels[i], pixels[i+1], 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// 左上に白地の枠を付けてタイトルを書く
+func drawTitle(img *image.RGBA, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	face := basicfont.Face7x13
+	lineHeight := face.Metrics().Height.Ceil() + 2
+	width := 0
+	for _, line := range lines {
+		width = max(width, font.MeasureString(face, line).Ceil())
+	}
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for y := 4; y < 4+lineHeight*len(lines)+8; y++ {
+		for x := 4; x < 4+width+12; x++ {
+			blendPixel(img, x, y, white, 0.85)
+		}
+	}
+
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.RGBA{A: 255}),
+		Face: face,
+	}
+	for i, line := range lines {
+		drawer.Dot = fixed.P(10, 4+lineHeight*(i+1))
+		drawer.DrawString(line)
+	}
+}
+
+// 地図画像をSVGとして描く
+func RenderMapSVG(scene model.MapScene) string {
+	projector := NewMapProjector(scene)
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", scene.Width, scene.Height, scene.Width, scene.Height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", scene.Width, scene.Height, mapOceanColor)
+
+	for _, layer := range scene.Layers {
+		fmt.Fprintf(&b, `<g id="%s">`+"\n", html.EscapeString(layer.Name))
+		fill, fillOpacity := "none", 0.0
+		if layer.Style.Fill != "" {
+			fill, fillOpacity = layer.Style.Fill, layer.Style.FillOpacity
+		}
+		stroke := "none"
+		if layer.Style.Stroke != "" {
+			stroke = layer.Style.Stroke
+		}
+
+		for _, polygon := range layer.Polygons {
+			if len(polygon) == 0 {
+				continue
+			}
+			fmt.Fprintf(
+				&b, `<path d="%sZ" fill="%s" fill-opacity="%.2f" stroke="%s" stroke-width="%.1f"/>`+"\n",
+				svgPath(projectPoints(projector, polygon)), fill, fillOpacity, stroke, layer.Style.StrokeWidth,
+			)
+		}
+		for _, line := range layer.Lines {
+			pixels := projectPoints(projector, line)
+			if lineOutside(image.Rect(0, 0, scene.Width, scene.Height), pixels) {
+				continue
+			}
+			fmt.Fprintf(
+				&b, `<path d="%s" fill="none" stroke="%s" stroke-width="%.1f"/>`+"\n",
+				svgPath(pixels), stroke, layer.Style.StrokeWidth,
+			)
+		}
+		for _, point := range layer.Points {
+			x, y := projector.Project(point)
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n", x, y, layer.Style.PointRadius, fill)
+		}
+		b.WriteString("</g>\n")
+	}
+
+	if len(scene.Title) > 0 {
+		fmt.Fprintf(&b, `<rect x="4" y="4" width="%d" height="%d" fill="#ffffff" fill-opacity="0.85"/>`+"\n", scene.Width/2, 16*len(scene.Title)+8)
+		for i, line := range scene.Title {
+			fmt.Fprintf(&b, `<text x="10" y="%d" font-family="sans-serif" font-size="13">%s</text>`+"\n", 4+16*(i+1), html.EscapeString(line))
+		}
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func SaveMapSVG(path string, scene model.MapScene) error {
+	return os.WriteFile(path, []byte(RenderMapSVG(scene)), 0644)
+}
+
+func svgPath(pixels [][2]float64) string {
+	var b strings.Builder
+	for i, p := range pixels {
+		command := "L"
+		if i == 0 {
+			command = "M"
+		}
+		fmt.Fprintf(&b, "%s%.1f %.1f ", command, p[0], p[1])
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,220 @@
+package usecase
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+// 空のポリゴンがあっても描けること
+func TestRenderMapEmptyPolygon(t *testing.T) {
+	scene := model.MapScene{
+		Width:      200,
+		Height:     200,
+		Projection: "equirectangular",
+		Bounds:     model.MapBounds{MinLatitude: 20, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 140},
+		Layers: []model.MapLayer{{
+			Name:  "forecast_cone_added",
+			Style: model.MapStyle{Fill: "#ff0000", FillOpacity: 0.3, Stroke: "#ff0000", StrokeWidth: 1},
+			Polygons: [][]model.Point{
+				{},
+				{{Latitude: 30, Longitude: 130}, {Latitude: 32, Longitude: 130}, {Latitude: 32, Longitude: 132}},
+			},
+		}},
+	}
+
+	if _, err := RenderMapImage(scene); err != nil {
+		t.Fatal(err)
+	}
+	svg := RenderMapSVG(scene)
+	if strings.Contains(svg, `d="Z"`) {
+		t.Errorf("空のポリゴンのpathが書き出されている")
+	}
+	if strings.Count(svg, "<path") != 1 {
+		t.Errorf("pathの数 = %d, want 1", strings.Count(svg, "<path"))
+	}
+}
+
+// 東経120〜160度・北緯20〜40度を200x100pxに描く
+func makeTestMapScene(layers []model.MapLayer) model.MapScene {
+	return model.MapScene{
+		Width:      200,
+		Height:     100,
+		Projection: "equirectangular",
+		Bounds:     model.MapBounds{MinLatitude: 20, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 160},
+		Layers:     layers,
+	}
+}
+
+func TestMapProjector(t *testing.T) {
+	equirectangular := NewMapProjector(makeTestMapScene(nil))
+	mercator := NewMapProjector(model.MapScene{
+		Width:      360,
+		Height:     300,
+		Projection: "mercator",
+		Bounds:     model.MapBounds{MinLatitude: 0, MaxLatitude: 60, MinLongitude: 120, MaxLongitude: 180},
+	})
+	cases := []struct {
+		name      string
+		projector MapProjector
+		point     model.Point
+		x, y      float64
+	}{
+		{"正距円筒の左上", equirectangular, model.Point{Latitude: 40, Longitude: 120}, 0, 0},
+		{"正距円筒の右下", equirectangular, model.Point{Latitude: 20, Longitude: 160}, 200, 100},
+		{"正距円筒の中央", equirectangular, model.Point{Latitude: 30, Longitude: 140}, 100, 50},
+		{"正距円筒の範囲外", equirectangular, model.Point{Latitude: 10, Longitude: 110}, -50, 150},
+		{"メルカトルの左上", mercator, model.Point{Latitude: 60, Longitude: 120}, 0, 0},
+		{"メルカトルの右下", mercator, model.Point{Latitude: 0, Longitude: 180}, 360, 300},
+		// 北緯30度は ln(tan(60°)) = 31.47度、北緯60度は75.46度に投影される
+		{"メルカトルの北緯30度", mercator, model.Point{Latitude: 30, Longitude: 150}, 180, 174.869},
+	}
+	for _, c := range cases {
+		x, y := c.projector.Project(c.point)
+		if math.Abs(x-c.x) > 1e-3 || math.Abs(y-c.y) > 1e-3 {
+			t.Errorf("%s: Project(%v) = (%.3f, %.3f), want (%.3f, %.3f)", c.name, c.point, x, y, c.x, c.y)
+		}
+	}
+
+	// メルカトルは北緯85度より高緯度を85度として扱う
+	_, y85 := mercator.Project(model.Point{Latitude: 85, Longitude: 150})
+	_, y89 := mercator.Project(model.Point{Latitude: 89, Longitude: 150})
+	if y85 != y89 {
+		t.Errorf("北緯89度のy = %v, want 北緯85度と同じ %v", y89, y85)
+	}
+}
+
+func TestMapBounds(t *testing.T) {
+	points := []model.Point{{Latitude: 25, Longitude: 130}, {Latitude: 35, Longitude: 140}, {Latitude: 82, Longitude: 135}}
+	got := MakeMapBounds(points, 5)
+	want := model.MapBounds{MinLatitude: 20, MaxLatitude: 85, MinLongitude: 125, MaxLongitude: 145}
+	if got != want {
+		t.Errorf("MakeMapBounds = %+v, want %+v", got, want)
+	}
+	if got := MakeMapBounds(nil, 5); got != (model.MapBounds{MinLatitude: 20, MaxLatitude: 50, MinLongitude: 120, MaxLongitude: 150}) {
+		t.Errorf("点が無いときの範囲 = %+v", got)
+	}
+
+	a := model.MapBounds{MinLatitude: 20, MaxLatitude: 30, MinLongitude: 120, MaxLongitude: 130}
+	b := model.MapBounds{MinLatitude: 25, MaxLatitude: 40, MinLongitude: 128, MaxLongitude: 150}
+	c := model.MapBounds{MinLatitude: 31, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 130}
+	if got := UnionMapBounds(a, b); got != (model.MapBounds{MinLatitude: 20, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 150}) {
+		t.Errorf("UnionMapBounds = %+v", got)
+	}
+	if !IntersectMapBounds(a, b) || IntersectMapBounds(a, c) {
+		t.Errorf("IntersectMapBounds(a, b) = %v, (a, c) = %v, want true, false", IntersectMapBounds(a, b), IntersectMapBounds(a, c))
+	}
+
+	// 縦横比が崩れない高さ
+	if got := MapHeightForWidth(model.MapBounds{MinLatitude: 20, MaxLatitude: 40, MinLongitude: 120, MaxLongitude: 160}, 200, "equirectangular"); got != 100 {
+		t.Errorf("正距円筒の高さ = %d, want 100", got)
+	}
+	if got := MapHeightForWidth(model.MapBounds{MinLatitude: 0, MaxLatitude: 60, MinLongitude: 120, MaxLongitude: 180}, 360, "mercator"); got != 453 {
+		t.Errorf("メルカトルの高さ = %d, want 453", got)
+	}
+}
+
+// 緯度経度の位置のピクセルに色が塗られ、後のレイヤーが上に重なること
+func TestRenderMapImage(t *testing.T) {
+	square := []model.Point{
+		{Latitude: 25, Longitude: 130}, {Latitude: 25, Longitude: 150},
+		{Latitude: 35, Longitude: 150}, {Latitude: 35, Longitude: 130},
+	}
+	scene := makeTestMapScene([]model.MapLayer{
+		{Name: "below", Style: model.MapStyle{Fill: "#ff0000", FillOpacity: 1}, Polygons: [][]model.Point{square}},
+		{Name: "above", Style: model.MapStyle{Fill: "#0000ff", FillOpacity: 1}, Polygons: [][]model.Point{{
+			{Latitude: 25, Longitude: 140}, {Latitude: 25, Longitude: 150},
+			{Latitude: 35, Longitude: 150}, {Latitude: 35, Longitude: 140},
+		}}},
+		{Name: "point", Style: model.MapStyle{Fill: "#00ff00", PointRadius: 2}, Points: []model.Point{{Latitude: 38, Longitude: 125}}},
+	})
+	img, err := RenderMapImage(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 100 {
+		t.Fatalf("画像の大きさ = %v, want 200x100", img.Bounds())
+	}
+
+	ocean, _ := parseHexColor(mapOceanColor)
+	cases := []struct {
+		name  string
+		point model.Point
+		want  string
+	}{
+		{"下のレイヤーだけの所", model.Point{Latitude: 30, Longitude: 135}, "#ff0000"},
+		{"2つのレイヤーが重なる所", model.Point{Latitude: 30, Longitude: 145}, "#0000ff"},
+		{"点の位置", model.Point{Latitude: 38, Longitude: 125}, "#00ff00"},
+		{"海", model.Point{Latitude: 22, Longitude: 155}, mapOceanColor},
+	}
+	projector := NewMapProjector(scene)
+	for _, c := range cases {
+		x, y := projector.Project(c.point)
+		want, _ := parseHexColor(c.want)
+		if got := img.RGBAAt(int(x), int(y)); got != want {
+			t.Errorf("%s (%v → %d,%d) の色 = %v, want %v", c.name, c.point, int(x), int(y), got, want)
+		}
+	}
+	if got := img.RGBAAt(199, 99); got != ocean {
+		t.Errorf("右下の色 = %v, want 海の色 %v", got, ocean)
+	}
+
+	// 無効な色はエラー
+	scene.Layers[0].Style.Fill = "red"
+	if _, err := RenderMapImage(scene); err == nil {
+		t.Errorf("無効な色でエラーにならない")
+	}
+}
+
+// SVGはレイヤーを順に<g>で書き、スタイルと投影した座標がそのまま入ること
+func TestRenderMapSVG(t *testing.T) {
+	scene := makeTestMapScene([]model.MapLayer{
+		{
+			Name:     "forecast_cone",
+			Style:    model.MapStyle{Fill: "#ff0000", FillOpacity: 0.3, Stroke: "#990000", StrokeWidth: 1.5},
+			Polygons: [][]model.Point{{{Latitude: 30, Longitude: 130}, {Latitude: 30, Longitude: 150}, {Latitude: 35, Longitude: 150}}},
+		},
+		{
+			Name:  "center_line",
+			Style: model.MapStyle{Stroke: "#000000", StrokeWidth: 2},
+			Lines: [][]model.Point{{{Latitude: 25, Longitude: 130}, {Latitude: 35, Longitude: 140}}},
+		},
+		{
+			Name:   "<centers>",
+			Style:  model.MapStyle{Fill: "#0000ff", PointRadius: 3},
+			Points: []model.Point{{Latitude: 30, Longitude: 140}},
+		},
+	})
+	scene.Title = []string{"TC2410 第20報"}
+	svg := RenderMapSVG(scene)
+
+	// 海・レイヤー(先頭から順)・タイトルの順に重なる
+	order := []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 200 100">`,
+		`<rect width="200" height="100" fill="` + mapOceanColor + `"/>`,
+		`<g id="forecast_cone">`,
+		`<path d="M50.0 50.0 L150.0 50.0 L150.0 25.0Z" fill="#ff0000" fill-opacity="0.30" stroke="#990000" stroke-width="1.5"/>`,
+		`<g id="center_line">`,
+		`<path d="M50.0 75.0 L100.0 25.0" fill="none" stroke="#000000" stroke-width="2.0"/>`,
+		`<g id="&lt;centers&gt;">`,
+		`<circle cx="100.0" cy="50.0" r="3.0" fill="#0000ff"/>`,
+		`<text x="10" y="20" font-family="sans-serif" font-size="13">TC2410 第20報</text>`,
+		`</svg>`,
+	}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(svg, s)
+		if i < 0 {
+			t.Errorf("SVGに %s が無い\n%s", s, svg)
+			continue
+		}
+		if i < last {
+			t.Errorf("%s の位置が前の要素より前にある", s)
+		}
+		last = i
+	}
+	if strings.Count(svg, "<g ") != 3 || strings.Count(svg, "</g>") != 3 {
+		t.Errorf("<g>の数 = %d/%d, want 3", strings.Count(svg, "<g "), strings.Count(svg, "</g>"))
+	}
+}
@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"math"
+	"math/rand"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 予報円は台風の中心が70%の確率で入る範囲
+const ForecastCircleProbability = 0.7
+
+// 中心位置の誤差を等方的な2次元正規分布とみなしたときの標準偏差(km)
+// 半径Rの円に入る確率は 1-exp(-R^2/2σ^2) なので、これが70%になるσを求める
+func ForecastCircleSigma(radius float64) float64 {
+	return radius / math.Sqrt(-2*math.Log(1-ForecastCircleProbability))
+}
+
+// 確率を求めるのに使う各時刻の中心・誤差・暴風の半径
+type probabilityStep struct {
+	validTime  time.Time
+	center     model.Point
+	sigma      float64 // 中心位置の誤差の標準偏差(km)
+	windRadius float64 // 指定した風速の範囲の半径(km)
+}
+
+// 実況・予報から各時刻の中心・誤差・暴風の半径を取り出し、stepごとに補間する
+// 予報の暴風警戒域は予報円に暴風域を足したものなので、予報円の半径を引いて暴風域の半径に戻す
+func makeProbabilitySteps(typhoons []model.Typhoon, options model.ProbabilityOptions, productOptions model.ProductOptions) []probabilityStep {
+	steps := []probabilityStep{}
+	for _, typhoon := range SelectProductTyphoons(typhoons, productOptions) {
+		step := probabilityStep{
+			validTime: typhoon.ValidTime,
+			center:    model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
+		}
+		forecastCircleRadius := 0.0
+		for _, warningArea := range typhoon.WarningAreas {
+			if warningArea.WarningAreaType == "予報円" {
+				forecastCircleRadius = float64(warningArea.CircleLongRadius)
+			}
+		}
+		for _, warningArea := range typhoon.WarningAreas {
+			if warningArea.WindSpeed == options.WindSpeed && warningArea.WarningAreaType != "予報円" {
+				step.windRadius = math.Max(0, float64(warningArea.CircleLongRadius)-forecastCircleRadius)
+				break
+			}
+		}
+		step.sigma = ForecastCircleSigma(forecastCircleRadius)
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 || options.Step <= 0 {
+		return steps
+	}
+
+	interpolated := []probabilityStep{}
+	for i := range steps[:len(steps)-1] {
+		a, b := steps[i], steps[i+1]
+		interpolated = append(interpolated, a)
+		duration := b.validTime.Sub(a.validTime)
+		distance := HaversineDistance(a.center.Latitude, a.center.Longitude, b.center.Latitude, b.center.Longitude)
+		theta := CalculateTheta(a.center.Latitude, a.center.Longitude, b.center.Latitude, b.center.Longitude)
+		for t := a.validTime.Add(options.Step); t.Before(b.validTime); t = t.Add(options.Step) {
+			ratio := float64(t.Sub(a.validTime)) / float64(duration)
+			interpolated = append(interpolated, probabilityStep{
+				validTime:  t,
+				center:     CalcCirclePoint(a.center.Latitude, a.center.Longitude, distance*ratio, theta),
+				sigma:      a.sigma + (b.sigma-a.sigma)*ratio,
+				windRadius: a.windRadius + (b.windRadius-a.windRadius)*ratio,
+			})
+		}
+	}
+	interpolated = append(interpolated, steps[len(steps)-1])
+
+	return interpolated
+}
+
+// 予報円から、格子ごとに中心がStrikeRadius以内を通る確率と、WindSpeed以上の風が吹く確率(%)を求める
+// 中心位置の誤差は予報円の半径に合わせた正規分布とし、1回の試行では全ての時刻で同じ向き・同じ割合だけずらす
+// (予報のずれは時刻の間で強く相関するため)
+// Samples回ずらした経路のうち、その格子にかかった割合を確率とする
+func CalcProbabilityGrids(typhoons []model.Typhoon, options model.ProbabilityOptions, productOptions model.ProductOptions) (model.Grid, model.Grid) {
+	steps := makeProbabilitySteps(typhoons, options, productOptions)
+
+	// 3σずらしても、半径の分まで入る範囲の格子を作る
+	points := []model.Point{}
+	margin := options.StrikeRadius
+	for _, step := range steps {
+		points = append(points, step.center)
+		margin = math.Max(margin, 3*step.sigma+math.Max(options.StrikeRadius, step.windRadius))
+	}
+	// 経度1度の距離は高緯度ほど短いので、範囲の極側の緯度で経度方向の余白を求める
+	bounds := MakeMapBounds(points, margin/kmPerDegree)
+	maxAbsLatitude := math.Max(math.Abs(bounds.MinLatitude), math.Abs(bounds.MaxLatitude))
+	extraLongitude := margin/(kmPerDegree*math.Max(0.01, math.Cos(degToRad(maxAbsLatitude)))) - margin/kmPerDegree
+	bounds.MinLongitude -= extraLongitude
+	bounds.MaxLongitude += extraLongitude
+	strikeGrid := NewGrid(bounds, options.CellSize)
+	windGrid := NewGrid(bounds, options.CellSize)
+	if len(steps) == 0 || options.Samples <= 0 {
+		return strikeGrid, windGrid
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	strikeHits := newGridMarker(strikeGrid)
+	windHits := newGridMarker(windGrid)
+	for sample := 0; sample < options.Samples; sample++ {
+		zx, zy := random.NormFloat64(), random.NormFloat64()
+		distance := math.Hypot(zx, zy)
+		theta := radToDeg(math.Atan2(zy, zx))
+
+		strikeHits.next()
+		windHits.next()
+		for _, step := range steps {
+			center := CalcCirclePoint(step.center.Latitude, step.center.Longitude, step.sigma*distance, theta)
+			strikeHits.markWithin(center, options.StrikeRadius)
+			if step.windRadius > 0 {
+				windHits.markWithin(center, step.windRadius)
+			}
+		}
+	}
+
+	for row := 0; row < strikeGrid.Rows; row++ {
+		for col := 0; col < strikeGrid.Cols; col++ {
+			strikeGrid.Values[row][col] = 100 * float64(strikeHits.count[row][col]) / float64(options.Samples)
+			windGrid.Values[row][col] = 100 * float64(windHits.count[row][col]) / float64(options.Samples)
+		}
+	}
+
+	return strikeGrid, windGrid
+}
+
+// 1度あたりの距離(km) (緯度方向)
+var kmPerDegree = EarthRadius * math.Pi / 180
+
+// 試行ごとに、1回でもかかった格子を数える
+type gridMarker struct {
+	grid   model.Grid
+	count  [][]int
+	marked [][]int // 最後にかかった試行の番号 (同じ試行で2回数えないため)
+	sample int
+}
+
+func newGridMarker(grid model.Grid) *gridMarker {
+	m := &gridMarker{grid: grid, count: make([][]int, grid.Rows), marked: make([][]int, grid.Rows)}
+	for row := 0; row < grid.Rows; row++ {
+		m.count[row] = make([]int, grid.Cols)
+		m.marked[row] = make([]int, grid.Cols)
+	}
+	return m
+}
+
+func (m *gridMarker) next() {
+	m.sample++
+}
+
+// 中心から半径radius(km)以内にある格子に印を付ける
+func (m *gridMarker) markWithin(center model.Point, radius float64) {
+	dLatitude := radius / kmPerDegree
+	dLongitude := radius / (kmPerDegree * math.Max(0.01, math.Cos(degToRad(center.Latitude))))
+	minRow := int(math.Floor((center.Latitude - dLatitude - m.grid.MinLatitude) / m.grid.CellSize))
+	maxRow := int(math.Floor((center.Latitude + dLatitude - m.grid.MinLatitude) / m.grid.CellSize))
+	minCol := int(math.Floor((center.Longitude - dLongitude - m.grid.MinLongitude) / m.grid.CellSize))
+	maxCol := int(math.Floor((center.Longitude + dLongitude - m.grid.MinLongitude) / m.grid.CellSize))
+
+	for row := max(0, minRow); row <= min(m.grid.Rows-1, maxRow); row++ {
+		for col := max(0, minCol); col <= min(m.grid.Cols-1, maxCol); col++ {
+			if m.marked[row][col] == m.sample {
+				continue
+			}
+			cell := GridCellCenter(m.grid, row, col)
+			if HaversineDistance(center.Latitude, center.Longitude, cell.Latitude, cell.Longitude) <= radius {
+				m.marked[row][col] = m.sample
+				m.count[row][col]++
+			}
+		}
+	}
+}
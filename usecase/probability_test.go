@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 北緯35度の予報円1つだけの予報
+func makeTestProbabilityTyphoons(radius int) []model.Typhoon {
+	return []model.Typhoon{{
+		Latitude:  35,
+		Longitude: 140,
+		WarningAreas: []model.TyphoonWarningArea{
+			{WarningAreaType: "予報円", CircleLongRadius: radius, CircleShortRadius: radius},
+		},
+		ValidTime: time.Date(2024, 8, 29, 0, 0, 0, 0, time.UTC),
+		FixKind:   model.FixKindForecast,
+		LeadHours: 24,
+	}}
+}
+
+func TestForecastCircleSigma(t *testing.T) {
+	// 半径Rの円に入る確率が70%になる
+	for _, radius := range []float64{50, 200, 500} {
+		sigma := ForecastCircleSigma(radius)
+		if p := 1 - math.Exp(-radius*radius/(2*sigma*sigma)); math.Abs(p-ForecastCircleProbability) > 1e-12 {
+			t.Errorf("ForecastCircleSigma(%g): 円に入る確率 = %g", radius, p)
+		}
+	}
+}
+
+func TestCalcProbabilityGridsForecastCircle(t *testing.T) {
+	const radius = 200
+	typhoons := makeTestProbabilityTyphoons(radius)
+	options := model.ProbabilityOptions{
+		CellSize:     0.2,
+		StrikeRadius: radius,
+		Samples:      10000,
+		Seed:         1,
+	}
+	strikeGrid, _ := CalcProbabilityGrids(typhoons, options, model.ProductOptions{})
+
+	// 予報円の中心から予報円の半径以内を通る確率は、ずらした中心が予報円に入る割合なので約70%
+	row := int(math.Floor((35 - strikeGrid.MinLatitude) / strikeGrid.CellSize))
+	col := int(math.Floor((140 - strikeGrid.MinLongitude) / strikeGrid.CellSize))
+	if got := strikeGrid.Values[row][col]; math.Abs(got-100*ForecastCircleProbability) > 2 {
+		t.Errorf("予報円の中心の確率 = %.1f%%, want 約70%%", got)
+	}
+
+	// 格子は3σずらした中心から半径の分までを東西南北とも含む
+	margin := 3*ForecastCircleSigma(radius) + radius
+	maxLatitude := strikeGrid.MinLatitude + float64(strikeGrid.Rows)*strikeGrid.CellSize
+	maxLongitude := strikeGrid.MinLongitude + float64(strikeGrid.Cols)*strikeGrid.CellSize
+	for _, theta := range []float64{0, 90, 180, 270} {
+		edge := CalcCirclePoint(35, 140, margin, theta)
+		if edge.Latitude < strikeGrid.MinLatitude || edge.Latitude > maxLatitude || edge.Longitude < strikeGrid.MinLongitude || edge.Longitude > maxLongitude {
+			t.Errorf("方位%g度の余白の端 %+v が格子(%.2f〜%.2f, %.2f〜%.2f)の外", theta, edge, strikeGrid.MinLatitude, maxLatitude, strikeGrid.MinLongitude, maxLongitude)
+		}
+	}
+
+	// 格子の端の確率はほぼ0で、範囲が足りずに切れていない
+	for row := 0; row < strikeGrid.Rows; row++ {
+		for _, col := range []int{0, strikeGrid.Cols - 1} {
+			if strikeGrid.Values[row][col] > 0.1 {
+				t.Errorf("格子の東西の端の確率 = %.2f%%", strikeGrid.Values[row][col])
+			}
+		}
+	}
+}
+
+func TestCalcProbabilityGridsDeterministic(t *testing.T) {
+	typhoons := makeTestProbabilityTyphoons(150)
+	options := model.ProbabilityOptions{CellSize: 0.25, StrikeRadius: 100, Samples: 500, Seed: 7}
+	a, _ := CalcProbabilityGrids(typhoons, options, model.ProductOptions{})
+	b, _ := CalcProbabilityGrids(typhoons, options, model.ProductOptions{})
+	for row := range a.Values {
+		for col := range a.Values[row] {
+			if a.Values[row][col] != b.Values[row][col] {
+				t.Fatalf("同じ乱数の種で結果が変わる: (%d, %d)", row, col)
+			}
+		}
+	}
+}
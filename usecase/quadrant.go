@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"math"
+	"typhoon-polygon/model"
+)
+
+// 象限の並び(北東・南東・南西・北西)の真ん中の方位 (北が0度の時計回り)
+var quadrantBearings = [4]float64{45, 135, 225, 315}
+
+// 象限ごとの半径があるか
+func HasQuadrantRadii(area model.StormArea) bool {
+	return area.QuadrantRadii != [4]float64{}
+}
+
+// 象限ごとの半径から、方位bearing(北が0度の時計回り)の向きの半径を求める
+// 各象限の真ん中の方位でその象限の半径になり、隣の象限との間は余弦でなめらかにつなぐ
+func QuadrantRadius(radii [4]float64, bearing float64) float64 {
+	u := math.Mod(bearing-quadrantBearings[0]+720, 360) / 90
+	i := int(u) % 4
+	t := u - math.Floor(u)
+	w := (1 - math.Cos(math.Pi*t)) / 2
+	return radii[i] + (radii[(i+1)%4]-radii[i])*w
+}
+
+// 象限ごとの半径(北東・南東・南西・北西)の範囲を、台風の中心から各方位の半径だけ進んだ点で描く
+func CalcQuadrantPoints(typhoonCenterLat, typhoonCenterLon float64, radii [4]float64, numPoints int) []model.Point {
+	points := make([]model.Point, 0, numPoints+1)
+
+	for i := 0; i <= numPoints; i++ {
+		// thetaは東が0度の反時計回り、半径は北が0度の時計回りの方位で求める
+		theta := 360 * float64(i) / float64(numPoints)
+		radius := QuadrantRadius(radii, math.Mod(450-theta, 360))
+		points = append(points, CalcCirclePoint(typhoonCenterLat, typhoonCenterLon, radius, theta))
+	}
+
+	return points
+}
+
+// 暴風域・強風域などの範囲を描く
+// 象限ごとの半径があればそれで、無ければ長い方・短い方の半径の円で描く
+func CalcStormAreaPoints(area model.StormArea, numPoints int) []model.Point {
+	if HasQuadrantRadii(area) {
+		return CalcQuadrantPoints(area.CenterPoint.Latitude, area.CenterPoint.Longitude, area.QuadrantRadii, numPoints)
+	}
+	return CalcTyphoonPoints(
+		area.CenterPoint.Latitude,
+		area.CenterPoint.Longitude,
+		area.CircleLongRadius,
+		area.CircleShortRadius,
+		area.CircleLongDirection,
+		numPoints,
+	)
+}
+
+// 象限ごとの半径 (無ければ円から各象限の真ん中の方位の半径を求める)
+// 象限ごとの半径がある時刻と無い時刻の間を補間するときに使う
+func StormAreaQuadrantRadii(area model.StormArea) [4]float64 {
+	if HasQuadrantRadii(area) {
+		return area.QuadrantRadii
+	}
+
+	// 円の中心は台風の中心から長い方へoffsetだけずれている
+	circleRadius := (area.CircleLongRadius + area.CircleShortRadius) / 2.
+	offset := area.CircleLongRadius - circleRadius
+	longBearing := 90 - area.CircleLongDirection
+	radii := [4]float64{}
+	for i, bearing := range quadrantBearings {
+		phi := degToRad(bearing - longBearing)
+		radii[i] = offset*math.Cos(phi) + math.Sqrt(circleRadius*circleRadius-offset*offset*math.Sin(phi)*math.Sin(phi))
+	}
+	return radii
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+func TestQuadrantRadiusEqualRadii(t *testing.T) {
+	radii := [4]float64{200, 200, 200, 200}
+	for bearing := 0.; bearing < 360; bearing += 7.5 {
+		if got := QuadrantRadius(radii, bearing); math.Abs(got-200) > 1e-9 {
+			t.Errorf("QuadrantRadius(%g) = %g, want 200", bearing, got)
+		}
+	}
+
+	// 全て同じ半径なら円と同じ点になる
+	center := model.Point{Latitude: 30, Longitude: 135}
+	quadrantPoints := CalcQuadrantPoints(center.Latitude, center.Longitude, radii, 64)
+	circlePoints := CalcTyphoonPoints(center.Latitude, center.Longitude, 200, 200, 0, 64)
+	if len(quadrantPoints) != len(circlePoints) {
+		t.Fatalf("点の数 = %d, want %d", len(quadrantPoints), len(circlePoints))
+	}
+	for _, point := range quadrantPoints {
+		if distance := HaversineDistance(center.Latitude, center.Longitude, point.Latitude, point.Longitude); math.Abs(distance-200) > 1e-6 {
+			t.Errorf("中心からの距離 = %g, want 200", distance)
+		}
+	}
+}
+
+func TestQuadrantRadiusMidpoints(t *testing.T) {
+	radii := [4]float64{300, 150, 100, 250}
+	tests := []struct {
+		bearing float64
+		want    float64
+	}{
+		{45, 300},
+		{135, 150},
+		{225, 100},
+		{315, 250},
+		{45 + 360, 300},
+		{-45, 250},
+		{90, 225}, // 北東と南東のちょうど間
+		{0, 275},  // 北西と北東のちょうど間
+	}
+	for _, tt := range tests {
+		if got := QuadrantRadius(radii, tt.bearing); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("QuadrantRadius(%g) = %g, want %g", tt.bearing, got, tt.want)
+		}
+	}
+
+	// 隣の象限の間では両方の半径の間に収まる
+	for bearing := 0.; bearing < 360; bearing++ {
+		got := QuadrantRadius(radii, bearing)
+		if got < 100-1e-9 || got > 300+1e-9 {
+			t.Errorf("QuadrantRadius(%g) = %g, 範囲外", bearing, got)
+		}
+	}
+}
+
+func TestStormAreaQuadrantRadiiRoundTrip(t *testing.T) {
+	// 長い方(北東)へずれた円
+	circleArea := model.StormArea{
+		CenterPoint:          model.Point{Latitude: 25, Longitude: 130},
+		CircleLongDirection:  45,
+		CircleLongRadius:     400,
+		CircleShortDirection: 225,
+		CircleShortRadius:    200,
+	}
+	radii := StormAreaQuadrantRadii(circleArea)
+	if radii[0] <= radii[2] {
+		t.Fatalf("長い方の象限の半径が短い: %v", radii)
+	}
+	// 北東・南西は長い方・短い方の半径そのもの
+	if math.Abs(radii[0]-400) > 1e-9 || math.Abs(radii[2]-200) > 1e-9 {
+		t.Errorf("北東・南西の半径 = %g, %g, want 400, 200", radii[0], radii[2])
+	}
+
+	quadrantArea := circleArea
+	quadrantArea.QuadrantRadii = radii
+	if got := StormAreaQuadrantRadii(quadrantArea); got != radii {
+		t.Errorf("象限ごとの半径があるのにそのまま返らない: %v", got)
+	}
+
+	// 各象限の真ん中の方位では、円と象限ごとの半径で中・外の判定が一致する
+	for i, bearing := range quadrantBearings {
+		theta := 90 - bearing
+		for _, scale := range []float64{0.97, 1.03} {
+			point := CalcCirclePoint(circleArea.CenterPoint.Latitude, circleArea.CenterPoint.Longitude, radii[i]*scale, theta)
+			want := scale < 1
+			if got := StormAreaContains(circleArea, point); got != want {
+				t.Errorf("象限%d 倍率%g: 円の判定 = %v, want %v", i, scale, got, want)
+			}
+			if got := StormAreaContains(quadrantArea, point); got != want {
+				t.Errorf("象限%d 倍率%g: 象限ごとの半径の判定 = %v, want %v", i, scale, got, want)
+			}
+		}
+	}
+}
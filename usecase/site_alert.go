@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"math"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 円(暴風域・予報円など)の内側に点があるか
+// 象限ごとの半径があれば、台風の中心から点の方位の半径以内かで判定する
+func StormAreaContains(area model.StormArea, point model.Point) bool {
+	if HasQuadrantRadii(area) {
+		bearing := math.Mod(450-CalculateTheta(area.CenterPoint.Latitude, area.CenterPoint.Longitude, point.Latitude, point.Longitude), 360)
+		return HaversineDistance(area.CenterPoint.Latitude, area.CenterPoint.Longitude, point.Latitude, point.Longitude) <= QuadrantRadius(area.QuadrantRadii, bearing)
+	}
+	circleCenterPoint, circleRadius := stormAreaCircle(area)
+	return HaversineDistance(circleCenterPoint.Latitude, circleCenterPoint.Longitude, point.Latitude, point.Longitude) <= circleRadius
+}
+
+// 時系列の中で初めて点を含む円
+func FirstCoveringStormArea(stormAreaTimeSeries []model.StormArea, point model.Point) (model.StormArea, bool) {
+	for _, area := range stormAreaTimeSeries {
+		if (area.CircleLongRadius > 0 || HasQuadrantRadii(area)) && StormAreaContains(area, point) {
+			return area, true
+		}
+	}
+	return model.StormArea{}, false
+}
+
+// 予報円の時系列を補間できるようにStormAreaの時系列にする
+func ForecastCirclesToStormAreas(forecastCircleTimeSeries []model.ForecastCircle) []model.StormArea {
+	stormAreaTimeSeries := make([]model.StormArea, 0, len(forecastCircleTimeSeries))
+	for _, circle := range forecastCircleTimeSeries {
+		stormAreaTimeSeries = append(stormAreaTimeSeries, model.StormArea{
+			CenterPoint:          circle.CenterPoint,
+			CircleLongDirection:  circle.CircleLongDirection,
+			CircleLongRadius:     circle.CircleLongRadius,
+			CircleShortDirection: circle.CircleShortDirection,
+			CircleShortRadius:    circle.CircleShortRadius,
+			ValidTime:            circle.ValidTime,
+			LeadHours:            circle.LeadHours,
+		})
+	}
+	return stormAreaTimeSeries
+}
+
+// 拠点ごとに、電文の予報円・暴風域と暴風警戒域・強風域の円に入るかを調べる
+// 予報時刻の間はstepごとに補間した円で調べ、初めて入る時刻を求める
+// 中心が最も近づく時刻・距離と予報円の範囲の縁までの距離はCalcClosestApproachで求める
+// 範囲に入る拠点だけを返し、Statusは空のまま (CompareSiteAlertsで決める)
+func EvaluateSites(advisory model.Advisory, sites []model.Site, step time.Duration, options model.ProductOptions) []model.SiteAlert {
+	products := []struct {
+		name                string
+		stormAreaTimeSeries []model.StormArea
+	}{
+		{"forecast_cone", InterpolateStormAreaTimeSeries(ForecastCirclesToStormAreas(MakeForecastCircleTimeSeries(advisory.Typhoons, options)), step)},
+		{"storm_warning_swath", InterpolateStormAreaTimeSeries(MakeStormAreaTimeSeries(advisory.Typhoons, options), step)},
+		{"gale_swath", InterpolateStormAreaTimeSeries(MakeWindAreaTimeSeries(advisory.Typhoons, 15, options), step)},
+	}
+
+	alerts := []model.SiteAlert{}
+	for _, site := range sites {
+		closestApproach, _ := CalcClosestApproach(advisory.Typhoons, site.Point, options)
+		for _, product := range products {
+			area, ok := FirstCoveringStormArea(product.stormAreaTimeSeries, site.Point)
+			if !ok {
+				continue
+			}
+			earliestTime := area.ValidTime
+			alerts = append(alerts, model.SiteAlert{
+				EventID:         advisory.EventID,
+				TyphoonName:     advisory.TyphoonName,
+				Serial:          advisory.Serial,
+				ReportDateTime:  advisory.ReportDateTime,
+				Site:            site.Name,
+				Category:        site.Category,
+				Product:         product.name,
+				EarliestTime:    &earliestTime,
+				EarliestLead:    area.LeadHours,
+				ClosestTime:     closestApproach.Time,
+				ClosestDistance: math.Round(closestApproach.Distance*10) / 10,
+				ConeDistance:    math.Round(closestApproach.ConeDistance*10) / 10,
+			})
+		}
+	}
+
+	return alerts
+}
+
+// 前の報数の結果と比べてStatusを決める
+// 前にも入っていた拠点・範囲はcontinued、新しく入ったものはnew、外れたものはclearedとして加える
+// clearedの中心が最も近づく時刻・距離は、今の電文からCalcClosestApproachで求め直す
+func CompareSiteAlerts(advisory model.Advisory, sites []model.Site, options model.ProductOptions, previous, current []model.SiteAlert) []model.SiteAlert {
+	key := func(alert model.SiteAlert) string {
+		return alert.Site + "\x00" + alert.Product
+	}
+	previousKeys := map[string]bool{}
+	for _, alert := range previous {
+		if alert.Status != model.SiteAlertStatusCleared {
+			previousKeys[key(alert)] = true
+		}
+	}
+	currentKeys := map[string]bool{}
+	sitePoints := map[string]model.Point{}
+	for _, site := range sites {
+		sitePoints[site.Name] = site.Point
+	}
+
+	alerts := []model.SiteAlert{}
+	for _, alert := range current {
+		currentKeys[key(alert)] = true
+		alert.Status = model.SiteAlertStatusNew
+		if previousKeys[key(alert)] {
+			alert.Status = model.SiteAlertStatusContinued
+		}
+		alerts = append(alerts, alert)
+	}
+	for _, alert := range previous {
+		if alert.Status == model.SiteAlertStatusCleared || currentKeys[key(alert)] {
+			continue
+		}
+		alert.Serial = advisory.Serial
+		alert.ReportDateTime = advisory.ReportDateTime
+		alert.Status = model.SiteAlertStatusCleared
+		alert.EarliestTime = nil
+		alert.EarliestLead = 0
+		alert.ClosestTime, alert.ClosestDistance, alert.ConeDistance = time.Time{}, 0, 0
+		if point, ok := sitePoints[alert.Site]; ok {
+			if closestApproach, ok := CalcClosestApproach(advisory.Typhoons, point, options); ok {
+				alert.ClosestTime = closestApproach.Time
+				alert.ClosestDistance = math.Round(closestApproach.Distance*10) / 10
+				alert.ConeDistance = math.Round(closestApproach.ConeDistance*10) / 10
+			}
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts
+}
+
+// 前の報数から変わったもの(new・cleared)だけを残す
+func FilterChangedSiteAlerts(alerts []model.SiteAlert) []model.SiteAlert {
+	changed := []model.SiteAlert{}
+	for _, alert := range alerts {
+		if alert.Status != model.SiteAlertStatusContinued {
+			changed = append(changed, alert)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+func TestCompareSiteAlerts(t *testing.T) {
+	base := time.Date(2024, 8, 28, 0, 0, 0, 0, time.UTC)
+	advisory := model.Advisory{
+		EventID:        "TC2410",
+		Serial:         20,
+		ReportDateTime: base.Add(50 * time.Minute),
+		Typhoons: []model.Typhoon{
+			{FixKind: model.FixKindAnalysis, ValidTime: base, Latitude: 30, Longitude: 130},
+			{FixKind: model.FixKindForecast, ValidTime: base.Add(12 * time.Hour), LeadHours: 12, Latitude: 32, Longitude: 130, WarningAreas: []model.TyphoonWarningArea{
+				{WarningAreaType: "予報円", CircleLongRadius: 50, CircleShortRadius: 50},
+			}},
+		},
+	}
+	sites := []model.Site{
+		{Name: "A", Point: model.Point{Latitude: 31, Longitude: 130}},
+		{Name: "B", Point: model.Point{Latitude: 31, Longitude: 133}},
+	}
+	staleTime := base.Add(-24 * time.Hour)
+	previous := []model.SiteAlert{
+		{Site: "A", Product: "forecast_cone", Serial: 19, Status: model.SiteAlertStatusNew},
+		{Site: "B", Product: "forecast_cone", Serial: 19, Status: model.SiteAlertStatusNew, EarliestTime: &staleTime, EarliestLead: 6, ClosestTime: staleTime, ClosestDistance: 10, ConeDistance: 0},
+	}
+	current := []model.SiteAlert{
+		{Site: "A", Product: "forecast_cone", Serial: 20},
+		{Site: "A", Product: "storm_warning_swath", Serial: 20},
+	}
+
+	alerts := CompareSiteAlerts(advisory, sites, model.ProductOptions{}, previous, current)
+	statuses := map[string]string{}
+	for _, alert := range alerts {
+		statuses[alert.Site+"/"+alert.Product] = alert.Status
+	}
+	want := map[string]string{
+		"A/forecast_cone":       model.SiteAlertStatusContinued,
+		"A/storm_warning_swath": model.SiteAlertStatusNew,
+		"B/forecast_cone":       model.SiteAlertStatusCleared,
+	}
+	for k, status := range want {
+		if statuses[k] != status {
+			t.Errorf("%s = %q, want %q", k, statuses[k], status)
+		}
+	}
+
+	// clearedは今の電文の値になる
+	cleared := alerts[len(alerts)-1]
+	if cleared.Serial != 20 || cleared.EarliestTime != nil || cleared.EarliestLead != 0 {
+		t.Errorf("cleared = %+v, want 第20報で初めて入る時刻なし", cleared)
+	}
+	closest, _ := CalcClosestApproach(advisory.Typhoons, sites[1].Point, model.ProductOptions{})
+	if !cleared.ClosestTime.Equal(closest.Time) || math.Abs(cleared.ClosestDistance-closest.Distance) > 0.1 {
+		t.Errorf("cleared の最接近 = %s %.1fkm, want %s %.1fkm", cleared.ClosestTime, cleared.ClosestDistance, closest.Time, closest.Distance)
+	}
+	if cleared.ConeDistance <= 0 {
+		t.Errorf("cleared の予報円の範囲までの距離 = %.1f, want 0より大きい", cleared.ConeDistance)
+	}
+}
@@ -0,0 +1,259 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 電文を台風(EventID)ごとにまとめる
+// 同時に複数の台風があるとVPTW60/61/62に分かれて発表されるので、ファイル単位ではなく台風単位で扱う
+type StormRegistry struct {
+	storms    map[string]*model.Storm
+	cancelled map[string]bool // 取消された電文 (EventID/Serial)
+	auditLog  []model.AdvisoryAuditEntry
+}
+
+func NewStormRegistry() *StormRegistry {
+	return &StormRegistry{
+		storms:    map[string]*model.Storm{},
+		cancelled: map[string]bool{},
+		auditLog:  []model.AdvisoryAuditEntry{},
+	}
+}
+
+// xmlディレクトリの台風解析・予報情報をすべて読み込む
+// ファイル名は発信時刻から始まるので、訂正・取消は元の電文の後に読み込まれる
+func LoadStormRegistry(dir string) (*StormRegistry, error) {
+	registry := NewStormRegistry()
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !IsTyphoonAdvisoryURL(path) {
+			return nil
+		}
+		advisory, err := ReadTyphoonXMLFile(path)
+		if err != nil {
+			return err
+		}
+		registry.Add(advisory)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// 電文を追加する
+// 訂正は同じ報数の電文を差し替え、取消は同じ報数の電文を取り除く
+// 差し替え・取り除きがあった場合はその記録を返す
+func (r *StormRegistry) Add(advisory model.Advisory) (model.AdvisoryAuditEntry, bool) {
+	key := fmt.Sprintf("%s/%d", advisory.EventID, advisory.Serial)
+	if advisory.InfoType != "取消" && r.cancelled[key] {
+		// 取消済みの電文が後から届いた
+		return model.AdvisoryAuditEntry{}, false
+	}
+
+	storm, ok := r.storms[advisory.EventID]
+	if !ok {
+		storm = &model.Storm{EventID: advisory.EventID}
+		r.storms[advisory.EventID] = storm
+	}
+
+	replacedSourcePath := ""
+	for i, v := range storm.Advisories {
+		if v.Serial != advisory.Serial {
+			continue
+		}
+		replacedSourcePath = v.SourcePath
+		storm.Advisories = append(storm.Advisories[:i], storm.Advisories[i+1:]...)
+		break
+	}
+
+	switch advisory.InfoType {
+	case "取消":
+		r.cancelled[key] = true
+	default:
+		// 名前は途中で付くことがあるので、分かった時点で埋める
+		if advisory.TyphoonNumber != "" {
+			storm.TyphoonNumber = advisory.TyphoonNumber
+		}
+		if advisory.TyphoonName != "" {
+			storm.TyphoonName = advisory.TyphoonName
+			storm.TyphoonNameKana = advisory.TyphoonNameKana
+		}
+
+		storm.Advisories = append(storm.Advisories, advisory)
+		sort.SliceStable(storm.Advisories, func(i, j int) bool {
+			return storm.Advisories[i].Serial < storm.Advisories[j].Serial
+		})
+	}
+
+	if advisory.InfoType != "訂正" && advisory.InfoType != "取消" {
+		return model.AdvisoryAuditEntry{}, false
+	}
+	entry := model.AdvisoryAuditEntry{
+		InfoType:           advisory.InfoType,
+		EventID:            advisory.EventID,
+		Serial:             advisory.Serial,
+		ReportDateTime:     advisory.ReportDateTime,
+		SourcePath:         advisory.SourcePath,
+		ReplacedSourcePath: replacedSourcePath,
+	}
+	r.auditLog = append(r.auditLog, entry)
+	return entry, true
+}
+
+// 訂正・取消による差し替えの記録
+func (r *StormRegistry) AuditLog() []model.AdvisoryAuditEntry {
+	return r.auditLog
+}
+
+// EventID(TC2410)・台風番号(2409)・台風名(SHANSHAN)のいずれかで台風を探す
+func (r *StormRegistry) FindStorm(query string) (*model.Storm, error) {
+	if storm, ok := r.storms[query]; ok && len(storm.Advisories) > 0 {
+		return storm, nil
+	}
+	for _, storm := range r.Storms() {
+		// 台風番号・台風名が付く前の台風に空の検索語が当たらないようにする
+		if (storm.TyphoonNumber != "" && storm.TyphoonNumber == query) || (storm.TyphoonName != "" && strings.EqualFold(storm.TyphoonName, query)) {
+			return storm, nil
+		}
+	}
+	return nil, fmt.Errorf("台風が見つかりません: %s", query)
+}
+
+// EventID順に全ての台風を返す
+// 全ての電文が取消された台風は含めない
+func (r *StormRegistry) Storms() []*model.Storm {
+	storms := make([]*model.Storm, 0, len(r.storms))
+	for _, storm := range r.storms {
+		if len(storm.Advisories) == 0 {
+			continue
+		}
+		storms = append(storms, storm)
+	}
+	sort.Slice(storms, func(i, j int) bool {
+		return storms[i].EventID < storms[j].EventID
+	})
+	return storms
+}
+
+// 台風の最新の電文
+func LatestAdvisory(storm *model.Storm) (model.Advisory, bool) {
+	if len(storm.Advisories) == 0 {
+		return model.Advisory{}, false
+	}
+	return storm.Advisories[len(storm.Advisories)-1], true
+}
+
+// 報数を指定して電文を探す
+func FindAdvisory(storm *model.Storm, serial int) (model.Advisory, error) {
+	for i := len(storm.Advisories) - 1; i >= 0; i-- {
+		if storm.Advisories[i].Serial == serial {
+			return storm.Advisories[i], nil
+		}
+	}
+	return model.Advisory{}, fmt.Errorf("%s 第%d報が見つかりません", storm.EventID, serial)
+}
+
+// 指定した報数の1つ前に受け取った電文
+// 届かなかった・取り消された報数は飛ばす
+func PreviousAdvisory(storm *model.Storm, serial int) (model.Advisory, error) {
+	for i := len(storm.Advisories) - 1; i >= 0; i-- {
+		if storm.Advisories[i].Serial < serial {
+			return storm.Advisories[i], nil
+		}
+	}
+	return model.Advisory{}, fmt.Errorf("%s 第%d報より前の電文が見つかりません", storm.EventID, serial)
+}
+
+// 実況の時刻が指定した時刻以前で最も新しい電文を探す (他の機関の予報と比べるときに使う)
+func FindAdvisoryByAnalysisTime(storm *model.Storm, t time.Time) (model.Advisory, bool) {
+	found := false
+	var latest model.Advisory
+	var latestTime time.Time
+	for _, advisory := range storm.Advisories {
+		for _, typhoon := range advisory.Typhoons {
+			if typhoon.FixKind != model.FixKindAnalysis || typhoon.ValidTime.After(t) {
+				continue
+			}
+			if !found || !typhoon.ValidTime.Before(latestTime) {
+				found, latest, latestTime = true, advisory, typhoon.ValidTime
+			}
+		}
+	}
+	return latest, found
+}
+
+// 台風ごとの最新の電文の一覧
+// GeoJSONPathはgeojsonDirに電文と同じ名前で書き出されている前提
+func MakeLatestProducts(registry *StormRegistry, geojsonDir string) []model.LatestProduct {
+	products := []model.LatestProduct{}
+	for _, storm := range registry.Storms() {
+		advisory, _ := LatestAdvisory(storm)
+		baseName := strings.TrimSuffix(filepath.Base(advisory.SourcePath), ".xml")
+		products = append(products, model.LatestProduct{
+			EventID:        storm.EventID,
+			TyphoonNumber:  storm.TyphoonNumber,
+			TyphoonName:    storm.TyphoonName,
+			Serial:         advisory.Serial,
+			InfoType:       advisory.InfoType,
+			ReportDateTime: advisory.ReportDateTime,
+			SourcePath:     advisory.SourcePath,
+			GeoJSONPath:    filepath.Join(geojsonDir, baseName+".geojson"),
+		})
+	}
+	return products
+}
+
+func SaveLatestProducts(path string, products []model.LatestProduct) error {
+	data, err := json.MarshalIndent(products, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// 訂正・取消で差し替えられた電文の変換結果(json・geojson)を取り除く
+// 元のxmlは記録のために残しておく
+func RetractProducts(entry model.AdvisoryAuditEntry, jsonDir, geojsonDir string) error {
+	if entry.ReplacedSourcePath == "" {
+		return nil
+	}
+	baseName := strings.TrimSuffix(filepath.Base(entry.ReplacedSourcePath), ".xml")
+	for _, path := range []string{
+		filepath.Join(jsonDir, baseName+".json"),
+		filepath.Join(geojsonDir, baseName+".geojson"),
+	} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 差し替えの記録をJSON Linesで追記する
+func AppendAuditLog(path string, entry model.AdvisoryAuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
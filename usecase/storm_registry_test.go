@@ -0,0 +1,308 @@
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+// TC2412 第104報は発表の後に訂正が出ている
+const (
+	testOriginalPath   = "../xml/20240828234034_0_VPTW60_010000.xml"
+	testCorrectionPath = "../xml/20240829000150_0_VPTW60_010000.xml"
+)
+
+func TestStormRegistryCorrection(t *testing.T) {
+	original, err := ReadTyphoonXMLFile(testOriginalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	correction, err := ReadTyphoonXMLFile(testCorrectionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.InfoType != "発表" || correction.InfoType != "訂正" || original.Serial != 104 || correction.Serial != 104 {
+		t.Fatalf("電文 = %s 第%d報 %s, %s 第%d報 %s", original.EventID, original.Serial, original.InfoType, correction.EventID, correction.Serial, correction.InfoType)
+	}
+
+	registry := NewStormRegistry()
+	if _, ok := registry.Add(original); ok {
+		t.Error("発表で差し替えの記録が返る")
+	}
+	entry, ok := registry.Add(correction)
+	if !ok {
+		t.Fatal("訂正で差し替えの記録が返らない")
+	}
+	want := model.AdvisoryAuditEntry{
+		InfoType:           "訂正",
+		EventID:            "TC2412",
+		Serial:             104,
+		ReportDateTime:     correction.ReportDateTime,
+		SourcePath:         testCorrectionPath,
+		ReplacedSourcePath: testOriginalPath,
+	}
+	if entry != want {
+		t.Errorf("訂正の記録 = %+v, want %+v", entry, want)
+	}
+
+	// 元の電文は訂正に差し替わり、同じ報数は1つだけ
+	storm, err := registry.FindStorm("TC2412")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, advisory := range storm.Advisories {
+		if advisory.Serial == 104 {
+			count++
+		}
+	}
+	advisory, err := FindAdvisory(storm, 104)
+	if err != nil || count != 1 || advisory.SourcePath != testCorrectionPath {
+		t.Errorf("第104報 = %d件 %s, %v", count, advisory.SourcePath, err)
+	}
+
+	// 取消は同じ報数を取り除き、後から届いた元の電文も入れない
+	cancel := model.Advisory{EventID: "TC2412", Serial: 104, InfoType: "取消", SourcePath: "cancel.xml"}
+	entry, ok = registry.Add(cancel)
+	if !ok || entry.InfoType != "取消" || entry.ReplacedSourcePath != testCorrectionPath {
+		t.Errorf("取消の記録 = %+v", entry)
+	}
+	if _, err := FindAdvisory(storm, 104); err == nil {
+		t.Error("取消した第104報が残っている")
+	}
+	if _, ok := registry.Add(original); ok {
+		t.Error("取消済みの電文で差し替えの記録が返る")
+	}
+	if _, err := FindAdvisory(storm, 104); err == nil {
+		t.Error("取消済みの電文が後から入った")
+	}
+	if auditLog := registry.AuditLog(); len(auditLog) != 2 || auditLog[0].InfoType != "訂正" || auditLog[1].InfoType != "取消" {
+		t.Errorf("記録 = %+v", auditLog)
+	}
+
+	// 全ての電文が取り消された台風は一覧に出ない
+	only := NewStormRegistry()
+	only.Add(original)
+	only.Add(cancel)
+	if storms := only.Storms(); len(storms) != 0 {
+		t.Errorf("取り消された台風が残っている: %d件", len(storms))
+	}
+}
+
+// xmlディレクトリの電文がEventIDごとにまとまり、報数の順に並ぶこと
+// VPTW61は時期によって別の台風(TC2413・TC2416・TC2418)に使われるので、ファイル名では分けられない
+func TestLoadStormRegistry(t *testing.T) {
+	registry, err := LoadStormRegistry("../xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		eventID       string
+		typhoonNumber string
+		typhoonName   string
+		advisories    int
+		firstSerial   int
+		lastSerial    int
+	}{
+		{"TC2410", "2409", "JONGDARI", 9, 7, 15},
+		{"TC2412", "2410", "SHANSHAN", 150, 1, 150},
+		{"TC2413", "2411", "YAGI", 59, 1, 59},
+		{"TC2415", "2412", "LEEPI", 15, 1, 15},
+		{"TC2416", "", "", 9, 1, 9},
+		{"TC2417", "2413", "BEBINCA", 71, 1, 71},
+		{"TC2418", "2414", "PULASAN", 27, 1, 27},
+		{"TC2419", "", "", 21, 1, 21},
+	}
+	storms := registry.Storms()
+	if len(storms) != len(want) {
+		t.Fatalf("台風の数 = %d, want %d", len(storms), len(want))
+	}
+	for i, w := range want {
+		storm := storms[i]
+		if storm.EventID != w.eventID || storm.TyphoonNumber != w.typhoonNumber || storm.TyphoonName != w.typhoonName {
+			t.Errorf("%d番目の台風 = %s %s %s, want %s %s %s", i, storm.EventID, storm.TyphoonNumber, storm.TyphoonName, w.eventID, w.typhoonNumber, w.typhoonName)
+		}
+		if len(storm.Advisories) != w.advisories {
+			t.Errorf("%s の電文の数 = %d, want %d", w.eventID, len(storm.Advisories), w.advisories)
+			continue
+		}
+		if first, last := storm.Advisories[0].Serial, storm.Advisories[len(storm.Advisories)-1].Serial; first != w.firstSerial || last != w.lastSerial {
+			t.Errorf("%s の報数 = 第%d報〜第%d報, want 第%d報〜第%d報", w.eventID, first, last, w.firstSerial, w.lastSerial)
+		}
+		for j, advisory := range storm.Advisories {
+			if advisory.EventID != storm.EventID {
+				t.Errorf("%s に %s の電文(%s)が入っている", storm.EventID, advisory.EventID, advisory.SourcePath)
+			}
+			// 報数は1つずつ増え、抜けも重なりも無い
+			if j > 0 && advisory.Serial != storm.Advisories[j-1].Serial+1 {
+				t.Errorf("%s の第%d報の次が第%d報", storm.EventID, storm.Advisories[j-1].Serial, advisory.Serial)
+			}
+		}
+	}
+
+	// 同じVPTW61の電文が3つの台風に分かれる
+	vptw61 := map[string]int{}
+	for _, storm := range storms {
+		for _, advisory := range storm.Advisories {
+			if strings.Contains(advisory.SourcePath, "_VPTW61_") {
+				vptw61[storm.EventID]++
+			}
+		}
+	}
+	if vptw61["TC2413"] != 59 || vptw61["TC2416"] != 9 || vptw61["TC2418"] != 27 || len(vptw61) != 3 {
+		t.Errorf("VPTW61の電文の台風ごとの数 = %v", vptw61)
+	}
+}
+
+// 電文が報数の順に届かなくても報数の順に並ぶこと
+func TestStormRegistryAddOrder(t *testing.T) {
+	registry := NewStormRegistry()
+	for _, serial := range []int{3, 1, 2} {
+		registry.Add(model.Advisory{EventID: "TC2410", Serial: serial, InfoType: "発表"})
+	}
+	registry.Add(model.Advisory{EventID: "TC2412", Serial: 1, InfoType: "発表", TyphoonNumber: "2410", TyphoonName: "SHANSHAN"})
+
+	storm, err := registry.FindStorm("TC2410")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, advisory := range storm.Advisories {
+		if advisory.Serial != i+1 {
+			t.Errorf("%d番目の電文 = 第%d報, want 第%d報", i, advisory.Serial, i+1)
+		}
+	}
+	latest, ok := LatestAdvisory(storm)
+	if !ok || latest.Serial != 3 {
+		t.Errorf("最新の電文 = 第%d報, want 第3報", latest.Serial)
+	}
+}
+
+// EventID・台風番号・台風名(大文字小文字を区別しない)で探せること
+func TestFindStorm(t *testing.T) {
+	registry, err := LoadStormRegistry("../xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]string{
+		"TC2413":   "TC2413",
+		"2411":     "TC2413",
+		"YAGI":     "TC2413",
+		"yagi":     "TC2413",
+		"Shanshan": "TC2412",
+		"TC2416":   "TC2416",
+	} {
+		storm, err := registry.FindStorm(query)
+		if err != nil {
+			t.Errorf("FindStorm(%q): %v", query, err)
+			continue
+		}
+		if storm.EventID != want {
+			t.Errorf("FindStorm(%q) = %s, want %s", query, storm.EventID, want)
+		}
+	}
+
+	// 台風番号の無い台風(TC2416・TC2419)に空の検索語が当たらない
+	for _, query := range []string{"", "TC9999", "2499", "HAGIBIS"} {
+		if storm, err := registry.FindStorm(query); err == nil {
+			t.Errorf("FindStorm(%q) = %s, want エラー", query, storm.EventID)
+		}
+	}
+}
+
+func TestLoadStormRegistryCorrection(t *testing.T) {
+	registry, err := LoadStormRegistry("../xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storm, err := registry.FindStorm("TC2412")
+	if err != nil {
+		t.Fatal(err)
+	}
+	advisory, err := FindAdvisory(storm, 104)
+	if err != nil || advisory.SourcePath != filepath.Join("../xml", filepath.Base(testCorrectionPath)) {
+		t.Errorf("第104報 = %s, %v", advisory.SourcePath, err)
+	}
+	found := false
+	for _, entry := range registry.AuditLog() {
+		if entry.EventID == "TC2412" && entry.Serial == 104 {
+			found = entry.InfoType == "訂正" && filepath.Base(entry.ReplacedSourcePath) == filepath.Base(testOriginalPath)
+		}
+	}
+	if !found {
+		t.Errorf("第104報の訂正の記録が無い: %+v", registry.AuditLog())
+	}
+}
+
+func TestRetractProducts(t *testing.T) {
+	dir := t.TempDir()
+	jsonDir := filepath.Join(dir, "json")
+	geojsonDir := filepath.Join(dir, "geojson")
+	for _, d := range []string{jsonDir, geojsonDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := []string{
+		filepath.Join(jsonDir, "20240828234034_0_VPTW60_010000.json"),
+		filepath.Join(geojsonDir, "20240828234034_0_VPTW60_010000.geojson"),
+		filepath.Join(geojsonDir, "20240829000150_0_VPTW60_010000.geojson"),
+	}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry := model.AdvisoryAuditEntry{InfoType: "訂正", SourcePath: testCorrectionPath, ReplacedSourcePath: testOriginalPath}
+	if err := RetractProducts(entry, jsonDir, geojsonDir); err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range paths {
+		_, err := os.Stat(path)
+		if removed := os.IsNotExist(err); removed != (i < 2) {
+			t.Errorf("%s: 削除 = %v", filepath.Base(path), removed)
+		}
+	}
+	// 2回目・差し替え元の無い記録は何もしない
+	if err := RetractProducts(entry, jsonDir, geojsonDir); err != nil {
+		t.Error(err)
+	}
+	if err := RetractProducts(model.AdvisoryAuditEntry{InfoType: "取消"}, jsonDir, geojsonDir); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPreviousAdvisory(t *testing.T) {
+	// 第3報が届かず、第5報が取り消された台風
+	registry := NewStormRegistry()
+	for _, serial := range []int{1, 2, 4, 5, 6} {
+		registry.Add(model.Advisory{EventID: "TC2410", Serial: serial, InfoType: "発表"})
+	}
+	registry.Add(model.Advisory{EventID: "TC2410", Serial: 5, InfoType: "取消"})
+	storm, err := registry.FindStorm("TC2410")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		serial int
+		want   int
+	}{
+		{2, 1},
+		{4, 2},
+		{6, 4},
+		{7, 6},
+	}
+	for _, tt := range tests {
+		advisory, err := PreviousAdvisory(storm, tt.serial)
+		if err != nil || advisory.Serial != tt.want {
+			t.Errorf("PreviousAdvisory(%d) = 第%d報, %v, want 第%d報", tt.serial, advisory.Serial, err, tt.want)
+		}
+	}
+	if _, err := PreviousAdvisory(storm, 1); err == nil {
+		t.Error("第1報より前の電文が見つかる")
+	}
+}
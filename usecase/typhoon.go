@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"typhoon-polygon/model"
+)
+
+// parse_xmls.pyが書き出したjsonファイルを読み込む
+func ReadTyphoonJSONFile(path string) ([]model.Typhoon, error) {
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var typhoons []model.Typhoon
+	err = json.Unmarshal(byteValue, &typhoons)
+	if err != nil {
+		return nil, err
+	}
+	if err := NormalizeTyphoons(typhoons); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return typhoons, nil
+}
+
+// parse_xmls.pyと同じ形式でjsonファイルに書き出す
+func SaveTyphoonJSONFile(path string, typhoons []model.Typhoon) error {
+	data, err := json.MarshalIndent(typhoons, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// 暴風域・暴風警戒域の時系列を取り出す
+func MakeStormAreaTimeSeries(typhoons []model.Typhoon, options model.ProductOptions) []model.StormArea {
+	stormAreaTimeSeries := []model.StormArea{}
+
+	for _, typhoon := range SelectProductTyphoons(typhoons, options) {
+		for _, warningArea := range typhoon.WarningAreas {
+			if warningArea.WarningAreaType == "暴風域" || warningArea.WarningAreaType == "暴風警戒域" {
+				if warningArea.CircleLongRadius == 0 {
+					continue
+				}
+				stormAreaTimeSeries = append(stormAreaTimeSeries, MakeStormArea(typhoon, warningArea))
+			}
+		}
+	}
+
+	return stormAreaTimeSeries
+}
+
+// 指定した風速(15m/sの強風域、25m/sの暴風域・暴風警戒域)の円の時系列を取り出す
+func MakeWindAreaTimeSeries(typhoons []model.Typhoon, windSpeed int, options model.ProductOptions) []model.StormArea {
+	windAreaTimeSeries := []model.StormArea{}
+
+	for _, typhoon := range SelectProductTyphoons(typhoons, options) {
+		for _, warningArea := range typhoon.WarningAreas {
+			if warningArea.WindSpeed != windSpeed || warningArea.CircleLongRadius == 0 {
+				continue
+			}
+			windAreaTimeSeries = append(windAreaTimeSeries, MakeStormArea(typhoon, warningArea))
+			break
+		}
+	}
+
+	return windAreaTimeSeries
+}
+
+// 暴風域・強風域などの円を台風の中心とあわせてStormAreaにする
+func MakeStormArea(typhoon model.Typhoon, warningArea model.TyphoonWarningArea) model.StormArea {
+	quadrantRadii := [4]float64{}
+	if len(warningArea.QuadrantRadii) == 4 {
+		for i, radius := range warningArea.QuadrantRadii {
+			quadrantRadii[i] = float64(radius)
+		}
+	}
+	return model.StormArea{
+		CenterPoint:          model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
+		CircleLongDirection:  DirectionToDegrees(warningArea.CircleLongDirection),
+		CircleLongRadius:     float64(warningArea.CircleLongRadius),
+		CircleShortDirection: DirectionToDegrees(warningArea.CircleShortDirection),
+		CircleShortRadius:    float64(warningArea.CircleShortRadius),
+		QuadrantRadii:        quadrantRadii,
+		ValidTime:            typhoon.ValidTime,
+		LeadHours:            typhoon.LeadHours,
+	}
+}
+
+// 予報円の時系列を取り出す
+// 実況(推定から描く場合は推定)は半径0の円として先頭に入れる
+func MakeForecastCircleTimeSeries(typhoons []model.Typhoon, options model.ProductOptions) []model.ForecastCircle {
+	forecastCircleTimeSeries := []model.ForecastCircle{}
+
+	for _, typhoon := range SelectProductTyphoons(typhoons, options) {
+		// 予報円は実況の中心から始め、推定から描く場合だけ推定の中心から始める
+		if typhoon.FixKind == model.FixKindAnalysis || (typhoon.FixKind == model.FixKindEstimate && options.StartFromEstimate) {
+			forecastCircleTimeSeries = append(
+				forecastCircleTimeSeries,
+				model.ForecastCircle{
+					CenterPoint:          model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
+					CircleLongDirection:  float64(0),
+					CircleLongRadius:     float64(0),
+					CircleShortDirection: float64(0),
+					CircleShortRadius:    float64(0),
+					ValidTime:            typhoon.ValidTime,
+					LeadHours:            typhoon.LeadHours,
+				},
+			)
+		}
+		for _, warningArea := range typhoon.WarningAreas {
+			if warningArea.WarningAreaType == "予報円" {
+				if warningArea.CircleLongRadius == 0 {
+					continue
+				}
+				forecastCircleTimeSeries = append(
+					forecastCircleTimeSeries,
+					model.ForecastCircle{
+						CenterPoint:          model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude},
+						CircleLongDirection:  DirectionToDegrees(warningArea.CircleLongDirection),
+						CircleLongRadius:     float64(warningArea.CircleLongRadius),
+						CircleShortDirection: DirectionToDegrees(warningArea.CircleShortDirection),
+						CircleShortRadius:    float64(warningArea.CircleShortRadius),
+						ValidTime:            typhoon.ValidTime,
+						LeadHours:            typhoon.LeadHours,
+					},
+				)
+			}
+		}
+	}
+
+	return forecastCircleTimeSeries
+}
+
+// 推定(1時間後)の位置を探す
+func FindEstimatedTyphoon(typhoons []model.Typhoon) (model.Typhoon, bool) {
+	for _, typhoon := range typhoons {
+		if typhoon.FixKind == model.FixKindEstimate {
+			return typhoon, true
+		}
+	}
+	return model.Typhoon{}, false
+}
+
+// 暴風域・予報円を作るのに使う実況・推定・予報を選ぶ
+// 通常は実況→推定→予報の全てを使い、推定から描く場合は実況を除く
+// 推定の無い電文では、推定から描く指定でも実況から描く
+// 何時間後の範囲が指定されていれば、その範囲外を除く
+func SelectProductTyphoons(typhoons []model.Typhoon, options model.ProductOptions) []model.Typhoon {
+	_, hasEstimate := FindEstimatedTyphoon(typhoons)
+	startFromEstimate := options.StartFromEstimate && hasEstimate
+
+	selected := []model.Typhoon{}
+	for _, typhoon := range typhoons {
+		if !InLeadRange(typhoon.LeadHours, options) {
+			continue
+		}
+		if typhoon.FixKind == model.FixKindAnalysis && startFromEstimate {
+			continue
+		}
+		selected = append(selected, typhoon)
+	}
+	return selected
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"testing"
+	"typhoon-polygon/model"
+)
+
+func productTestTyphoons() []model.Typhoon {
+	stormArea := model.TyphoonWarningArea{WarningAreaType: "暴風域", WindSpeed: 25, CircleLongRadius: 90, CircleShortRadius: 90}
+	return []model.Typhoon{
+		{FixKind: model.FixKindAnalysis, Latitude: 30, Longitude: 130, WarningAreas: []model.TyphoonWarningArea{stormArea}},
+		{FixKind: model.FixKindEstimate, LeadHours: 1, Latitude: 30.2, Longitude: 130, WarningAreas: []model.TyphoonWarningArea{stormArea}},
+		{FixKind: model.FixKindForecast, LeadHours: 12, Latitude: 32, Longitude: 130, WarningAreas: []model.TyphoonWarningArea{
+			{WarningAreaType: "予報円", CircleLongRadius: 70, CircleShortRadius: 70},
+			{WarningAreaType: "暴風警戒域", WindSpeed: 25, CircleLongRadius: 160, CircleShortRadius: 160},
+		}},
+	}
+}
+
+func TestSelectProductTyphoons(t *testing.T) {
+	typhoons := productTestTyphoons()
+
+	tests := []struct {
+		name    string
+		options model.ProductOptions
+		want    []model.FixKind
+	}{
+		{"通常は実況→推定→予報", model.ProductOptions{}, []model.FixKind{model.FixKindAnalysis, model.FixKindEstimate, model.FixKindForecast}},
+		{"推定から描く", model.ProductOptions{StartFromEstimate: true}, []model.FixKind{model.FixKindEstimate, model.FixKindForecast}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := SelectProductTyphoons(typhoons, tt.options)
+			if len(selected) != len(tt.want) {
+				t.Fatalf("%d件, want %d件", len(selected), len(tt.want))
+			}
+			for i, typhoon := range selected {
+				if typhoon.FixKind != tt.want[i] {
+					t.Errorf("%d件目 = %s, want %s", i, typhoon.FixKind, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// 推定の無い電文では推定から描く指定でも実況から描く
+	withoutEstimate := []model.Typhoon{typhoons[0], typhoons[2]}
+	if got := SelectProductTyphoons(withoutEstimate, model.ProductOptions{StartFromEstimate: true}); len(got) != 2 || got[0].FixKind != model.FixKindAnalysis {
+		t.Errorf("推定の無い電文 = %+v", got)
+	}
+}
+
+func TestTimeSeriesStart(t *testing.T) {
+	typhoons := productTestTyphoons()
+
+	// 暴風域は推定も通る
+	if got := len(MakeStormAreaTimeSeries(typhoons, model.ProductOptions{})); got != 3 {
+		t.Errorf("暴風域 = %d件, want 3件", got)
+	}
+
+	// 予報円は実況から始まり、推定は入らない
+	circles := MakeForecastCircleTimeSeries(typhoons, model.ProductOptions{})
+	if len(circles) != 2 || circles[0].CenterPoint.Latitude != 30 {
+		t.Errorf("予報円 = %+v, want 実況(30,130)と12時間後", circles)
+	}
+
+	// 推定から描く場合は推定から始まる
+	circles = MakeForecastCircleTimeSeries(typhoons, model.ProductOptions{StartFromEstimate: true})
+	if len(circles) != 2 || circles[0].CenterPoint.Latitude != 30.2 {
+		t.Errorf("推定から描く予報円 = %+v, want 推定(30.2,130)と12時間後", circles)
+	}
+}
@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 実況の間隔がこれより空いている時刻は補間しない
+const maxObservedGap = 12 * time.Hour
+
+// 指定した時刻の実況の中心・中心気圧・最大風速を、前後の実況の間を補間して求める
+// 中心は大円に沿って動かし、中心気圧・最大風速は線形に変える
+// 推定は含めず、実況の範囲外や間隔が空きすぎている場合はfalseを返す
+func ObservedFixAt(track model.ObservedTrack, t time.Time) (model.TrackFix, bool) {
+	var before, after model.TrackFix
+	var beforeTime, afterTime time.Time
+	foundBefore, foundAfter := false, false
+	for _, fix := range track.Fixes {
+		if fix.FixKind != model.FixKindAnalysis {
+			continue
+		}
+		validTime, err := time.Parse(TargetTimestampLayout, fix.TargetTimestamp)
+		if err != nil {
+			continue
+		}
+		if validTime.Equal(t) {
+			return fix, true
+		}
+		if validTime.Before(t) && (!foundBefore || validTime.After(beforeTime)) {
+			before, beforeTime, foundBefore = fix, validTime, true
+		}
+		if validTime.After(t) && (!foundAfter || validTime.Before(afterTime)) {
+			after, afterTime, foundAfter = fix, validTime, true
+		}
+	}
+	if !foundBefore || !foundAfter || afterTime.Sub(beforeTime) > maxObservedGap {
+		return model.TrackFix{}, false
+	}
+
+	ratio := float64(t.Sub(beforeTime)) / float64(afterTime.Sub(beforeTime))
+	a, b := before.CenterPoint, after.CenterPoint
+	distance := HaversineDistance(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+	theta := CalculateTheta(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+
+	fix := before
+	fix.TargetTimestamp = t.UTC().Format(TargetTimestampLayout)
+	fix.CenterPoint = CalcCirclePoint(a.Latitude, a.Longitude, distance*ratio, theta)
+	fix.CentralPressure = before.CentralPressure + int(math.Round(float64(after.CentralPressure-before.CentralPressure)*ratio))
+	fix.MaxWindSpeedNearTheCenter = before.MaxWindSpeedNearTheCenter + int(math.Round(float64(after.MaxWindSpeedNearTheCenter-before.MaxWindSpeedNearTheCenter)*ratio))
+	// 最大風速はどちらかの実況に無ければ比べない
+	if before.MaxWindSpeedNearTheCenter == 0 || after.MaxWindSpeedNearTheCenter == 0 {
+		fix.MaxWindSpeedNearTheCenter = 0
+	}
+	return fix, true
+}
+
+// 電文の予報を実況と比べる
+// 予報の時刻の実況が無いもの(まだ実況が無い・経路の範囲外)は含めない
+func VerifyAdvisory(advisory model.Advisory, track model.ObservedTrack) []model.ForecastVerification {
+	verifications := []model.ForecastVerification{}
+
+	baseTime := advisory.TargetDateTime
+	for _, typhoon := range advisory.Typhoons {
+		if typhoon.FixKind == model.FixKindAnalysis {
+			baseTime = typhoon.ValidTime
+		}
+	}
+
+	for _, typhoon := range advisory.Typhoons {
+		if typhoon.FixKind != model.FixKindForecast {
+			continue
+		}
+		observed, ok := ObservedFixAt(track, typhoon.ValidTime)
+		if !ok {
+			continue
+		}
+
+		forecastPoint := model.Point{Latitude: typhoon.Latitude, Longitude: typhoon.Longitude}
+		verification := model.ForecastVerification{
+			EventID:       advisory.EventID,
+			Serial:        advisory.Serial,
+			BaseTime:      baseTime,
+			LeadHours:     typhoon.LeadHours,
+			ValidTime:     typhoon.ValidTime,
+			ForecastPoint: forecastPoint,
+			ObservedPoint: observed.CenterPoint,
+			TrackError:    HaversineDistance(forecastPoint.Latitude, forecastPoint.Longitude, observed.CenterPoint.Latitude, observed.CenterPoint.Longitude),
+			PressureError: typhoon.CentralPressure - observed.CentralPressure,
+		}
+		if typhoon.MaxWindSpeedNearTheCenter > 0 && observed.MaxWindSpeedNearTheCenter > 0 {
+			verification.WindSpeedError = typhoon.MaxWindSpeedNearTheCenter - observed.MaxWindSpeedNearTheCenter
+			verification.HasWindSpeed = true
+		}
+		for _, warningArea := range typhoon.WarningAreas {
+			if warningArea.WarningAreaType == "予報円" && warningArea.CircleLongRadius > 0 {
+				verification.CircleRadius = float64(warningArea.CircleLongRadius)
+				verification.InsideCircle = StormAreaContains(MakeStormArea(typhoon, warningArea), observed.CenterPoint)
+				break
+			}
+		}
+		verifications = append(verifications, verification)
+	}
+
+	return verifications
+}
+
+// 台風の全ての電文の予報を実況と比べる
+func VerifyStorm(storm *model.Storm, track model.ObservedTrack) []model.ForecastVerification {
+	verifications := []model.ForecastVerification{}
+	for _, advisory := range storm.Advisories {
+		verifications = append(verifications, VerifyAdvisory(advisory, track)...)
+	}
+	return verifications
+}
+
+// groupで分けたまとまりごと、何時間後ごとに集計する
+// 例: groupにEventIDを返す関数を渡すと台風ごと、年を返す関数を渡すと年ごとの集計になる
+func SummarizeVerifications(verifications []model.ForecastVerification, group func(model.ForecastVerification) string) []model.VerificationSummary {
+	type key struct {
+		group     string
+		leadHours int
+	}
+	grouped := map[key][]model.ForecastVerification{}
+	keys := []key{}
+	for _, verification := range verifications {
+		k := key{group: group(verification), leadHours: verification.LeadHours}
+		if _, ok := grouped[k]; !ok {
+			keys = append(keys, k)
+		}
+		grouped[k] = append(grouped[k], verification)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].leadHours < keys[j].leadHours
+	})
+
+	summaries := []model.VerificationSummary{}
+	for _, k := range keys {
+		summary := model.VerificationSummary{Group: k.group, LeadHours: k.leadHours}
+		trackErrors := []float64{}
+		for _, v := range grouped[k] {
+			summary.Count++
+			trackErrors = append(trackErrors, v.TrackError)
+			summary.MeanTrackError += v.TrackError
+			summary.MeanPressureError += float64(v.PressureError)
+			summary.MeanAbsPressureError += math.Abs(float64(v.PressureError))
+			if v.CircleRadius > 0 {
+				summary.CircleCount++
+				if v.InsideCircle {
+					summary.HitCount++
+				}
+			}
+			if v.HasWindSpeed {
+				summary.WindSpeedCount++
+				summary.MeanWindSpeedError += float64(v.WindSpeedError)
+				summary.MeanAbsWindSpeedError += math.Abs(float64(v.WindSpeedError))
+			}
+		}
+		n := float64(summary.Count)
+		summary.MeanTrackError /= n
+		summary.MeanPressureError /= n
+		summary.MeanAbsPressureError /= n
+		summary.MedianTrackError = median(trackErrors)
+		if summary.CircleCount > 0 {
+			summary.HitRate = 100 * float64(summary.HitCount) / float64(summary.CircleCount)
+		}
+		if summary.WindSpeedCount > 0 {
+			summary.MeanWindSpeedError /= float64(summary.WindSpeedCount)
+			summary.MeanAbsWindSpeedError /= float64(summary.WindSpeedCount)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2]
+	}
+	return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+}
+
+func SaveVerificationsCSV(path string, verifications []model.ForecastVerification) error {
+	rows := [][]string{{
+		"event_id", "serial", "base_time", "lead_hours", "valid_time",
+		"forecast_latitude", "forecast_longitude", "observed_latitude", "observed_longitude",
+		"track_error", "circle_radius", "inside_circle", "pressure_error", "wind_speed_error",
+	}}
+	for _, v := range verifications {
+		rows = append(rows, []string{
+			v.EventID,
+			strconv.Itoa(v.Serial),
+			v.BaseTime.UTC().Format(TargetTimestampLayout),
+			strconv.Itoa(v.LeadHours),
+			v.ValidTime.UTC().Format(TargetTimestampLayout),
+			formatGridValue(v.ForecastPoint.Latitude),
+			formatGridValue(v.ForecastPoint.Longitude),
+			formatGridValue(v.ObservedPoint.Latitude),
+			formatGridValue(v.ObservedPoint.Longitude),
+			strconv.FormatFloat(v.TrackError, 'f', 1, 64),
+			strconv.FormatFloat(v.CircleRadius, 'f', 0, 64),
+			strconv.FormatBool(v.InsideCircle),
+			strconv.Itoa(v.PressureError),
+			strconv.Itoa(v.WindSpeedError),
+		})
+	}
+	return saveCSV(path, rows)
+}
+
+func SaveVerificationSummaryCSV(path string, summaries []model.VerificationSummary) error {
+	rows := [][]string{{
+		"group", "lead_hours", "count", "mean_track_error", "median_track_error",
+		"circle_count", "hit_count", "hit_rate", "mean_pressure_error", "mean_abs_pressure_error",
+		"wind_speed_count", "mean_wind_speed_error", "mean_abs_wind_speed_error",
+	}}
+	for _, s := range summaries {
+		rows = append(rows, []string{
+			s.Group,
+			strconv.Itoa(s.LeadHours),
+			strconv.Itoa(s.Count),
+			strconv.FormatFloat(s.MeanTrackError, 'f', 1, 64),
+			strconv.FormatFloat(s.MedianTrackError, 'f', 1, 64),
+			strconv.Itoa(s.CircleCount),
+			strconv.Itoa(s.HitCount),
+			strconv.FormatFloat(s.HitRate, 'f', 1, 64),
+			strconv.FormatFloat(s.MeanPressureError, 'f', 1, 64),
+			strconv.FormatFloat(s.MeanAbsPressureError, 'f', 1, 64),
+			strconv.Itoa(s.WindSpeedCount),
+			strconv.FormatFloat(s.MeanWindSpeedError, 'f', 1, 64),
+			strconv.FormatFloat(s.MeanAbsWindSpeedError, 'f', 1, 64),
+		})
+	}
+	return saveCSV(path, rows)
+}
+
+func saveCSV(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+	"typhoon-polygon/model"
+)
+
+var testVerificationBaseTime = time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)
+
+// 0・6時間後の実況、7時間後の推定、24時間後の実況 (6〜24時間後は間隔が空きすぎている)
+func makeTestObservedTrack() model.ObservedTrack {
+	fix := func(hours int, kind model.FixKind, latitude float64, pressure, wind int) model.TrackFix {
+		return model.TrackFix{
+			TargetTimestamp:           testVerificationBaseTime.Add(time.Duration(hours) * time.Hour).Format(TargetTimestampLayout),
+			FixKind:                   kind,
+			CenterPoint:               model.Point{Latitude: latitude, Longitude: 130},
+			CentralPressure:           pressure,
+			MaxWindSpeedNearTheCenter: wind,
+		}
+	}
+	return model.ObservedTrack{
+		EventID: "TC2410",
+		Fixes: []model.TrackFix{
+			fix(0, model.FixKindAnalysis, 20, 990, 25),
+			fix(6, model.FixKindAnalysis, 21, 980, 30),
+			fix(7, model.FixKindEstimate, 21.2, 978, 30),
+			fix(24, model.FixKindAnalysis, 25, 970, 0),
+		},
+	}
+}
+
+func TestObservedFixAt(t *testing.T) {
+	track := makeTestObservedTrack()
+	tests := []struct {
+		name     string
+		hours    float64
+		ok       bool
+		latitude float64
+		pressure int
+		wind     int
+	}{
+		{"実況の時刻", 6, true, 21, 980, 30},
+		{"実況の間を補間", 3, true, 20.5, 985, 28},
+		{"実況の間を補間(1/3)", 2, true, 20 + 1./3, 987, 27},
+		{"最初の実況より前", -1, false, 0, 0, 0},
+		{"推定の時刻は使わない", 7, false, 0, 0, 0},
+		{"12時間より空いた実況の間", 12, false, 0, 0, 0},
+		{"最後の実況より後", 30, false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		fix, ok := ObservedFixAt(track, testVerificationBaseTime.Add(time.Duration(tt.hours*float64(time.Hour))))
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if math.Abs(fix.CenterPoint.Latitude-tt.latitude) > 1e-6 || math.Abs(fix.CenterPoint.Longitude-130) > 1e-6 {
+			t.Errorf("%s: 中心 = %+v, want %g, 130", tt.name, fix.CenterPoint, tt.latitude)
+		}
+		if fix.CentralPressure != tt.pressure || fix.MaxWindSpeedNearTheCenter != tt.wind {
+			t.Errorf("%s: 中心気圧・最大風速 = %d, %d, want %d, %d", tt.name, fix.CentralPressure, fix.MaxWindSpeedNearTheCenter, tt.pressure, tt.wind)
+		}
+	}
+
+	// 12時間ちょうどの間隔なら補間する。片方に最大風速が無ければ0にする
+	gapTrack := model.ObservedTrack{Fixes: []model.TrackFix{track.Fixes[1], track.Fixes[3]}}
+	gapTrack.Fixes[1].TargetTimestamp = testVerificationBaseTime.Add(18 * time.Hour).Format(TargetTimestampLayout)
+	fix, ok := ObservedFixAt(gapTrack, testVerificationBaseTime.Add(12*time.Hour))
+	if !ok {
+		t.Fatal("12時間の間隔で補間されない")
+	}
+	if math.Abs(fix.CenterPoint.Latitude-23) > 1e-6 || fix.CentralPressure != 975 || fix.MaxWindSpeedNearTheCenter != 0 {
+		t.Errorf("12時間の間隔の補間 = %+v", fix)
+	}
+}
+
+func TestVerifyAdvisory(t *testing.T) {
+	forecast := func(hours int, latitude float64, pressure, wind, circleRadius int) model.Typhoon {
+		validTime := testVerificationBaseTime.Add(time.Duration(hours) * time.Hour)
+		return model.Typhoon{
+			TargetTimestamp:           validTime.Format(TargetTimestampLayout),
+			Latitude:                  latitude,
+			Longitude:                 130,
+			CentralPressure:           pressure,
+			MaxWindSpeedNearTheCenter: wind,
+			WarningAreas: []model.TyphoonWarningArea{
+				{WarningAreaType: "予報円", CircleLongRadius: circleRadius, CircleShortRadius: circleRadius},
+			},
+			ValidTime: validTime,
+			FixKind:   model.FixKindForecast,
+			LeadHours: hours,
+		}
+	}
+	advisory := model.Advisory{
+		EventID:        "TC2410",
+		Serial:         3,
+		TargetDateTime: testVerificationBaseTime.Add(-time.Hour),
+		Typhoons: []model.Typhoon{
+			{Latitude: 20, Longitude: 130, ValidTime: testVerificationBaseTime, FixKind: model.FixKindAnalysis},
+			// 実況(20.5度)から約55km、予報円の中
+			forecast(3, 21, 990, 30, 100),
+			// 実況(21度)から約111km、予報円の外
+			forecast(6, 22, 975, 0, 90),
+			// 実況が無い
+			forecast(12, 23, 970, 35, 150),
+		},
+	}
+
+	verifications := VerifyAdvisory(advisory, makeTestObservedTrack())
+	if len(verifications) != 2 {
+		t.Fatalf("検証の数 = %d, want 2", len(verifications))
+	}
+
+	hit, miss := verifications[0], verifications[1]
+	if hit.EventID != "TC2410" || hit.Serial != 3 || !hit.BaseTime.Equal(testVerificationBaseTime) || hit.LeadHours != 3 {
+		t.Errorf("予報 = %+v", hit)
+	}
+	if math.Abs(hit.TrackError-55.6) > 0.5 || hit.CircleRadius != 100 || !hit.InsideCircle {
+		t.Errorf("予報円の中 = %+v", hit)
+	}
+	if hit.PressureError != 5 || !hit.HasWindSpeed || hit.WindSpeedError != 2 {
+		t.Errorf("中心気圧・最大風速の誤差 = %+v", hit)
+	}
+	if math.Abs(miss.TrackError-111.2) > 0.5 || miss.CircleRadius != 90 || miss.InsideCircle {
+		t.Errorf("予報円の外 = %+v", miss)
+	}
+	// 予報に最大風速が無ければ比べない
+	if miss.PressureError != -5 || miss.HasWindSpeed {
+		t.Errorf("最大風速の無い予報 = %+v", miss)
+	}
+}
+
+func TestSummarizeVerifications(t *testing.T) {
+	verification := func(eventID string, leadHours int, trackError float64, circleRadius float64, inside bool, pressureError int) model.ForecastVerification {
+		return model.ForecastVerification{
+			EventID:       eventID,
+			LeadHours:     leadHours,
+			TrackError:    trackError,
+			CircleRadius:  circleRadius,
+			InsideCircle:  inside,
+			PressureError: pressureError,
+		}
+	}
+	verifications := []model.ForecastVerification{
+		verification("TC2410", 48, 50, 200, true, 0),
+		verification("TC2410", 24, 10, 100, true, -4),
+		verification("TC2410", 24, 30, 100, true, 2),
+		verification("TC2410", 48, 70, 200, false, 0),
+		verification("TC2410", 24, 100, 100, false, 6),
+		verification("TC2410", 24, 20, 0, false, -4),
+		verification("TC2410", 48, 60, 200, true, 0),
+		verification("TC2409", 24, 40, 120, true, 0),
+	}
+	verifications[1].HasWindSpeed, verifications[1].WindSpeedError = true, -3
+	verifications[2].HasWindSpeed, verifications[2].WindSpeedError = true, 1
+
+	summaries := SummarizeVerifications(verifications, func(v model.ForecastVerification) string { return v.EventID })
+	if len(summaries) != 3 {
+		t.Fatalf("集計の数 = %d, want 3", len(summaries))
+	}
+	tests := []struct {
+		group                string
+		leadHours            int
+		count                int
+		meanTrackError       float64
+		medianTrackError     float64
+		circleCount          int
+		hitCount             int
+		hitRate              float64
+		meanPressureError    float64
+		meanAbsPressureError float64
+		windSpeedCount       int
+		meanWindSpeedError   float64
+	}{
+		{"TC2409", 24, 1, 40, 40, 1, 1, 100, 0, 0, 0, 0},
+		// 偶数個の中央値は真ん中2つの平均、予報円の無い予報は的中率に含めない
+		{"TC2410", 24, 4, 40, 25, 3, 2, 200. / 3, 0, 4, 2, -1},
+		{"TC2410", 48, 3, 60, 60, 3, 2, 200. / 3, 0, 0, 0, 0},
+	}
+	for i, tt := range tests {
+		s := summaries[i]
+		if s.Group != tt.group || s.LeadHours != tt.leadHours || s.Count != tt.count {
+			t.Errorf("%d番目 = %s %d時間後 %d件, want %s %d時間後 %d件", i, s.Group, s.LeadHours, s.Count, tt.group, tt.leadHours, tt.count)
+			continue
+		}
+		if math.Abs(s.MeanTrackError-tt.meanTrackError) > 1e-9 || math.Abs(s.MedianTrackError-tt.medianTrackError) > 1e-9 {
+			t.Errorf("%s %d時間後: 平均・中央値 = %g, %g, want %g, %g", s.Group, s.LeadHours, s.MeanTrackError, s.MedianTrackError, tt.meanTrackError, tt.medianTrackError)
+		}
+		if s.CircleCount != tt.circleCount || s.HitCount != tt.hitCount || math.Abs(s.HitRate-tt.hitRate) > 1e-9 {
+			t.Errorf("%s %d時間後: 的中 = %d/%d (%g%%), want %d/%d (%g%%)", s.Group, s.LeadHours, s.HitCount, s.CircleCount, s.HitRate, tt.hitCount, tt.circleCount, tt.hitRate)
+		}
+		if math.Abs(s.MeanPressureError-tt.meanPressureError) > 1e-9 || math.Abs(s.MeanAbsPressureError-tt.meanAbsPressureError) > 1e-9 {
+			t.Errorf("%s %d時間後: 中心気圧の誤差 = %g, %g, want %g, %g", s.Group, s.LeadHours, s.MeanPressureError, s.MeanAbsPressureError, tt.meanPressureError, tt.meanAbsPressureError)
+		}
+		if s.WindSpeedCount != tt.windSpeedCount || math.Abs(s.MeanWindSpeedError-tt.meanWindSpeedError) > 1e-9 {
+			t.Errorf("%s %d時間後: 最大風速の誤差 = %d件 %g, want %d件 %g", s.Group, s.LeadHours, s.WindSpeedCount, s.MeanWindSpeedError, tt.windSpeedCount, tt.meanWindSpeedError)
+		}
+	}
+
+	if median(nil) != 0 || median([]float64{3, 1, 2}) != 2 {
+		t.Error("median")
+	}
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"typhoon-polygon/model"
+)
+
+// 送り先の一覧のJSONを読み込む
+// 例: [{"url": "https://hooks.slack.com/services/...", "format": "slack"}, {"url": "http://localhost:8000/hook", "secret": "..."}]
+func ReadWebhookEndpoints(path string) ([]model.WebhookEndpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := []model.WebhookEndpoint{}
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, endpoint := range endpoints {
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("%s: %d番目の送り先にurlがありません", path, i+1)
+		}
+		switch endpoint.Format {
+		case "":
+			endpoints[i].Format = model.WebhookFormatGeneric
+		case model.WebhookFormatSlack, model.WebhookFormatGeneric:
+		default:
+			return nil, fmt.Errorf("%s: 不明なformat: %s", path, endpoint.Format)
+		}
+	}
+	return endpoints, nil
+}
+
+// 電文の要約 (Slackの本文などに使う)
+// 例: 台風第10号(JONGDARI) TC2410 第7報 2024-08-19 12:45:00 UTC 発表 / 実況 北緯26.2度 東経127.7度 990hPa 最大風速25m/s
+func MakeAdvisorySummary(advisory model.Advisory) string {
+	parts := []string{}
+	if advisory.TyphoonNumber != "" {
+		name := fmt.Sprintf("台風第%s号", strings.TrimLeft(advisory.TyphoonNumber[min(2, len(advisory.TyphoonNumber)):], "0"))
+		if advisory.TyphoonName != "" {
+			name += "(" + advisory.TyphoonName + ")"
+		}
+		parts = append(parts, name)
+	}
+	if advisory.EventID != "" {
+		parts = append(parts, advisory.EventID, fmt.Sprintf("第%d報", advisory.Serial))
+	}
+	if !advisory.ReportDateTime.IsZero() {
+		parts = append(parts, advisory.ReportDateTime.UTC().Format(TargetTimestampLayout))
+	}
+	if advisory.InfoType != "" {
+		parts = append(parts, advisory.InfoType)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, advisory.SourcePath)
+	}
+	summary := strings.Join(parts, " ")
+
+	for _, typhoon := range advisory.Typhoons {
+		if typhoon.FixKind == model.FixKindAnalysis {
+			summary += fmt.Sprintf(" / 実況 北緯%.1f度 東経%.1f度 %dhPa", typhoon.Latitude, typhoon.Longitude, typhoon.CentralPressure)
+			// 熱帯低気圧などでは最大風速が入らない
+			if typhoon.MaxWindSpeedNearTheCenter > 0 {
+				summary += fmt.Sprintf(" 最大風速%dm/s", typhoon.MaxWindSpeedNearTheCenter)
+			}
+			break
+		}
+	}
+	return summary
+}
+
+// 送り先の形式に合わせて送る本文を作る
+// SlackのIncoming Webhookには要約だけを送り、genericにはGeoJSONも含めて送る
+func MakeWebhookBody(endpoint model.WebhookEndpoint, notification model.AdvisoryNotification) ([]byte, error) {
+	if endpoint.Format == model.WebhookFormatSlack {
+		return json.Marshal(map[string]string{"text": notification.Summary})
+	}
+	return json.Marshal(notification)
+}
+
+// 本文の署名 ("<timestamp>.<本文>"のHMAC-SHA256)
+// 受け取る側はX-Typhoon-TimestampとX-Typhoon-Signatureで検証する
+func SignWebhookBody(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 受け取った本文の署名を検証する
+// timestampはX-Typhoon-Timestamp、signatureはX-Typhoon-Signatureの値
+func VerifyWebhookSignature(secret string, timestamp string, signature string, body []byte) bool {
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(SignWebhookBody(secret, t, body)))
+}
+
+// 本文をPOSTする
+// 送れなかった場合や5xx・429が返った場合は、retryWaitから倍々に間隔をあけてretries回までやり直す
+func PostWebhook(client *http.Client, endpoint model.WebhookEndpoint, body []byte, retries int, retryWait time.Duration) error {
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryWait << (attempt - 1))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if endpoint.Secret != "" {
+			// やり直すたびに署名し直す
+			timestamp := time.Now().Unix()
+			req.Header.Set("X-Typhoon-Timestamp", strconv.FormatInt(timestamp, 10))
+			req.Header.Set("X-Typhoon-Signature", SignWebhookBody(endpoint.Secret, timestamp, body))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("POST %s: %s", endpoint.URL, resp.Status)
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// 4xxはやり直しても変わらない
+			return lastErr
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"typhoon-polygon/model"
+)
+
+// statusesの順にステータスを返し、受け取った本文の署名を検証する受け手
+func newWebhookReceiver(t *testing.T, secret string, statuses []int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if secret != "" && !VerifyWebhookSignature(secret, r.Header.Get("X-Typhoon-Timestamp"), r.Header.Get("X-Typhoon-Signature"), body) {
+			t.Errorf("%d回目の署名が正しくない: %s", n, r.Header.Get("X-Typhoon-Signature"))
+		}
+		status := http.StatusOK
+		if int(n) <= len(statuses) {
+			status = statuses[n-1]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestPostWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		requests int32
+		wantErr  bool
+	}{
+		{"すぐに届く", nil, 3, 1, false},
+		{"5xxはやり直す", []int{503, 502}, 3, 3, false},
+		{"429はやり直す", []int{429}, 3, 2, false},
+		{"4xxはやり直さない", []int{400}, 3, 1, true},
+		{"やり直しきれない", []int{500, 500, 500}, 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newWebhookReceiver(t, "s3cret", tt.statuses)
+			endpoint := model.WebhookEndpoint{URL: server.URL, Format: model.WebhookFormatGeneric, Secret: "s3cret"}
+			err := PostWebhook(server.Client(), endpoint, []byte(`{"event_id":"TC2410"}`), tt.retries, 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(requests); got != tt.requests {
+				t.Errorf("POST = %d回, want %d回", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event_id":"TC2410"}`)
+	signature := SignWebhookBody("s3cret", 1724025600, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		want      bool
+	}{
+		{"正しい署名", "s3cret", "1724025600", body, true},
+		{"鍵が違う", "other", "1724025600", body, false},
+		{"時刻が違う", "s3cret", "1724025601", body, false},
+		{"本文が違う", "s3cret", "1724025600", []byte(`{"event_id":"TC2411"}`), false},
+		{"時刻が無い", "s3cret", "", body, false},
+	}
+	for _, tt := range tests {
+		if got := VerifyWebhookSignature(tt.secret, tt.timestamp, signature, tt.body); got != tt.want {
+			t.Errorf("%s: VerifyWebhookSignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMakeWebhookBody(t *testing.T) {
+	notification := model.AdvisoryNotification{EventID: "TC2410", Serial: 7, InfoType: "発表", Summary: "TC2410 第7報", GeoJSON: json.RawMessage("null")}
+
+	body, err := MakeWebhookBody(model.WebhookEndpoint{Format: model.WebhookFormatSlack}, notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text":"TC2410 第7報"}` {
+		t.Errorf("slack = %s", body)
+	}
+
+	body, err = MakeWebhookBody(model.WebhookEndpoint{Format: model.WebhookFormatGeneric}, notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := model.AdvisoryNotification{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.EventID != "TC2410" || got.Serial != 7 || got.InfoType != "発表" {
+		t.Errorf("generic = %s", body)
+	}
+}
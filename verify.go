@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// xmlディレクトリの電文の予報を実況と比べ、進路誤差・予報円の的中・強度の誤差を台風ごと・年ごとに集計する
+// 実況は電文に含まれていた実況を使い、-rsmcか-ibtracsを指定した場合はベストトラックを使う
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	verificationOptions := addVerificationFlags(flags)
+	output := flags.String("o", "verification", "書き出すCSVのパスの前半 (<o>_forecasts.csv と <o>_summary.csv)")
+	flags.Parse(args)
+
+	verifications := verificationOptions()
+
+	byStorm := usecase.SummarizeVerifications(verifications, func(v model.ForecastVerification) string {
+		return v.EventID
+	})
+	bySeason := usecase.SummarizeVerifications(verifications, func(v model.ForecastVerification) string {
+		return strconv.Itoa(v.BaseTime.UTC().Year())
+	})
+	printVerificationSummaries(byStorm)
+	printVerificationSummaries(bySeason)
+
+	forecastsPath := *output + "_forecasts.csv"
+	if err := usecase.SaveVerificationsCSV(forecastsPath, verifications); err != nil {
+		log.Fatal(err)
+	}
+	summaryPath := *output + "_summary.csv"
+	if err := usecase.SaveVerificationSummaryCSV(summaryPath, append(byStorm, bySeason...)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("予報%d件の検証結果を %s に、集計を %s に書き出しました\n", len(verifications), forecastsPath, summaryPath)
+}
+
+// -xml・-storm・-rsmc・-ibtracsを追加し、パース後に予報と実況を比べた結果を返す関数を返す
+func addVerificationFlags(flags *flag.FlagSet) func() []model.ForecastVerification {
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "EventID(TC2410)・台風番号(2409)・台風名のいずれか (省略時は全ての台風)")
+	rsmcPath := flags.String("rsmc", "", "実況に使う気象庁のベストトラックのパス")
+	ibtracsPath := flags.String("ibtracs", "", "実況に使うIBTrACSのCSVのパス")
+
+	return func() []model.ForecastVerification {
+		registry, err := usecase.LoadStormRegistry(*xmlDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		storms := registry.Storms()
+		if *stormQuery != "" {
+			storm, err := registry.FindStorm(*stormQuery)
+			if err != nil {
+				log.Fatal(err)
+			}
+			storms = []*model.Storm{storm}
+		}
+
+		var bestTracks []*model.Storm
+		switch {
+		case *rsmcPath != "":
+			bestTracks, err = usecase.ReadRSMCBestTrack(*rsmcPath)
+		case *ibtracsPath != "":
+			bestTracks, err = usecase.ReadIBTrACSCSV(*ibtracsPath)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		verifications := []model.ForecastVerification{}
+		for _, storm := range storms {
+			observed := storm
+			if *rsmcPath != "" || *ibtracsPath != "" {
+				bestTrack, ok := findBestTrackStorm(bestTracks, storm)
+				if !ok {
+					fmt.Printf("%s のベストトラックが見つからないため飛ばします\n", storm.EventID)
+					continue
+				}
+				observed = bestTrack
+			}
+			verifications = append(verifications, usecase.VerifyStorm(storm, service.MakeObservedTrack(observed))...)
+		}
+		if len(verifications) == 0 {
+			log.Fatal("実況と比べられる予報がありません")
+		}
+		return verifications
+	}
+}
+
+// 電文の台風に対応するベストトラックを探す
+// EventID・台風番号が一致するもの、無ければ同じ年の同じ名前のものを返す
+func findBestTrackStorm(bestTracks []*model.Storm, storm *model.Storm) (*model.Storm, bool) {
+	for _, bestTrack := range bestTracks {
+		if bestTrack.EventID == storm.EventID || (storm.TyphoonNumber != "" && bestTrack.TyphoonNumber == storm.TyphoonNumber) {
+			return bestTrack, true
+		}
+	}
+	if storm.TyphoonName == "" || len(storm.Advisories) == 0 {
+		return nil, false
+	}
+	year := storm.Advisories[0].TargetDateTime.UTC().Year()
+	for _, bestTrack := range bestTracks {
+		if len(bestTrack.Advisories) == 0 || !strings.EqualFold(bestTrack.TyphoonName, storm.TyphoonName) {
+			continue
+		}
+		if bestTrack.Advisories[0].TargetDateTime.UTC().Year() == year {
+			return bestTrack, true
+		}
+	}
+	return nil, false
+}
+
+func printVerificationSummaries(summaries []model.VerificationSummary) {
+	fmt.Println("集計\t予報時間\t件数\t平均進路誤差(km)\t予報円的中率\t平均気圧誤差(hPa)\t平均風速誤差(m/s)")
+	for _, s := range summaries {
+		hitRate := "-"
+		if s.CircleCount > 0 {
+			hitRate = fmt.Sprintf("%.1f%% (%d/%d)", s.HitRate, s.HitCount, s.CircleCount)
+		}
+		windSpeedError := "-"
+		if s.WindSpeedCount > 0 {
+			windSpeedError = fmt.Sprintf("%+.1f", s.MeanWindSpeedError)
+		}
+		fmt.Printf(
+			"%s\t%d時間後\t%d\t%.1f\t%s\t%+.1f\t%s\n",
+			s.Group, s.LeadHours, s.Count, s.MeanTrackError, hitRate, s.MeanPressureError, windSpeedError,
+		)
+	}
+	fmt.Println()
+}
+
+// 予報円が本当に70%の確率で実況の中心を捉えていたかを確かめる
+// 予報円を確率ごとの円に縮めた・広げたときの的中率(信頼度表)と、台風ごとの予報円の的中率を表示する
+func runCalibration(args []string) {
+	flags := flag.NewFlagSet("calibration", flag.ExitOnError)
+	verificationOptions := addVerificationFlags(flags)
+	levels := flags.String("levels", "30,50,70,90", "信頼度表に入れる確率(%)")
+	output := flags.String("o", "calibration.csv", "書き出すCSVのパス")
+	flags.Parse(args)
+
+	// 同じ確率が重なると信頼度表の列がずれるので1つにする
+	probabilities := []float64{}
+	seen := map[float64]bool{}
+	for _, level := range parseLevels(*levels) {
+		if level <= 0 || level >= 100 {
+			log.Fatalf("確率は0〜100の間で指定してください: %g", level)
+		}
+		if seen[level] {
+			continue
+		}
+		seen[level] = true
+		probabilities = append(probabilities, level/100)
+	}
+
+	verifications := verificationOptions()
+	all := usecase.CalcCalibration(verifications, probabilities, func(model.ForecastVerification) string {
+		return "all"
+	})
+	byStorm := usecase.CalcCalibration(verifications, []float64{usecase.ForecastCircleProbability}, func(v model.ForecastVerification) string {
+		return v.EventID
+	})
+	if len(all) == 0 {
+		log.Fatal("予報円のある予報がありません")
+	}
+
+	// 信頼度表: 行が何時間後、列が確率
+	// CalcCalibrationは確率の順に並べ替えるので、何時間後と確率で引く
+	type cell struct {
+		leadHours   int
+		probability float64
+	}
+	bins := map[cell]model.CalibrationBin{}
+	leads := []int{}
+	for _, bin := range all {
+		if len(leads) == 0 || leads[len(leads)-1] != bin.LeadHours {
+			leads = append(leads, bin.LeadHours)
+		}
+		bins[cell{leadHours: bin.LeadHours, probability: bin.Probability}] = bin
+	}
+	fmt.Print("予報時間\t件数")
+	for _, probability := range probabilities {
+		fmt.Printf("\t%.0f%%", probability*100)
+	}
+	fmt.Println()
+	for _, leadHours := range leads {
+		fmt.Printf("%d時間後\t%d", leadHours, bins[cell{leadHours: leadHours, probability: probabilities[0]}].Count)
+		for _, probability := range probabilities {
+			bin, ok := bins[cell{leadHours: leadHours, probability: probability}]
+			if !ok {
+				fmt.Print("\t-")
+				continue
+			}
+			fmt.Printf("\t%s", formatCalibrationBin(bin))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	fmt.Println("台風\t予報時間\t予報円の的中率")
+	for _, bin := range byStorm {
+		fmt.Printf("%s\t%d時間後\t%s (%d/%d)\n", bin.Group, bin.LeadHours, formatCalibrationBin(bin), bin.HitCount, bin.Count)
+	}
+	fmt.Println("* は想定した確率が95%信頼区間の外にあるもの")
+
+	if err := usecase.SaveCalibrationCSV(*output, append(all, byStorm...)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("予報%d件の信頼度表を %s に書き出しました\n", len(verifications), *output)
+}
+
+func formatCalibrationBin(bin model.CalibrationBin) string {
+	mark := ""
+	if bin.Probability < bin.LowerBound || bin.Probability > bin.UpperBound {
+		mark = "*"
+	}
+	return fmt.Sprintf("%.1f%%%s", bin.HitRate*100, mark)
+}
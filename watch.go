@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+	"typhoon-polygon/model"
+	"typhoon-polygon/service"
+	"typhoon-polygon/usecase"
+)
+
+// 拠点の一覧を電文ごとに予報円の範囲・暴風警戒域や強風域の通過範囲と照らし合わせ、通知をJSON Linesで出す
+// -pollを指定した場合はフィードを読み、新しい電文が届くたびに通知する
+// 指定しない場合はxmlディレクトリの電文を報数の順に読み直す
+func runWatch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	xmlDir := flags.String("xml", "./xml", "台風解析・予報情報のXMLがあるディレクトリ")
+	stormQuery := flags.String("storm", "", "読み直す台風 (省略時は全ての台風)")
+	sitesPath := flags.String("sites", "", "拠点のCSVのパス (name,latitude,longitude[,category])")
+	changes := flags.Bool("changes", false, "前の報数から変わった拠点(new・cleared)だけを出す")
+	step := flags.Duration("step", time.Hour, "予報時刻の間の円を補間する間隔")
+	output := flags.String("o", "", "追記するJSON Linesのパス (省略時は標準出力)")
+	poll := flags.Bool("poll", false, "フィードを読み、新しい電文が届くたびに通知する")
+	feedURL := flags.String("feed", "https://www.data.jma.go.jp/developer/xml/feed/extra.xml", "AtomフィードのURL (-poll指定時)")
+	interval := flags.Duration("interval", time.Minute, "フィードを読む間隔 (-poll指定時)")
+	productOptions := addProductFlags(flags)
+	flags.Parse(args)
+
+	if *sitesPath == "" {
+		log.Fatal("-sites を指定してください")
+	}
+	sites, err := usecase.ReadSitesCSV(*sitesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := productOptions()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	emit := func(alerts []model.SiteAlert) {
+		if *changes {
+			alerts = usecase.FilterChangedSiteAlerts(alerts)
+		}
+		for _, alert := range alerts {
+			if err := encoder.Encode(alert); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if !*poll {
+		registry, err := usecase.LoadStormRegistry(*xmlDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		storms := registry.Storms()
+		if *stormQuery != "" {
+			storm, err := registry.FindStorm(*stormQuery)
+			if err != nil {
+				log.Fatal(err)
+			}
+			storms = []*model.Storm{storm}
+		}
+		for _, storm := range storms {
+			previous := []model.SiteAlert{}
+			for _, advisory := range storm.Advisories {
+				alerts := usecase.CompareSiteAlerts(advisory, sites, options, previous, usecase.EvaluateSites(advisory, sites, *step, options))
+				emit(alerts)
+				previous = alerts
+			}
+		}
+		return
+	}
+
+	poller := service.NewPoller(*feedURL)
+	poller.XMLDir = *xmlDir
+	poller.ProductOptions = options
+	// 台風ごとの前の報数の結果
+	previousAlerts := map[string][]model.SiteAlert{}
+	for {
+		advisories, err := poller.PollOnce()
+		for _, advisory := range advisories {
+			if advisory.InfoType == "取消" {
+				// 次の電文では取消後の前の報数から求め直す
+				delete(previousAlerts, advisory.EventID)
+				continue
+			}
+			previous, ok := previousAlerts[advisory.EventID]
+			if !ok {
+				previous = []model.SiteAlert{}
+				if storm, err := poller.Registry().FindStorm(advisory.EventID); err == nil {
+					if previousAdvisory, ok := findPreviousAdvisory(storm, advisory.Serial); ok {
+						previous = usecase.CompareSiteAlerts(previousAdvisory, sites, options, nil, usecase.EvaluateSites(previousAdvisory, sites, *step, options))
+					}
+				}
+			}
+			alerts := usecase.CompareSiteAlerts(advisory, sites, options, previous, usecase.EvaluateSites(advisory, sites, *step, options))
+			emit(alerts)
+			previousAlerts[advisory.EventID] = alerts
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error polling feed:", err)
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// 指定した報数より前の最も新しい電文
+func findPreviousAdvisory(storm *model.Storm, serial int) (model.Advisory, bool) {
+	for i := len(storm.Advisories) - 1; i >= 0; i-- {
+		if storm.Advisories[i].Serial < serial {
+			return storm.Advisories[i], true
+		}
+	}
+	return model.Advisory{}, false
+}